@@ -0,0 +1,301 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/jsm.go/api"
+)
+
+const (
+	accountBackupManifestFile = "manifest.json"
+	accountBackupTimeFormat   = "20060102T150405Z"
+)
+
+type accountBackupManifest struct {
+	Created time.Time              `json:"created"`
+	Streams []*accountBackupStream `json:"streams"`
+	Failed  []string               `json:"failed,omitempty"`
+}
+
+type accountBackupStream struct {
+	Name     string            `json:"name"`
+	Messages uint64            `json:"messages"`
+	Bytes    uint64            `json:"bytes"`
+	Files    map[string]string `json:"files"`
+}
+
+type accountBackupSet struct {
+	path     string
+	manifest *accountBackupManifest
+}
+
+type accountBackupCheck struct {
+	Stream   string
+	Messages uint64
+	Error    string
+}
+
+func (c *actCmd) backupVerifyAction(_ *fisk.ParseContext) error {
+	var sets []*accountBackupSet
+
+	if fileExists(filepath.Join(c.backupDirectory, accountBackupManifestFile)) {
+		manifest, err := loadAccountBackupManifest(c.backupDirectory)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, &accountBackupSet{path: c.backupDirectory, manifest: manifest})
+	} else {
+		var err error
+		sets, err = accountBackupSets(c.backupDirectory)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sets) == 0 {
+		return fmt.Errorf("no backups with a %s found in %s", accountBackupManifestFile, c.backupDirectory)
+	}
+
+	failed := 0
+	for _, set := range sets {
+		checks := verifyAccountBackupSet(set, c.verifyDeep)
+
+		table := newTableWriter(fmt.Sprintf("Backup %s created %s", set.path, f(set.manifest.Created.Local())))
+		table.AddHeaders("Stream", "Messages", "Status")
+		for _, check := range checks {
+			status := "OK"
+			if check.Error != "" {
+				status = check.Error
+				failed++
+			}
+			table.AddRow(check.Stream, f(check.Messages), status)
+		}
+		fmt.Println(table.Render())
+
+		for _, s := range set.manifest.Failed {
+			fmt.Printf("WARNING: backup of %s failed when creating this backup\n", s)
+		}
+		if len(set.manifest.Failed) > 0 {
+			fmt.Println()
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("verification failed for %d streams", failed)
+	}
+
+	return nil
+}
+
+// verifyAccountBackupSet checks every stream in the manifest against the files on disk, deep also reads all messages
+func verifyAccountBackupSet(set *accountBackupSet, deep bool) []*accountBackupCheck {
+	var checks []*accountBackupCheck
+	known := map[string]bool{}
+
+	// failed streams are reported separately and might have left a partial directory behind
+	for _, stream := range set.manifest.Failed {
+		known[stream] = true
+	}
+
+	for _, stream := range set.manifest.Streams {
+		known[stream.Name] = true
+		check := &accountBackupCheck{Stream: stream.Name, Messages: stream.Messages}
+		checks = append(checks, check)
+
+		dir := filepath.Join(set.path, stream.Name)
+		for file, sum := range stream.Files {
+			actual, err := sha256File(filepath.Join(dir, file))
+			if err != nil {
+				check.Error = err.Error()
+				break
+			}
+			if actual != sum {
+				check.Error = fmt.Sprintf("checksum mismatch for %s", file)
+				break
+			}
+		}
+
+		if check.Error != "" || !deep {
+			continue
+		}
+
+		backup, err := openStreamBackup(dir)
+		if err == nil {
+			check.Messages = 0
+			err = backup.read(func(_ *streamMsgRecord) error {
+				check.Messages++
+				return nil
+			})
+		}
+		switch {
+		case err != nil:
+			check.Error = err.Error()
+		case check.Messages != stream.Messages:
+			check.Error = fmt.Sprintf("found %s messages, expected %s", f(check.Messages), f(stream.Messages))
+		}
+	}
+
+	entries, err := os.ReadDir(set.path)
+	if err != nil {
+		return append(checks, &accountBackupCheck{Error: err.Error()})
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !known[entry.Name()] {
+			checks = append(checks, &accountBackupCheck{Stream: entry.Name(), Error: "not listed in the manifest"})
+		}
+	}
+
+	return checks
+}
+
+// newAccountBackupStream records the checksums of the files in a stream backup
+func newAccountBackupStream(dir string, name string) (*accountBackupStream, error) {
+	stream := &accountBackupStream{Name: name, Files: map[string]string{}}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		stream.Files[entry.Name()], err = sha256File(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var req api.JSApiStreamRestoreRequest
+	rj, err := os.ReadFile(filepath.Join(dir, "backup.json"))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(rj, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	stream.Messages = req.State.Msgs
+	stream.Bytes = req.State.Bytes
+
+	return stream, nil
+}
+
+func writeAccountBackupManifest(dir string, manifest *accountBackupManifest) error {
+	j, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, accountBackupManifestFile), j, 0600)
+}
+
+func loadAccountBackupManifest(dir string) (*accountBackupManifest, error) {
+	mj, err := os.ReadFile(filepath.Join(dir, accountBackupManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &accountBackupManifest{}
+	err = json.Unmarshal(mj, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup manifest in %s: %v", dir, err)
+	}
+
+	return manifest, nil
+}
+
+// accountBackupSets finds all backups with manifests in dir, newest first
+func accountBackupSets(dir string) ([]*accountBackupSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []*accountBackupSet
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() || !fileExists(filepath.Join(path, accountBackupManifestFile)) {
+			continue
+		}
+
+		manifest, err := loadAccountBackupManifest(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, &accountBackupSet{path: path, manifest: manifest})
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].manifest.Created.After(sets[j].manifest.Created)
+	})
+
+	return sets, nil
+}
+
+// pruneAccountBackups removes backups beyond the newest keep or older than maxAge, current is never removed
+func pruneAccountBackups(dir string, current string, keep int, maxAge time.Duration) ([]string, error) {
+	sets, err := accountBackupSets(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i, set := range sets {
+		if set.path == current {
+			continue
+		}
+
+		if (keep > 0 && i >= keep) || (maxAge > 0 && time.Since(set.manifest.Created) > maxAge) {
+			err = os.RemoveAll(set.path)
+			if err != nil {
+				return removed, err
+			}
+			removed = append(removed, set.path)
+		}
+	}
+
+	return removed, nil
+}
+
+func sha256File(file string) (string, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, fh)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestAccountBackupSets(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		_, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.FileStorage())
+		checkErr(t, err, "create failed: %v", err)
+		for i := 0; i < 10; i++ {
+			checkErr(t, nc.Publish("orders.new", []byte("order")), "publish failed")
+		}
+		checkErr(t, nc.Flush(), "flush failed")
+
+		dir := t.TempDir()
+		for i, age := range []time.Duration{48 * time.Hour, 24 * time.Hour, 2 * time.Hour} {
+			old := filepath.Join(dir, time.Now().Add(-age).UTC().Format(accountBackupTimeFormat))
+			checkErr(t, os.Mkdir(old, 0700), "mkdir failed")
+			err = writeAccountBackupManifest(old, &accountBackupManifest{Created: time.Now().Add(-age - time.Duration(i))})
+			checkErr(t, err, "manifest failed: %v", err)
+		}
+
+		SetContext(context.Background())
+		c := &actCmd{backupDirectory: dir, force: true, snapShotConsumers: true, backupTimestamp: true, backupKeep: 3, backupMaxAge: 12 * time.Hour}
+		err = c.backupAction(nil)
+		checkErr(t, err, "backup failed: %v", err)
+
+		sets, err := accountBackupSets(dir)
+		checkErr(t, err, "sets failed: %v", err)
+		if len(sets) != 2 {
+			t.Fatalf("expected 2 backups after pruning got %d", len(sets))
+		}
+		if len(sets[0].manifest.Streams) != 1 || sets[0].manifest.Streams[0].Name != "ORDERS" || sets[0].manifest.Streams[0].Messages != 10 {
+			t.Fatalf("invalid manifest: %+v", sets[0].manifest)
+		}
+
+		checks := verifyAccountBackupSet(sets[0], true)
+		if len(checks) != 1 || checks[0].Error != "" || checks[0].Messages != 10 {
+			t.Fatalf("verify failed: %+v", checks[0])
+		}
+
+		sets[0].manifest.Streams[0].Messages = 11
+		checks = verifyAccountBackupSet(sets[0], true)
+		if len(checks) != 1 || checks[0].Error != "found 10 messages, expected 11" {
+			t.Fatalf("expected message count mismatch: %+v", checks[0])
+		}
+		sets[0].manifest.Streams[0].Messages = 10
+
+		// partial directories of streams that failed to back up are not reported again
+		sets[0].manifest.Failed = []string{"FAILED"}
+		checkErr(t, os.Mkdir(filepath.Join(sets[0].path, "FAILED"), 0700), "mkdir failed")
+		checks = verifyAccountBackupSet(sets[0], false)
+		if len(checks) != 1 || checks[0].Error != "" {
+			t.Fatalf("expected failed stream to be skipped: %+v", checks)
+		}
+
+		err = os.WriteFile(filepath.Join(sets[0].path, "ORDERS", "stream.tar.s2"), []byte("corrupt"), 0600)
+		checkErr(t, err, "write failed: %v", err)
+		checkErr(t, os.Mkdir(filepath.Join(sets[0].path, "OTHER"), 0700), "mkdir failed")
+
+		checks = verifyAccountBackupSet(sets[0], false)
+		if len(checks) != 2 || checks[0].Error != "checksum mismatch for stream.tar.s2" || checks[1].Error == "" {
+			t.Fatalf("expected verify to fail: %+v %+v", checks[0], checks[1])
+		}
+	})
+}
@@ -43,6 +43,10 @@ type actCmd struct {
 	snapShotConsumers bool
 	force             bool
 	failOnWarn        bool
+	backupTimestamp   bool
+	backupKeep        int
+	backupMaxAge      time.Duration
+	verifyDeep        bool
 
 	placementCluster string
 	placementTags    []string
@@ -65,12 +69,20 @@ func configureActCommand(app commandHost) {
 
 	report.Command("statistics", "Report on server statistics").Alias("stats").Alias("statsz").Action(c.reportServerStats)
 
-	backup := act.Command("backup", "Creates a backup of all  JetStream Streams over the NATS network").Alias("snapshot").Action(c.backupAction)
-	backup.Arg("target", "Directory to create the backup in").Required().StringVar(&c.backupDirectory)
-	backup.Flag("check", "Checks the Stream for health prior to backup").UnNegatableBoolVar(&c.healthCheck)
-	backup.Flag("consumers", "Enable or disable consumer backups").Default("true").BoolVar(&c.snapShotConsumers)
-	backup.Flag("force", "Perform backup without prompting").Short('f').UnNegatableBoolVar(&c.force)
-	backup.Flag("critical-warnings", "Treat warnings as failures").Short('w').UnNegatableBoolVar(&c.failOnWarn)
+	backup := act.Command("backup", "Creates and verifies backups of all JetStream Streams").Alias("snapshot")
+	backupCreate := backup.Command("create", "Creates a backup of all  JetStream Streams over the NATS network").Default().Action(c.backupAction)
+	backupCreate.Arg("target", "Directory to create the backup in").Required().StringVar(&c.backupDirectory)
+	backupCreate.Flag("check", "Checks the Stream for health prior to backup").UnNegatableBoolVar(&c.healthCheck)
+	backupCreate.Flag("consumers", "Enable or disable consumer backups").Default("true").BoolVar(&c.snapShotConsumers)
+	backupCreate.Flag("force", "Perform backup without prompting").Short('f').UnNegatableBoolVar(&c.force)
+	backupCreate.Flag("critical-warnings", "Treat warnings as failures").Short('w').UnNegatableBoolVar(&c.failOnWarn)
+	backupCreate.Flag("timestamp", "Creates the backup in a new timestamped directory inside the target").UnNegatableBoolVar(&c.backupTimestamp)
+	backupCreate.Flag("keep", "Keeps only the newest timestamped backups in the target after a successful backup").PlaceHolder("COUNT").IntVar(&c.backupKeep)
+	backupCreate.Flag("max-age", "Removes timestamped backups older than a duration from the target after a successful backup").PlaceHolder("DURATION").DurationVar(&c.backupMaxAge)
+
+	verify := backup.Command("verify", "Verifies the integrity of account backups").Action(c.backupVerifyAction)
+	verify.Arg("directory", "The backup, or directory of timestamped backups, to verify").Required().ExistingDirVar(&c.backupDirectory)
+	verify.Flag("deep", "Also reads every message held in the backups and compares their count with the manifest").UnNegatableBoolVar(&c.verifyDeep)
 
 	restore := act.Command("restore", "Restore an account backup over the NATS network").Action(c.restoreAction)
 	restore.Arg("directory", "The directory holding the account backup to restore").Required().ExistingDirVar(&c.backupDirectory)
@@ -87,6 +99,10 @@ func init() {
 func (c *actCmd) backupAction(_ *fisk.ParseContext) error {
 	var err error
 
+	if (c.backupKeep > 0 || c.backupMaxAge > 0) && !c.backupTimestamp {
+		return fmt.Errorf("--keep and --max-age requires --timestamp")
+	}
+
 	_, mgr, err := prepareHelper("", natsOpts()...)
 	fisk.FatalIfError(err, "setup failed")
 
@@ -112,7 +128,16 @@ func (c *actCmd) backupAction(_ *fisk.ParseContext) error {
 		totalSize += state.Bytes
 	}
 
-	cols := newColumns("Performing backup of all streams to %s", c.backupDirectory)
+	created := time.Now().UTC()
+	target := c.backupDirectory
+	if c.backupTimestamp {
+		target = filepath.Join(c.backupDirectory, created.Format(accountBackupTimeFormat))
+		if fileExists(target) {
+			return fmt.Errorf("backup %s already exist", target)
+		}
+	}
+
+	cols := newColumns("Performing backup of all streams to %s", target)
 	cols.AddRow("Streams", len(streams))
 	cols.AddRow("Size", humanize.IBytes(totalSize))
 	cols.AddRow("Consumers:", totalConsumers)
@@ -130,16 +155,17 @@ func (c *actCmd) backupAction(_ *fisk.ParseContext) error {
 		}
 	}
 
-	err = os.MkdirAll(c.backupDirectory, 0700)
+	err = os.MkdirAll(target, 0700)
 	if err != nil {
 		return err
 	}
 
 	var errs []error
 	var warns []error
+	manifest := &accountBackupManifest{Created: created}
 
 	for _, s := range streams {
-		err = backupStream(s, false, c.snapShotConsumers, c.healthCheck, filepath.Join(c.backupDirectory, s.Name()), 128*1024)
+		err = backupStream(s, false, c.snapShotConsumers, c.healthCheck, filepath.Join(target, s.Name()), 128*1024)
 		if errors.Is(err, jsm.ErrMemoryStreamNotSupported) {
 			fmt.Printf("Backup of %s failed: %v\n", s.Name(), err)
 			warns = append(warns, fmt.Errorf("%s: %w", s.Name(), err))
@@ -148,6 +174,22 @@ func (c *actCmd) backupAction(_ *fisk.ParseContext) error {
 			errs = append(errs, fmt.Errorf("%s: %s", s.Name(), err))
 		}
 		fmt.Println()
+
+		if err != nil {
+			manifest.Failed = append(manifest.Failed, s.Name())
+			continue
+		}
+
+		stream, err := newAccountBackupStream(filepath.Join(target, s.Name()), s.Name())
+		if err != nil {
+			return fmt.Errorf("could not record checksums for %s: %v", s.Name(), err)
+		}
+		manifest.Streams = append(manifest.Streams, stream)
+	}
+
+	err = writeAccountBackupManifest(target, manifest)
+	if err != nil {
+		return fmt.Errorf("could not write backup manifest: %v", err)
 	}
 
 	if len(warns) > 0 {
@@ -170,6 +212,16 @@ func (c *actCmd) backupAction(_ *fisk.ParseContext) error {
 		return fmt.Errorf("backup failed")
 	}
 
+	if c.backupKeep > 0 || c.backupMaxAge > 0 {
+		removed, err := pruneAccountBackups(c.backupDirectory, target, c.backupKeep, c.backupMaxAge)
+		for _, r := range removed {
+			fmt.Printf("Removed old backup %s\n", r)
+		}
+		if err != nil {
+			return fmt.Errorf("could not remove old backups: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -186,19 +238,24 @@ func (c *actCmd) restoreAction(kp *fisk.ParseContext) error {
 	}
 	de, err := os.ReadDir(c.backupDirectory)
 	fisk.FatalIfError(err, "setup failed")
+	var dirs []os.DirEntry
 	for _, d := range de {
 		if !d.IsDir() {
-			fisk.FatalIfError(err, "expected a directory")
+			if d.Name() == accountBackupManifestFile {
+				continue
+			}
+			fisk.Fatalf("expected a directory: %s", d.Name())
 		}
+		dirs = append(dirs, d)
 		if _, ok := existingStreams[d.Name()]; ok {
 			fisk.Fatalf("stream %q exists already", d.Name())
 		}
 		_, err := os.Stat(filepath.Join(c.backupDirectory, d.Name(), "backup.json"))
 		fisk.FatalIfError(err, "expected backup.json")
 	}
-	fmt.Printf("Restoring backup of all %d streams in directory %q\n\n", len(de), c.backupDirectory)
+	fmt.Printf("Restoring backup of all %d streams in directory %q\n\n", len(dirs), c.backupDirectory)
 	s := &streamCmd{msgID: -1, showProgress: false, placementCluster: c.placementCluster, placementTags: c.placementTags}
-	for _, d := range de {
+	for _, d := range dirs {
 		s.backupDirectory = filepath.Join(c.backupDirectory, d.Name())
 		err := s.restoreAction(kp)
 		fisk.FatalIfError(err, "restore for %s failed", d.Name())
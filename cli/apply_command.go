@@ -0,0 +1,1007 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats.go"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	manifestKindStream   = "stream"
+	manifestKindConsumer = "consumer"
+	manifestKindKV       = "kv"
+	manifestKindObject   = "object"
+)
+
+// jsManifest is a single declarative description of a JetStream asset
+type jsManifest struct {
+	Kind   string          `json:"kind"`
+	Stream string          `json:"stream,omitempty"`
+	Config json.RawMessage `json:"config"`
+
+	source string
+}
+
+// kvManifestConfig is the manifest representation of a Key-Value bucket
+type kvManifestConfig struct {
+	Bucket       string          `json:"bucket"`
+	Description  string          `json:"description,omitempty"`
+	History      uint8           `json:"history"`
+	TTL          time.Duration   `json:"ttl"`
+	MaxValueSize int32           `json:"max_value_size"`
+	MaxBytes     int64           `json:"max_bytes"`
+	Storage      api.StorageType `json:"storage"`
+	Replicas     int             `json:"replicas"`
+	Compression  bool            `json:"compression"`
+	Placement    *api.Placement  `json:"placement,omitempty"`
+	RePublish    *api.RePublish  `json:"republish,omitempty"`
+}
+
+// objectManifestConfig is the manifest representation of an Object Store bucket
+type objectManifestConfig struct {
+	Bucket      string            `json:"bucket"`
+	Description string            `json:"description,omitempty"`
+	TTL         time.Duration     `json:"ttl"`
+	MaxBytes    int64             `json:"max_bytes"`
+	Storage     api.StorageType   `json:"storage"`
+	Replicas    int               `json:"replicas"`
+	Compression bool              `json:"compression"`
+	Placement   *api.Placement    `json:"placement,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type applyPlanItem struct {
+	Kind   string `json:"kind"`
+	Stream string `json:"stream,omitempty"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Diff   string `json:"diff,omitempty"`
+
+	apply func() error
+}
+
+type applyCmd struct {
+	files  []string
+	prune  bool
+	dryRun bool
+	force  bool
+	json   bool
+
+	nc  *nats.Conn
+	mgr *jsm.Manager
+	js  nats.JetStreamContext
+}
+
+var (
+	defaultManifestStream = api.StreamConfig{
+		Retention:    api.LimitsPolicy,
+		Discard:      api.DiscardOld,
+		Storage:      api.FileStorage,
+		MaxConsumers: -1,
+		MaxMsgs:      -1,
+		MaxMsgsPer:   -1,
+		MaxBytes:     -1,
+		MaxMsgSize:   -1,
+		Replicas:     1,
+	}
+
+	// duration keys in manifest configs that accept values like 1h in addition to nanoseconds
+	manifestDurationKeys = map[string][]string{
+		manifestKindStream:   {"max_age", "duplicate_window"},
+		manifestKindConsumer: {"ack_wait", "idle_heartbeat", "backoff", "max_expires", "inactive_threshold"},
+		manifestKindKV:       {"ttl"},
+		manifestKindObject:   {"ttl"},
+	}
+
+	defaultManifestKV = kvManifestConfig{
+		History:      1,
+		MaxValueSize: -1,
+		MaxBytes:     -1,
+		Storage:      api.FileStorage,
+		Replicas:     1,
+	}
+
+	defaultManifestObject = objectManifestConfig{
+		MaxBytes: -1,
+		Storage:  api.FileStorage,
+		Replicas: 1,
+	}
+)
+
+func configureApplyCommand(app commandHost) {
+	c := &applyCmd{}
+
+	help := `Reconciles JetStream assets with declarative manifests
+
+Manifests are YAML or JSON documents, multiple documents may be placed in a
+single YAML file separated by ---. Each document has a kind, one of stream,
+consumer, kv or object, and a config holding the configuration using the same
+keys seen in JSON output from the info commands:
+
+   kind: stream
+   config:
+     name: ORDERS
+     subjects: ["orders.>"]
+
+   kind: consumer
+   stream: ORDERS
+   config:
+     durable_name: PROCESSOR
+     ack_policy: explicit
+
+Durations such as max_age or ttl may be given as nanoseconds or as strings
+like 1h or 30s.
+
+Properties not mentioned in a manifest are left unchanged when updating
+existing assets. When using --prune assets of the kinds found in the
+manifests but not described by them are removed, durable consumers are only
+removed from streams mentioned in the manifests.
+
+When using --dry-run the command exits with code 1 when changes are pending.
+`
+
+	apply := app.Command("apply", "Apply declarative JetStream configuration").Action(c.applyAction)
+	apply.HelpLong(help)
+	apply.Flag("file", "Manifest file or directory of manifests to apply (pass multiple times)").Short('f').Required().ExistingFilesOrDirsVar(&c.files)
+	apply.Flag("prune", "Remove assets not described in the manifests").UnNegatableBoolVar(&c.prune)
+	apply.Flag("dry-run", "Only shows the plan, do not make any changes").UnNegatableBoolVar(&c.dryRun)
+	apply.Flag("force", "Apply changes without prompting").UnNegatableBoolVar(&c.force)
+	apply.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+
+	addCheat("apply", apply)
+}
+
+func init() {
+	registerCommand("apply", 1, configureApplyCommand)
+}
+
+func (c *applyCmd) applyAction(_ *fisk.ParseContext) error {
+	manifests, err := loadManifests(c.files)
+	if err != nil {
+		return err
+	}
+
+	if len(manifests) == 0 {
+		return fmt.Errorf("no manifests found")
+	}
+
+	c.nc, c.mgr, err = prepareHelper("", natsOpts()...)
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	plan, err := c.plan(manifests)
+	if err != nil {
+		return err
+	}
+
+	changes := 0
+	for _, item := range plan {
+		if item.Action != "unchanged" {
+			changes++
+		}
+	}
+
+	if c.json {
+		printJSON(plan)
+	} else {
+		c.renderPlan(plan)
+	}
+
+	if changes == 0 {
+		if !c.json {
+			fmt.Println("No changes required")
+		}
+		return nil
+	}
+
+	if c.dryRun {
+		os.Exit(1)
+	}
+
+	if !c.force {
+		ok, err := askConfirmation(fmt.Sprintf("Really apply %d changes", changes), false)
+		fisk.FatalIfError(err, "could not obtain confirmation")
+
+		if !ok {
+			return nil
+		}
+	}
+
+	applied := 0
+	for _, item := range plan {
+		if item.apply == nil {
+			continue
+		}
+
+		err = item.apply()
+		if err != nil {
+			return fmt.Errorf("could not %s %s %s after %d changes: %v", item.Action, item.Kind, item.displayName(), applied, err)
+		}
+		applied++
+
+		if !c.json {
+			fmt.Printf("Applied %s of %s %s\n", item.Action, item.Kind, item.displayName())
+		}
+	}
+
+	return nil
+}
+
+func (p *applyPlanItem) displayName() string {
+	if p.Stream != "" {
+		return fmt.Sprintf("%s > %s", p.Stream, p.Name)
+	}
+
+	return p.Name
+}
+
+func (c *applyCmd) renderPlan(plan []*applyPlanItem) {
+	table := newTableWriter("JetStream Apply Plan")
+	table.AddHeaders("Kind", "Name", "Action")
+	for _, item := range plan {
+		table.AddRow(item.Kind, item.displayName(), item.Action)
+	}
+	fmt.Println(table.Render())
+	fmt.Println()
+
+	for _, item := range plan {
+		if item.Diff == "" {
+			continue
+		}
+
+		fmt.Printf("Differences for %s %s (-old +new):\n%s\n", item.Kind, item.displayName(), item.Diff)
+	}
+}
+
+// plan compares the manifests with the live account and produces an ordered list of changes
+func (c *applyCmd) plan(manifests []*jsManifest) ([]*applyPlanItem, error) {
+	var plan []*applyPlanItem
+	kinds := map[string]bool{}
+	declared := map[string]bool{}
+	managedStreams := map[string]bool{}
+
+	for _, m := range manifests {
+		name, err := m.name()
+		if err != nil {
+			return nil, err
+		}
+
+		key := m.Kind + "/" + m.Stream + "/" + name
+		if declared[key] {
+			return nil, fmt.Errorf("%s: duplicate %s %s", m.source, m.Kind, name)
+		}
+		declared[key] = true
+		kinds[m.Kind] = true
+
+		var item *applyPlanItem
+		switch m.Kind {
+		case manifestKindStream:
+			managedStreams[name] = true
+			item, err = c.planStream(m, name)
+		case manifestKindConsumer:
+			managedStreams[m.Stream] = true
+			item, err = c.planConsumer(m, name)
+		case manifestKindKV:
+			item, err = c.planKV(m, name)
+		case manifestKindObject:
+			item, err = c.planObject(m, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.source, err)
+		}
+
+		plan = append(plan, item)
+	}
+
+	if c.prune {
+		pruned, err := c.planPrune(kinds, declared, managedStreams)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, pruned...)
+	}
+
+	sort.SliceStable(plan, func(i, j int) bool {
+		return planItemOrder(plan[i]) < planItemOrder(plan[j])
+	})
+
+	return plan, nil
+}
+
+// planItemOrder ensures consumers are removed before, and created after, the streams they belong to
+func planItemOrder(item *applyPlanItem) int {
+	kinds := []string{manifestKindStream, manifestKindKV, manifestKindObject, manifestKindConsumer}
+	for i, k := range kinds {
+		if k != item.Kind {
+			continue
+		}
+
+		if item.Action == "delete" {
+			return 10 + len(kinds) - i
+		}
+
+		return i
+	}
+
+	return 0
+}
+
+func (c *applyCmd) planStream(m *jsManifest, name string) (*applyPlanItem, error) {
+	item := &applyPlanItem{Kind: m.Kind, Name: name}
+
+	known, err := c.mgr.IsKnownStream(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired api.StreamConfig
+
+	if !known {
+		err = overlayManifestConfig(defaultManifestStream, m.Config, &desired)
+		if err != nil {
+			return nil, err
+		}
+
+		err = validateManifestConfig(&desired)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Action = "create"
+		item.apply = func() error {
+			_, err := c.mgr.NewStreamFromDefault(name, desired)
+			return err
+		}
+
+		return item, nil
+	}
+
+	stream, err := c.mgr.LoadStream(name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = overlayManifestConfig(stream.Configuration(), m.Config, &desired)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateManifestConfig(&desired)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Diff = manifestDiff(stream.Configuration(), desired)
+	if item.Diff == "" {
+		item.Action = "unchanged"
+		return item, nil
+	}
+
+	item.Action = "update"
+	item.apply = func() error {
+		return stream.UpdateConfiguration(desired)
+	}
+
+	return item, nil
+}
+
+func (c *applyCmd) planConsumer(m *jsManifest, name string) (*applyPlanItem, error) {
+	item := &applyPlanItem{Kind: m.Kind, Stream: m.Stream, Name: name}
+
+	var live *api.ConsumerConfig
+
+	known, err := c.mgr.IsKnownStream(m.Stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if known {
+		known, err = c.mgr.IsKnownConsumer(m.Stream, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if known {
+			consumer, err := c.mgr.LoadConsumer(m.Stream, name)
+			if err != nil {
+				return nil, err
+			}
+			cfg := consumer.Configuration()
+			live = &cfg
+		}
+	}
+
+	var desired api.ConsumerConfig
+	if live == nil {
+		err = overlayManifestConfig(jsm.DefaultConsumer, m.Config, &desired)
+	} else {
+		err = overlayManifestConfig(*live, m.Config, &desired)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if desired.Durable == "" {
+		desired.Durable = name
+	}
+
+	err = validateManifestConfig(&desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if live != nil {
+		item.Diff = manifestDiff(*live, desired)
+		if item.Diff == "" {
+			item.Action = "unchanged"
+			return item, nil
+		}
+		item.Action = "update"
+	} else {
+		item.Action = "create"
+	}
+
+	item.apply = func() error {
+		_, err := c.mgr.NewConsumerFromDefault(m.Stream, desired)
+		return err
+	}
+
+	return item, nil
+}
+
+func (c *applyCmd) planKV(m *jsManifest, name string) (*applyPlanItem, error) {
+	item := &applyPlanItem{Kind: m.Kind, Name: name}
+	stream := "KV_" + name
+
+	known, err := c.mgr.IsKnownStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired kvManifestConfig
+
+	if !known {
+		err = overlayManifestConfig(defaultManifestKV, m.Config, &desired)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Action = "create"
+		item.apply = func() error {
+			_, err := c.js.CreateKeyValue(desired.natsConfig())
+			return err
+		}
+
+		return item, nil
+	}
+
+	str, err := c.mgr.LoadStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	live := kvManifestFromStream(name, str.Configuration())
+	err = overlayManifestConfig(live, m.Config, &desired)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Diff = manifestDiff(live, desired)
+	if item.Diff == "" {
+		item.Action = "unchanged"
+		return item, nil
+	}
+
+	item.Action = "update"
+	item.apply = func() error {
+		return str.UpdateConfiguration(desired.streamConfig(str.Configuration()))
+	}
+
+	return item, nil
+}
+
+func (c *applyCmd) planObject(m *jsManifest, name string) (*applyPlanItem, error) {
+	item := &applyPlanItem{Kind: m.Kind, Name: name}
+	stream := "OBJ_" + name
+
+	known, err := c.mgr.IsKnownStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired objectManifestConfig
+
+	if !known {
+		err = overlayManifestConfig(defaultManifestObject, m.Config, &desired)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Action = "create"
+		item.apply = func() error {
+			_, err := c.js.CreateObjectStore(desired.natsConfig())
+			return err
+		}
+
+		return item, nil
+	}
+
+	str, err := c.mgr.LoadStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	live := objectManifestFromStream(name, str.Configuration())
+	err = overlayManifestConfig(live, m.Config, &desired)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Diff = manifestDiff(live, desired)
+	if item.Diff == "" {
+		item.Action = "unchanged"
+		return item, nil
+	}
+
+	item.Action = "update"
+	item.apply = func() error {
+		return str.UpdateConfiguration(desired.streamConfig(str.Configuration()))
+	}
+
+	return item, nil
+}
+
+func (c *applyCmd) planPrune(kinds map[string]bool, declared map[string]bool, managedStreams map[string]bool) ([]*applyPlanItem, error) {
+	var plan []*applyPlanItem
+
+	names, err := c.mgr.StreamNames(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		var item *applyPlanItem
+
+		switch {
+		case jsm.IsKVBucketStream(name):
+			bucket := strings.TrimPrefix(name, "KV_")
+			if kinds[manifestKindKV] && !declared[manifestKindKV+"//"+bucket] {
+				item = &applyPlanItem{Kind: manifestKindKV, Name: bucket, apply: func() error { return c.js.DeleteKeyValue(bucket) }}
+			}
+
+		case jsm.IsObjectBucketStream(name):
+			bucket := strings.TrimPrefix(name, "OBJ_")
+			if kinds[manifestKindObject] && !declared[manifestKindObject+"//"+bucket] {
+				item = &applyPlanItem{Kind: manifestKindObject, Name: bucket, apply: func() error { return c.js.DeleteObjectStore(bucket) }}
+			}
+
+		case jsm.IsInternalStream(name):
+			continue
+
+		case kinds[manifestKindStream] && !declared[manifestKindStream+"//"+name]:
+			stream := name
+			item = &applyPlanItem{Kind: manifestKindStream, Name: stream, apply: func() error { return c.mgr.DeleteStream(stream) }}
+		}
+
+		if item != nil {
+			item.Action = "delete"
+			plan = append(plan, item)
+			continue
+		}
+
+		if !kinds[manifestKindConsumer] || !managedStreams[name] {
+			continue
+		}
+
+		consumers, _, err := c.mgr.Consumers(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, consumer := range consumers {
+			if !consumer.IsDurable() || declared[manifestKindConsumer+"/"+name+"/"+consumer.Name()] {
+				continue
+			}
+
+			cons := consumer
+			plan = append(plan, &applyPlanItem{Kind: manifestKindConsumer, Stream: name, Name: cons.Name(), Action: "delete", apply: cons.Delete})
+		}
+	}
+
+	return plan, nil
+}
+
+func (m *jsManifest) name() (string, error) {
+	var names struct {
+		Name    string `json:"name"`
+		Durable string `json:"durable_name"`
+		Bucket  string `json:"bucket"`
+	}
+
+	err := json.Unmarshal(m.Config, &names)
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid %s config: %v", m.source, m.Kind, err)
+	}
+
+	var name string
+	switch m.Kind {
+	case manifestKindStream:
+		name = names.Name
+	case manifestKindConsumer:
+		if m.Stream == "" {
+			return "", fmt.Errorf("%s: consumer manifests require a stream", m.source)
+		}
+		name = names.Durable
+		if name == "" {
+			name = names.Name
+		}
+	case manifestKindKV, manifestKindObject:
+		name = names.Bucket
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("%s: %s manifest does not have a name", m.source, m.Kind)
+	}
+
+	return name, nil
+}
+
+// loadManifests reads all manifests from files or, non recursively, directories of files
+func loadManifests(paths []string) ([]*jsManifest, error) {
+	var manifests []*jsManifest
+
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+		if stat.IsDir() {
+			files = nil
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				switch filepath.Ext(entry.Name()) {
+				case ".yaml", ".yml", ".json":
+					if !entry.IsDir() {
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+		}
+
+		for _, file := range files {
+			fm, err := parseManifestFile(file)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, fm...)
+		}
+	}
+
+	return manifests, nil
+}
+
+func parseManifestFile(file string) ([]*jsManifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseManifests(file, data)
+}
+
+// parseManifests parses one or more YAML or JSON documents into manifests
+func parseManifests(source string, data []byte) ([]*jsManifest, error) {
+	var manifests []*jsManifest
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		var doc map[string]any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+
+		if len(doc) == 0 {
+			continue
+		}
+
+		j, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s document %d: %v", source, i, err)
+		}
+
+		m := &jsManifest{source: fmt.Sprintf("%s document %d", source, i)}
+		err = json.Unmarshal(j, m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.source, err)
+		}
+
+		switch m.Kind {
+		case manifestKindStream, manifestKindConsumer, manifestKindKV, manifestKindObject:
+		default:
+			return nil, fmt.Errorf("%s: unknown kind %q", m.source, m.Kind)
+		}
+
+		if len(m.Config) == 0 {
+			return nil, fmt.Errorf("%s: no config given", m.source)
+		}
+
+		m.Config, err = parseManifestDurations(m.Config, manifestDurationKeys[m.Kind])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.source, err)
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
+
+// parseManifestDurations replaces duration strings like 1h in keys with nanoseconds as used in the JSON configuration
+func parseManifestDurations(config json.RawMessage, keys []string) (json.RawMessage, error) {
+	var cfg map[string]any
+	dec := json.NewDecoder(bytes.NewReader(config))
+	dec.UseNumber()
+	err := dec.Decode(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(key string, v any) (any, error) {
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+
+		d, err := parseDurationString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+
+		return int64(d), nil
+	}
+
+	for _, key := range keys {
+		v, ok := cfg[key]
+		if !ok {
+			continue
+		}
+
+		if list, ok := v.([]any); ok {
+			for i := range list {
+				list[i], err = parse(key, list[i])
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		cfg[key], err = parse(key, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(cfg)
+}
+
+// overlayManifestConfig replaces top level keys in base with those set in the manifest and stores the result in target
+func overlayManifestConfig(base any, manifest json.RawMessage, target any) error {
+	bj, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+
+	merged := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(bj))
+	dec.UseNumber()
+	err = dec.Decode(&merged)
+	if err != nil {
+		return err
+	}
+
+	var overlay map[string]any
+	dec = json.NewDecoder(bytes.NewReader(manifest))
+	dec.UseNumber()
+	err = dec.Decode(&overlay)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range overlay {
+		merged[k] = v
+	}
+
+	mj, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(mj, target)
+}
+
+type validatableConfig interface {
+	Validate(...api.StructValidator) (bool, []string)
+}
+
+func validateManifestConfig(cfg validatableConfig) error {
+	if os.Getenv("NOVALIDATE") != "" {
+		return nil
+	}
+
+	valid, errs := cfg.Validate(new(SchemaValidator))
+	if !valid {
+		return fmt.Errorf("validation failed: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func manifestDiff(live any, desired any) string {
+	// sorts strings to subject lists that only differ in ordering is considered equal
+	sorter := cmp.Transformer("Sort", func(in []string) []string {
+		out := append([]string(nil), in...)
+		sort.Strings(out)
+		return out
+	})
+
+	return cmp.Diff(live, desired, sorter)
+}
+
+func kvManifestFromStream(bucket string, cfg api.StreamConfig) kvManifestConfig {
+	return kvManifestConfig{
+		Bucket:       bucket,
+		Description:  cfg.Description,
+		History:      uint8(cfg.MaxMsgsPer),
+		TTL:          cfg.MaxAge,
+		MaxValueSize: cfg.MaxMsgSize,
+		MaxBytes:     cfg.MaxBytes,
+		Storage:      cfg.Storage,
+		Replicas:     cfg.Replicas,
+		Compression:  cfg.Compression == api.S2Compression,
+		Placement:    cfg.Placement,
+		RePublish:    cfg.RePublish,
+	}
+}
+
+func (k kvManifestConfig) natsConfig() *nats.KeyValueConfig {
+	cfg := &nats.KeyValueConfig{
+		Bucket:       k.Bucket,
+		Description:  k.Description,
+		MaxValueSize: k.MaxValueSize,
+		History:      k.History,
+		TTL:          k.TTL,
+		MaxBytes:     k.MaxBytes,
+		Storage:      nats.FileStorage,
+		Replicas:     k.Replicas,
+		Compression:  k.Compression,
+	}
+
+	if k.Storage == api.MemoryStorage {
+		cfg.Storage = nats.MemoryStorage
+	}
+
+	if k.Placement != nil {
+		cfg.Placement = &nats.Placement{Cluster: k.Placement.Cluster, Tags: k.Placement.Tags}
+	}
+
+	if k.RePublish != nil {
+		cfg.RePublish = &nats.RePublish{Source: k.RePublish.Source, Destination: k.RePublish.Destination, HeadersOnly: k.RePublish.HeadersOnly}
+	}
+
+	return cfg
+}
+
+func (k kvManifestConfig) streamConfig(cfg api.StreamConfig) api.StreamConfig {
+	cfg.Description = k.Description
+	cfg.MaxMsgsPer = int64(k.History)
+	cfg.MaxAge = k.TTL
+	cfg.MaxMsgSize = k.MaxValueSize
+	cfg.MaxBytes = k.MaxBytes
+	cfg.Storage = k.Storage
+	cfg.Replicas = k.Replicas
+	cfg.Placement = k.Placement
+	cfg.RePublish = k.RePublish
+	cfg.Compression = api.NoCompression
+	if k.Compression {
+		cfg.Compression = api.S2Compression
+	}
+
+	return cfg
+}
+
+func objectManifestFromStream(bucket string, cfg api.StreamConfig) objectManifestConfig {
+	return objectManifestConfig{
+		Bucket:      bucket,
+		Description: cfg.Description,
+		TTL:         cfg.MaxAge,
+		MaxBytes:    cfg.MaxBytes,
+		Storage:     cfg.Storage,
+		Replicas:    cfg.Replicas,
+		Compression: cfg.Compression == api.S2Compression,
+		Placement:   cfg.Placement,
+		Metadata:    cfg.Metadata,
+	}
+}
+
+func (o objectManifestConfig) natsConfig() *nats.ObjectStoreConfig {
+	cfg := &nats.ObjectStoreConfig{
+		Bucket:      o.Bucket,
+		Description: o.Description,
+		TTL:         o.TTL,
+		MaxBytes:    o.MaxBytes,
+		Storage:     nats.FileStorage,
+		Replicas:    o.Replicas,
+		Compression: o.Compression,
+		Metadata:    o.Metadata,
+	}
+
+	if o.Storage == api.MemoryStorage {
+		cfg.Storage = nats.MemoryStorage
+	}
+
+	if o.Placement != nil {
+		cfg.Placement = &nats.Placement{Cluster: o.Placement.Cluster, Tags: o.Placement.Tags}
+	}
+
+	return cfg
+}
+
+func (o objectManifestConfig) streamConfig(cfg api.StreamConfig) api.StreamConfig {
+	cfg.Description = o.Description
+	cfg.MaxAge = o.TTL
+	cfg.MaxBytes = o.MaxBytes
+	cfg.Storage = o.Storage
+	cfg.Replicas = o.Replicas
+	cfg.Placement = o.Placement
+	cfg.Metadata = o.Metadata
+	cfg.Compression = api.NoCompression
+	if o.Compression {
+		cfg.Compression = api.S2Compression
+	}
+
+	return cfg
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func planActions(plan []*applyPlanItem) map[string]string {
+	res := map[string]string{}
+	for _, item := range plan {
+		res[item.Kind+" "+item.displayName()] = item.Action
+	}
+	return res
+}
+
+func applyPlan(t *testing.T, plan []*applyPlanItem) {
+	t.Helper()
+
+	for _, item := range plan {
+		if item.apply == nil {
+			continue
+		}
+
+		err := item.apply()
+		checkErr(t, err, "apply %s %s failed: %v", item.Kind, item.Name, err)
+	}
+}
+
+func TestParseManifests(t *testing.T) {
+	manifests, err := parseManifests("test.yaml", []byte(`
+kind: stream
+config:
+  name: ORDERS
+  subjects: ["orders.>"]
+---
+kind: consumer
+stream: ORDERS
+config:
+  name: PROCESSOR
+`))
+	assertNoError(t, err)
+
+	if len(manifests) != 2 {
+		t.Fatalf("expected 2 manifests got %d", len(manifests))
+	}
+
+	name, err := manifests[1].name()
+	assertNoError(t, err)
+	if name != "PROCESSOR" {
+		t.Fatalf("expected PROCESSOR got %q", name)
+	}
+
+	manifests, err = parseManifests("test.yaml", []byte(`
+kind: kv
+config:
+  bucket: CONFIG
+  ttl: 1h
+---
+kind: consumer
+stream: ORDERS
+config:
+  name: PROCESSOR
+  ack_wait: 30s
+  backoff: [1s, 1m, 1000]
+`))
+	assertNoError(t, err)
+
+	var kv kvManifestConfig
+	err = json.Unmarshal(manifests[0].Config, &kv)
+	assertNoError(t, err)
+	if kv.TTL != time.Hour {
+		t.Fatalf("expected a 1h ttl got %v", kv.TTL)
+	}
+
+	var consumer api.ConsumerConfig
+	err = json.Unmarshal(manifests[1].Config, &consumer)
+	assertNoError(t, err)
+	if consumer.AckWait != 30*time.Second || len(consumer.BackOff) != 3 || consumer.BackOff[1] != time.Minute || consumer.BackOff[2] != 1000 {
+		t.Fatalf("invalid consumer durations: %v %v", consumer.AckWait, consumer.BackOff)
+	}
+
+	_, err = parseManifests("test.yaml", []byte("kind: kv\nconfig:\n  bucket: x\n  ttl: soon\n"))
+	if err == nil {
+		t.Fatalf("expected an error for invalid durations")
+	}
+
+	_, err = parseManifests("test.yaml", []byte("kind: other\nconfig:\n  name: x\n"))
+	if err == nil {
+		t.Fatalf("expected an error for unknown kinds")
+	}
+
+	manifests, err = parseManifests("test.yaml", []byte("kind: consumer\nconfig:\n  name: x\n"))
+	assertNoError(t, err)
+	_, err = manifests[0].name()
+	if err == nil {
+		t.Fatalf("expected an error for consumers without streams")
+	}
+}
+
+func TestOverlayManifestConfig(t *testing.T) {
+	base := api.StreamConfig{Name: "ORDERS", Subjects: []string{"a", "b"}, MaxAge: time.Hour, Metadata: map[string]string{"a": "1"}}
+
+	var res api.StreamConfig
+	err := overlayManifestConfig(base, []byte(`{"subjects":["c"],"metadata":{"b":"2"}}`), &res)
+	assertNoError(t, err)
+
+	assertListEquals(t, res.Subjects, "c")
+	if res.MaxAge != time.Hour {
+		t.Fatalf("expected max age to be retained got %v", res.MaxAge)
+	}
+	if len(res.Metadata) != 1 || res.Metadata["b"] != "2" {
+		t.Fatalf("expected metadata to be replaced got %v", res.Metadata)
+	}
+}
+
+func TestApplyPlan(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		c := &applyCmd{nc: nc, mgr: mgr, js: js, prune: true}
+
+		manifests, err := parseManifests("test.yaml", []byte(`
+kind: stream
+config:
+  name: ORDERS
+  subjects: ["orders.>"]
+  storage: memory
+---
+kind: consumer
+stream: ORDERS
+config:
+  durable_name: PROCESSOR
+---
+kind: kv
+config:
+  bucket: CONFIG
+  history: 5
+  storage: memory
+`))
+		checkErr(t, err, "parse failed: %v", err)
+
+		_, err = mgr.NewStream("OLD", jsm.Subjects("old"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		plan, err := c.plan(manifests)
+		checkErr(t, err, "plan failed: %v", err)
+
+		actions := planActions(plan)
+		expected := map[string]string{"stream ORDERS": "create", "consumer ORDERS > PROCESSOR": "create", "kv CONFIG": "create", "stream OLD": "delete"}
+		for k, v := range expected {
+			if actions[k] != v {
+				t.Fatalf("expected %s to be %s got %v", k, v, actions)
+			}
+		}
+		if plan[len(plan)-1].Kind != manifestKindStream || plan[len(plan)-1].Action != "delete" {
+			t.Fatalf("expected deletes to be last")
+		}
+
+		applyPlan(t, plan)
+
+		plan, err = c.plan(manifests)
+		checkErr(t, err, "plan failed: %v", err)
+		for _, item := range plan {
+			if item.Action != "unchanged" {
+				t.Fatalf("expected no changes after apply, got %s for %s %s: %s", item.Action, item.Kind, item.Name, item.Diff)
+			}
+		}
+
+		manifests[0].Config = []byte(`{"name":"ORDERS","description":"Orders"}`)
+		manifests[2].Config = []byte(`{"bucket":"CONFIG","history":10}`)
+		plan, err = c.plan(manifests)
+		checkErr(t, err, "plan failed: %v", err)
+		actions = planActions(plan)
+		if actions["stream ORDERS"] != "update" || actions["kv CONFIG"] != "update" {
+			t.Fatalf("expected updates got %v", actions)
+		}
+		applyPlan(t, plan)
+
+		str, err := mgr.LoadStream("ORDERS")
+		checkErr(t, err, "load failed: %v", err)
+		if str.Description() != "Orders" {
+			t.Fatalf("description was not updated")
+		}
+		assertListEquals(t, str.Subjects(), "orders.>")
+
+		str, err = mgr.LoadStream("KV_CONFIG")
+		checkErr(t, err, "load failed: %v", err)
+		if str.MaxMsgsPerSubject() != 10 {
+			t.Fatalf("history was not updated")
+		}
+	})
+}
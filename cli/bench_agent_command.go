@@ -0,0 +1,712 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/bench"
+	"github.com/nats-io/nuid"
+)
+
+const DefaultBenchControlSubject = "natscli.bench.agents"
+
+// benchAgentStartTimeout is the least time prepared agents wait for the coordinator to start the benchmark, the
+// coordinator prepares all agents before starting any of them
+const benchAgentStartTimeout = time.Minute
+
+type benchAgentCmd struct {
+	name    string
+	control string
+	id      string
+	busy    atomic.Bool
+}
+
+// benchAgentInfo is the reply of agents to discovery requests
+type benchAgentInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Busy    bool   `json:"busy"`
+}
+
+// benchAgentJob describes the clients an agent runs as part of a distributed benchmark
+type benchAgentJob struct {
+	ID               string        `json:"id"`
+	StartSubject     string        `json:"start_subject"`
+	ResultsSubject   string        `json:"results_subject"`
+	HeartbeatSubject string        `json:"heartbeat_subject"`
+	AbortSubject     string        `json:"abort_subject"`
+	StartTimeout     time.Duration `json:"start_timeout"`
+	Heartbeat        time.Duration `json:"heartbeat"`
+	Subject          string        `json:"subject"`
+	Pubs             int           `json:"pubs"`
+	Subs             int           `json:"subs"`
+	PubClients       []int         `json:"pub_clients"`
+	SubClients       []int         `json:"sub_clients"`
+	Msgs             int           `json:"msgs"`
+	Size             int           `json:"size"`
+	Rate             string        `json:"rate,omitempty"`
+	PubSleep         time.Duration `json:"pub_sleep,omitempty"`
+	SubSleep         time.Duration `json:"sub_sleep,omitempty"`
+	Request          bool          `json:"request,omitempty"`
+	MultiSubject     bool          `json:"multi_subject,omitempty"`
+	MultiSubjectMax  int           `json:"multi_subject_max,omitempty"`
+	JS               bool          `json:"js,omitempty"`
+	Stream           string        `json:"stream,omitempty"`
+	SyncPub          bool          `json:"sync_pub,omitempty"`
+	PubBatch         int           `json:"pub_batch,omitempty"`
+	JSTimeout        time.Duration `json:"js_timeout,omitempty"`
+	Pull             bool          `json:"pull,omitempty"`
+	Push             bool          `json:"push,omitempty"`
+	Consumer         string        `json:"consumer,omitempty"`
+	ConsumerBatch    int           `json:"consumer_batch,omitempty"`
+	Dedup            bool          `json:"dedup,omitempty"`
+	Retries          int           `json:"retries,omitempty"`
+	KV               bool          `json:"kv,omitempty"`
+	Obj              bool          `json:"obj,omitempty"`
+	Bucket           string        `json:"bucket,omitempty"`
+	Watch            bool          `json:"watch,omitempty"`
+	CAS              bool          `json:"cas,omitempty"`
+	Keys             int           `json:"keys,omitempty"`
+}
+
+type benchAgentReply struct {
+	Error string `json:"error,omitempty"`
+}
+
+// benchAgentResult holds the samples and latencies recorded by the clients of an agent
+type benchAgentResult struct {
+	ID           string                 `json:"id"`
+	Agent        string                 `json:"agent"`
+	Error        string                 `json:"error,omitempty"`
+	Pubs         []*bench.Sample        `json:"pubs"`
+	Subs         []*bench.Sample        `json:"subs"`
+	PubLatency   *hdrhistogram.Snapshot `json:"pub_latency,omitempty"`
+	SubLatency   *hdrhistogram.Snapshot `json:"sub_latency,omitempty"`
+	CASAttempts  int                    `json:"cas_attempts,omitempty"`
+	CASConflicts int                    `json:"cas_conflicts,omitempty"`
+	FetchTimeout bool                   `json:"fetch_timeout,omitempty"`
+	RetriesUsed  bool                   `json:"retries_used,omitempty"`
+}
+
+func configureBenchAgentCommand(bench *fisk.CmdClause) {
+	c := &benchAgentCmd{}
+
+	help := `Runs benchmark clients on behalf of a coordinator
+
+Agents listen on the control subject until interrupted. A nats bench
+--agents coordinator discovers the agents, divides the publishers and
+subscribers between them, starts all agents at the same time and reports
+the combined results. Running agents send heartbeats, the coordinator gives
+up on agents not heard from within --agent-timeout.
+
+  nats bench agent
+
+  nats bench benchsubject --pub 8 --sub 8 --agents 4
+
+Durations and rates are combined using the clocks of the agents, keep the
+clocks of the hosts running agents in sync.
+`
+
+	agent := bench.Command("agent", "Runs benchmark clients on behalf of a nats bench --agents coordinator").Action(c.agentAction)
+	agent.HelpLong(help)
+	agent.Flag("name", "The name of the agent, defaults to the host name and process id").StringVar(&c.name)
+	agent.Flag("control", "The subject to listen on for coordinators").Default(DefaultBenchControlSubject).StringVar(&c.control)
+}
+
+func (c *benchAgentCmd) agentAction(_ *fisk.ParseContext) error {
+	nc, err := nats.Connect(opts.Config.ServerURL(), natsOpts()...)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	return c.run(ctx, nc)
+}
+
+// run handles discovery requests and jobs one at a time until ctx is done
+func (c *benchAgentCmd) run(ctx context.Context, nc *nats.Conn) error {
+	if c.name == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "agent"
+		}
+		c.name = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+
+	c.id = nuid.Next()
+	subject := fmt.Sprintf("%s.agent.%s", c.control, c.id)
+
+	discover, err := nc.Subscribe(c.control+".discover", func(m *nats.Msg) {
+		info, _ := json.Marshal(&benchAgentInfo{ID: c.id, Name: c.name, Subject: subject, Busy: c.busy.Load()})
+		m.Respond(info)
+	})
+	if err != nil {
+		return err
+	}
+	defer discover.Unsubscribe()
+
+	jobs := make(chan *nats.Msg, 1)
+	sub, err := nc.Subscribe(subject, func(m *nats.Msg) {
+		if !c.busy.CompareAndSwap(false, true) {
+			c.respond(m, fmt.Errorf("agent %s is busy", c.name))
+			return
+		}
+		jobs <- m
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	err = nc.Flush()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Benchmark agent %s listening on %s", c.name, c.control)
+
+	for {
+		select {
+		case m := <-jobs:
+			c.runJob(nc, m)
+			c.busy.Store(false)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *benchAgentCmd) respond(m *nats.Msg, err error) {
+	reply := benchAgentReply{}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+
+	data, _ := json.Marshal(reply)
+	m.Respond(data)
+}
+
+// runJob connects the clients of the job, replies once they are ready and runs them when the coordinator starts the benchmark
+func (c *benchAgentCmd) runJob(nc *nats.Conn, m *nats.Msg) {
+	var job benchAgentJob
+	err := json.Unmarshal(m.Data, &job)
+	if err != nil {
+		c.respond(m, fmt.Errorf("invalid job: %v", err))
+		return
+	}
+
+	cmd, err := job.benchCmd()
+	if err != nil {
+		c.respond(m, err)
+		return
+	}
+	defer cmd.closeConnections()
+
+	// the coordinator aborts the job when it gives up, this stops subscribers waiting for messages the way scenarios do
+	cmd.stop = make(chan struct{})
+	abort := &sync.Once{}
+	asub, err := nc.Subscribe(job.AbortSubject, func(_ *nats.Msg) {
+		abort.Do(func() { close(cmd.stop) })
+	})
+	if err != nil {
+		c.respond(m, err)
+		return
+	}
+	defer asub.Unsubscribe()
+
+	started := make(chan *nats.Msg, 1)
+	start, err := nc.ChanSubscribe(job.StartSubject, started)
+	if err != nil {
+		c.respond(m, err)
+		return
+	}
+	defer start.Unsubscribe()
+
+	log.Printf("Preparing benchmark %s with %d publishers and %d subscribers", job.ID, len(job.PubClients), len(job.SubClients))
+
+	bm := bench.NewBenchmark(c.name, len(job.SubClients), len(job.PubClients))
+	startwg := &sync.WaitGroup{}
+	donewg := &sync.WaitGroup{}
+
+	err = cmd.startSubscribers(bm, startwg, donewg)
+	if err != nil {
+		c.respond(m, err)
+		return
+	}
+	startwg.Wait()
+
+	trigger := make(chan struct{})
+	err = cmd.startPublishers(bm, startwg, donewg, trigger, job.ID)
+	if err != nil {
+		c.respond(m, err)
+		return
+	}
+	startwg.Wait()
+
+	c.respond(m, nil)
+
+	select {
+	case <-started:
+	case <-cmd.stop:
+		log.Printf("Benchmark %s was aborted by the coordinator before it started", job.ID)
+		return
+	case <-time.After(job.StartTimeout):
+		log.Printf("Benchmark %s was not started: %v", job.ID, nats.ErrTimeout)
+		return
+	}
+
+	log.Printf("Running benchmark %s", job.ID)
+
+	stopHeartbeat := c.heartbeat(nc, job)
+	close(trigger)
+	donewg.Wait()
+	stopHeartbeat()
+
+	select {
+	case <-cmd.stop:
+		log.Printf("Benchmark %s was aborted by the coordinator", job.ID)
+		return
+	default:
+	}
+
+	bm.Close()
+
+	res := &benchAgentResult{
+		ID:           c.id,
+		Agent:        c.name,
+		Pubs:         bm.Pubs.Samples,
+		Subs:         bm.Subs.Samples,
+		PubLatency:   cmd.pubLatency.snapshot(),
+		SubLatency:   cmd.subLatency.snapshot(),
+		CASAttempts:  cmd.casStats.attempts,
+		CASConflicts: cmd.casStats.conflicts,
+		FetchTimeout: cmd.fetchTimeout,
+		RetriesUsed:  cmd.retriesUsed,
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		data, _ = json.Marshal(&benchAgentResult{ID: c.id, Agent: c.name, Error: err.Error()})
+	}
+
+	err = nc.Publish(job.ResultsSubject, data)
+	if err == nil {
+		err = nc.Flush()
+	}
+	if err != nil {
+		log.Printf("Could not publish the results of benchmark %s: %v", job.ID, err)
+		return
+	}
+
+	log.Printf("Completed benchmark %s", job.ID)
+}
+
+// heartbeat lets the coordinator know the agent is still running the job until the returned function is called
+func (c *benchAgentCmd) heartbeat(nc *nats.Conn, job benchAgentJob) func() {
+	if job.Heartbeat <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(job.Heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				nc.Publish(job.HeartbeatSubject, []byte(c.id))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// benchCmd creates the benchmark settings for the clients of the job, the coordinator already validated the settings
+func (j *benchAgentJob) benchCmd() (*benchCmd, error) {
+	c := &benchCmd{
+		subject:         j.Subject,
+		numPubs:         j.Pubs,
+		numSubs:         j.Subs,
+		pubClients:      j.PubClients,
+		subClients:      j.SubClients,
+		numMsg:          j.Msgs,
+		msgSize:         j.Size,
+		noProgress:      true,
+		rateString:      j.Rate,
+		pubSleep:        j.PubSleep,
+		subSleep:        j.SubSleep,
+		request:         j.Request,
+		multiSubject:    j.MultiSubject,
+		multiSubjectMax: j.MultiSubjectMax,
+		js:              j.JS,
+		streamName:      j.Stream,
+		syncPub:         j.SyncPub,
+		pubBatch:        j.PubBatch,
+		jsTimeout:       j.JSTimeout,
+		pull:            j.Pull,
+		pushDurable:     j.Push,
+		consumerName:    j.Consumer,
+		consumerBatch:   j.ConsumerBatch,
+		deDuplication:   j.Dedup,
+		retries:         j.Retries,
+		kv:              j.KV,
+		obj:             j.Obj,
+		bucketName:      j.Bucket,
+		watch:           j.Watch,
+		cas:             j.CAS,
+		casKeys:         j.Keys,
+		pubLatency:      newBenchLatency(),
+		subLatency:      newBenchLatency(),
+		casStats:        &benchCAS{},
+	}
+
+	if j.Rate != "" {
+		var err error
+		c.pubInterval, err = parseRate(j.Rate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// agentJob assigns every n'th publisher and subscriber, starting at agent, to the agent
+func (c *benchCmd) agentJob(id string, agent int, agents int) *benchAgentJob {
+	job := &benchAgentJob{
+		ID:               id,
+		StartSubject:     fmt.Sprintf("%s.%s.start", c.controlSubject, id),
+		ResultsSubject:   fmt.Sprintf("%s.%s.results", c.controlSubject, id),
+		HeartbeatSubject: fmt.Sprintf("%s.%s.heartbeat", c.controlSubject, id),
+		AbortSubject:     fmt.Sprintf("%s.%s.abort", c.controlSubject, id),
+		StartTimeout:     max(benchAgentStartTimeout, 2*c.agentTimeout),
+		Heartbeat:        c.agentTimeout / 4,
+		Subject:          c.subject,
+		Pubs:             c.numPubs,
+		Subs:             c.numSubs,
+		PubClients:       []int{},
+		SubClients:       []int{},
+		Msgs:             c.numMsg,
+		Size:             c.msgSize,
+		Rate:             c.rateString,
+		PubSleep:         c.pubSleep,
+		SubSleep:         c.subSleep,
+		Request:          c.request,
+		MultiSubject:     c.multiSubject,
+		MultiSubjectMax:  c.multiSubjectMax,
+		JS:               c.js,
+		Stream:           c.streamName,
+		SyncPub:          c.syncPub,
+		PubBatch:         c.pubBatch,
+		JSTimeout:        c.jsTimeout,
+		Pull:             c.pull,
+		Push:             c.pushDurable,
+		Consumer:         c.consumerName,
+		ConsumerBatch:    c.consumerBatch,
+		Dedup:            c.deDuplication,
+		Retries:          c.retries,
+		KV:               c.kv,
+		Obj:              c.obj,
+		Bucket:           c.bucketName,
+		Watch:            c.watch,
+		CAS:              c.cas,
+		Keys:             c.casKeys,
+	}
+
+	for i := agent; i < c.numPubs; i += agents {
+		job.PubClients = append(job.PubClients, i)
+	}
+	for i := agent; i < c.numSubs; i += agents {
+		job.SubClients = append(job.SubClients, i)
+	}
+
+	return job
+}
+
+func (c *benchCmd) discoverAgents() ([]*benchAgentInfo, error) {
+	nc, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	inbox := nc.NewRespInbox()
+	sub, err := nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	err = nc.PublishRequest(c.controlSubject+".discover", inbox, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []*benchAgentInfo
+	deadline := time.Now().Add(c.agentTimeout)
+
+	for len(agents) < c.numAgents && time.Now().Before(deadline) {
+		msg, err := sub.NextMsg(time.Until(deadline))
+		if errors.Is(err, nats.ErrTimeout) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		info := &benchAgentInfo{}
+		err = json.Unmarshal(msg.Data, info)
+		if err != nil {
+			log.Printf("Invalid agent discovery response: %v", err)
+			continue
+		}
+
+		if info.Busy {
+			log.Printf("Agent %s is busy, not using it", info.Name)
+			continue
+		}
+
+		agents = append(agents, info)
+	}
+
+	if len(agents) < c.numAgents {
+		return nil, fmt.Errorf("found %d of %d agents listening on %s", len(agents), c.numAgents, c.controlSubject)
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Name < agents[j].Name
+	})
+
+	var names []string
+	for _, agent := range agents {
+		names = append(names, agent.Name)
+	}
+	log.Printf("Distributing the benchmark over agents %s", strings.Join(names, ", "))
+
+	return agents, nil
+}
+
+// runAgentsRound prepares all agents, starts them at the same time and combines their results into bm
+func (c *benchCmd) runAgentsRound(bm *bench.Benchmark, agents []*benchAgentInfo) error {
+	nc, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	id := nuid.Next()
+	jobs := make([]*benchAgentJob, len(agents))
+	for i := range agents {
+		jobs[i] = c.agentJob(id, i, len(agents))
+	}
+
+	// agents still preparing or running the job are told to stop when the round does not complete
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+
+		err := nc.Publish(jobs[0].AbortSubject, nil)
+		if err == nil {
+			err = nc.Flush()
+		}
+		if err != nil {
+			log.Printf("Could not abort benchmark %s on the agents: %v", id, err)
+		}
+	}()
+
+	results := make(chan *nats.Msg, len(agents))
+	rsub, err := nc.ChanSubscribe(jobs[0].ResultsSubject, results)
+	if err != nil {
+		return err
+	}
+	defer rsub.Unsubscribe()
+
+	heartbeats := make(chan *nats.Msg, 100)
+	hsub, err := nc.ChanSubscribe(jobs[0].HeartbeatSubject, heartbeats)
+	if err != nil {
+		return err
+	}
+	defer hsub.Unsubscribe()
+
+	errs := make([]error, len(agents))
+	wg := &sync.WaitGroup{}
+	for i, agent := range agents {
+		wg.Add(1)
+		go func(i int, agent *benchAgentInfo) {
+			defer wg.Done()
+			errs[i] = c.prepareAgent(nc, agent, jobs[i])
+		}(i, agent)
+	}
+	wg.Wait()
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting benchmark %s on %d agents", id, len(agents))
+	err = nc.Publish(jobs[0].StartSubject, nil)
+	if err != nil {
+		return err
+	}
+
+	table := newTableWriter("Agents")
+	table.AddHeaders("Agent", "Publishers", "Subscribers", "Published", "Received")
+
+	// agents that did not report their results yet and when they were last heard from
+	pending := map[string]time.Time{}
+	names := map[string]string{}
+	for _, agent := range agents {
+		pending[agent.ID] = time.Now()
+		names[agent.ID] = agent.Name
+	}
+
+	pendingNames := func() string {
+		var list []string
+		for agent := range pending {
+			list = append(list, names[agent])
+		}
+		sort.Strings(list)
+		return strings.Join(list, ", ")
+	}
+
+	check := time.NewTicker(max(c.agentTimeout/2, time.Millisecond))
+	defer check.Stop()
+
+	for len(pending) > 0 {
+		var msg *nats.Msg
+
+		select {
+		case msg = <-results:
+		case hb := <-heartbeats:
+			if _, ok := pending[string(hb.Data)]; ok {
+				pending[string(hb.Data)] = time.Now()
+			}
+			continue
+		case <-check.C:
+			for agent, seen := range pending {
+				if time.Since(seen) > c.agentTimeout {
+					return fmt.Errorf("agent %s stopped responding, no results were received from %s", names[agent], pendingNames())
+				}
+			}
+			continue
+		case <-ctx.Done():
+			return fmt.Errorf("benchmark interrupted, no results were received from %s", pendingNames())
+		}
+
+		res := &benchAgentResult{}
+		err = json.Unmarshal(msg.Data, res)
+		if err != nil {
+			return fmt.Errorf("invalid agent result: %v", err)
+		}
+		if res.Error != "" {
+			return fmt.Errorf("agent %s failed: %s", res.Agent, res.Error)
+		}
+		if _, ok := pending[res.ID]; !ok {
+			log.Printf("Ignoring unexpected results from agent %s", res.Agent)
+			continue
+		}
+		delete(pending, res.ID)
+
+		published, received := 0, 0
+		for _, s := range res.Pubs {
+			bm.AddPubSample(s)
+			published += s.JobMsgCnt
+		}
+		for _, s := range res.Subs {
+			bm.AddSubSample(s)
+			received += s.JobMsgCnt
+		}
+
+		c.pubLatency.merge(importBenchHistogram(res.PubLatency))
+		c.subLatency.merge(importBenchHistogram(res.SubLatency))
+		c.casStats.add(res.CASAttempts, res.CASConflicts)
+		c.fetchTimeout = c.fetchTimeout || res.FetchTimeout
+		c.retriesUsed = c.retriesUsed || res.RetriesUsed
+
+		table.AddRow(res.Agent, f(len(res.Pubs)), f(len(res.Subs)), f(published), f(received))
+	}
+
+	completed = true
+	bm.Close()
+
+	fmt.Println()
+	fmt.Println(table.Render())
+
+	return nil
+}
+
+func (c *benchCmd) prepareAgent(nc *nats.Conn, agent *benchAgentInfo, job *benchAgentJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	msg, err := nc.Request(agent.Subject, data, c.agentTimeout)
+	if err != nil {
+		return fmt.Errorf("agent %s: %v", agent.Name, err)
+	}
+
+	reply := benchAgentReply{}
+	err = json.Unmarshal(msg.Data, &reply)
+	if err != nil {
+		return fmt.Errorf("agent %s: invalid reply: %v", agent.Name, err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("agent %s: %s", agent.Name, reply.Error)
+	}
+
+	return nil
+}
+
+func (l *benchLatency) snapshot() *hdrhistogram.Snapshot {
+	if l.count() == 0 {
+		return nil
+	}
+
+	return l.hist.Export()
+}
+
+func importBenchHistogram(s *hdrhistogram.Snapshot) *hdrhistogram.Histogram {
+	if s == nil {
+		return nil
+	}
+
+	return hdrhistogram.Import(s)
+}
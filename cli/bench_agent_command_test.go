@@ -0,0 +1,213 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func startBenchAgents(t *testing.T, srv *server.Server, count int) func() {
+	t.Helper()
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < count; i++ {
+		nc, err := nats.Connect(srv.ClientURL())
+		checkErr(t, err, "connect failed: %v", err)
+
+		agent := &benchAgentCmd{name: fmt.Sprintf("agent-%d", i), control: DefaultBenchControlSubject}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer nc.Close()
+
+			err := agent.run(runCtx, nc)
+			if err != nil {
+				t.Errorf("agent failed: %v", err)
+			}
+		}()
+	}
+
+	// waits for all agents to listen
+	discover := &benchCmd{numAgents: count, controlSubject: DefaultBenchControlSubject, agentTimeout: 100 * time.Millisecond}
+	defer discover.closeConnections()
+	for i := 0; i < 50; i++ {
+		_, err := discover.discoverAgents()
+		if err == nil {
+			break
+		}
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func TestBenchAgents(t *testing.T) {
+	withBenchContext(t, func(srv *server.Server, _ *nats.Conn, mgr *jsm.Manager) {
+		stop := startBenchAgents(t, srv, 3)
+		defer stop()
+
+		file := filepath.Join(t.TempDir(), "results.json")
+
+		cmd := &benchCmd{
+			subject:        "bench",
+			numPubs:        3,
+			numSubs:        4,
+			numMsg:         300,
+			msgSizeString:  "16",
+			noProgress:     true,
+			rateString:     "10000/s",
+			consumerName:   DefaultDurableConsumerName,
+			numAgents:      3,
+			controlSubject: DefaultBenchControlSubject,
+			agentTimeout:   time.Second,
+			resultsFile:    file,
+		}
+
+		err := cmd.bench(nil)
+		checkErr(t, err, "bench failed: %v", err)
+
+		res, err := loadBenchResult(file)
+		checkErr(t, err, "load failed: %v", err)
+
+		w := res.workload("NATS")
+		if w == nil || w.Publish == nil || w.Subscribe == nil {
+			t.Fatalf("invalid workload: %+v", w)
+		}
+		if w.Publish.Clients != 3 || w.Publish.Messages != 300 {
+			t.Fatalf("invalid publish results: %+v", w.Publish)
+		}
+		if w.Subscribe.Clients != 4 || w.Subscribe.Messages != 1200 {
+			t.Fatalf("invalid subscribe results: %+v", w.Subscribe)
+		}
+
+		// latencies recorded by the agents are combined
+		if cmd.subLatency.count() != 1200 {
+			t.Fatalf("expected 1200 latency samples got %d", cmd.subLatency.count())
+		}
+
+		// the work queue is shared by the pull consumers spread over the agents
+		js := newBenchStoreCmd()
+		js.js = true
+		js.pull = true
+		js.numPubs = 2
+		js.numSubs = 2
+		js.numMsg = 1000
+		js.msgSizeString = "16"
+		js.streamName = DefaultStreamName
+		js.consumerBatch = 100
+		js.pubBatch = 100
+		js.numAgents = 2
+		js.controlSubject = DefaultBenchControlSubject
+		js.agentTimeout = time.Second
+
+		err = js.bench(nil)
+		checkErr(t, err, "js bench failed: %v", err)
+
+		stream, err := mgr.LoadStream(DefaultStreamName)
+		checkErr(t, err, "stream load failed: %v", err)
+		nfo, err := stream.LatestInformation()
+		checkErr(t, err, "stream info failed: %v", err)
+		if nfo.State.Msgs != 1000 {
+			t.Fatalf("expected 1000 messages got %d", nfo.State.Msgs)
+		}
+
+		cmd = &benchCmd{subject: "bench", numPubs: 1, numMsg: 10, msgSizeString: "16", numAgents: 4, controlSubject: DefaultBenchControlSubject, agentTimeout: time.Second}
+		err = cmd.bench(nil)
+		if err == nil || err.Error() != "can not distribute 1 clients over 4 agents" {
+			t.Fatalf("expected agents error got %v", err)
+		}
+
+		cmd = &benchCmd{subject: "bench", numPubs: 4, numMsg: 10, msgSizeString: "16", numAgents: 4, controlSubject: DefaultBenchControlSubject, agentTimeout: 100 * time.Millisecond}
+		err = cmd.bench(nil)
+		if err == nil || err.Error() != "found 3 of 4 agents listening on natscli.bench.agents" {
+			t.Fatalf("expected discovery error got %v", err)
+		}
+	})
+}
+
+func TestBenchAgentsLiveness(t *testing.T) {
+	withBenchContext(t, func(srv *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		stop := startBenchAgents(t, srv, 1)
+		defer stop()
+
+		// an agent that accepts the job but never runs it
+		_, err := nc.Subscribe(DefaultBenchControlSubject+".discover", func(m *nats.Msg) {
+			m.Respond([]byte(`{"id":"lost","name":"lost","subject":"lost.agent"}`))
+		})
+		checkErr(t, err, "subscribe failed: %v", err)
+		_, err = nc.Subscribe("lost.agent", func(m *nats.Msg) {
+			m.Respond([]byte(`{}`))
+		})
+		checkErr(t, err, "subscribe failed: %v", err)
+
+		aborts := make(chan *nats.Msg, 1)
+		_, err = nc.ChanSubscribe(DefaultBenchControlSubject+".*.abort", aborts)
+		checkErr(t, err, "subscribe failed: %v", err)
+
+		// the subscriber on the working agent never receives the messages of the lost agent and only stops once aborted
+		cmd := &benchCmd{subject: "bench", numPubs: 2, numSubs: 2, numMsg: 10, msgSizeString: "16", noProgress: true, numAgents: 2, controlSubject: DefaultBenchControlSubject, agentTimeout: 200 * time.Millisecond}
+		err = cmd.bench(nil)
+		if err == nil || err.Error() != "agent lost stopped responding, no results were received from agent-0, lost" {
+			t.Fatalf("expected liveness error got %v", err)
+		}
+
+		select {
+		case <-aborts:
+		case <-time.After(time.Second):
+			t.Fatalf("the agents were not aborted")
+		}
+
+		job := cmd.agentJob("x", 0, 2)
+		if job.StartTimeout != benchAgentStartTimeout || job.Heartbeat != 50*time.Millisecond {
+			t.Fatalf("invalid timeouts: %v %v", job.StartTimeout, job.Heartbeat)
+		}
+	})
+}
+
+func TestBenchAgentJob(t *testing.T) {
+	c := &benchCmd{subject: "bench", numPubs: 5, numSubs: 2, numMsg: 100, msgSize: 16, controlSubject: DefaultBenchControlSubject}
+
+	job := c.agentJob("x", 1, 3)
+	if job.StartSubject != "natscli.bench.agents.x.start" || job.AbortSubject != "natscli.bench.agents.x.abort" {
+		t.Fatalf("invalid subjects %q %q", job.StartSubject, job.AbortSubject)
+	}
+	if fmt.Sprint(job.PubClients) != "[1 4]" || fmt.Sprint(job.SubClients) != "[1]" {
+		t.Fatalf("invalid clients: %v %v", job.PubClients, job.SubClients)
+	}
+
+	job = c.agentJob("x", 2, 3)
+	if fmt.Sprint(job.PubClients) != "[2]" || len(job.SubClients) != 0 || job.SubClients == nil {
+		t.Fatalf("invalid clients: %v %v", job.PubClients, job.SubClients)
+	}
+
+	cmd, err := job.benchCmd()
+	checkErr(t, err, "benchCmd failed: %v", err)
+	if cmd.numPubs != 5 || cmd.msgSize != 16 || cmd.subClients == nil || !cmd.noProgress {
+		t.Fatalf("invalid settings: %+v", cmd)
+	}
+}
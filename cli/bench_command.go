@@ -15,13 +15,18 @@ package cli
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/choria-io/fisk"
 	"github.com/dustin/go-humanize"
 	"github.com/gosuri/uiprogress"
@@ -57,6 +62,17 @@ type benchCmd struct {
 	pushDurable          bool
 	consumerName         string
 	kv                   bool
+	obj                  bool
+	objSizes             []int
+	watch                bool
+	cas                  bool
+	casKeys              int
+	casStats             *benchCAS
+	numAgents            int
+	controlSubject       string
+	agentTimeout         time.Duration
+	pubClients           []int
+	subClients           []int
 	bucketName           string
 	history              uint8
 	fetchTimeout         bool
@@ -66,6 +82,17 @@ type benchCmd struct {
 	deDuplicationWindow  time.Duration
 	retries              int
 	retriesUsed          bool
+	scenario             string
+	resultsFile          string
+	rateString           string
+	pubInterval          time.Duration
+	pubLatency           *benchLatency
+	subLatency           *benchLatency
+	defineConsumer       bool
+	stop                 chan struct{}
+	receivedTotal        *atomic.Uint64
+	misses               *atomic.Uint64
+	conns                []*nats.Conn
 }
 
 const (
@@ -108,46 +135,125 @@ JetStream KV put and get:
 
   nats bench benchsubject --kv --sub 10
 
+JetStream KV watchers receiving every put, measuring fan-out latency:
+
+  nats bench benchsubject --kv --watch --pub 1 --sub 10
+
+JetStream KV optimistic concurrency, publishers update a shared set of keys
+and retry when another client updated the key first:
+
+  nats bench benchsubject --kv --cas --keys 5 --pub 10
+
+JetStream Object Store put and get for a number of object sizes:
+
+  nats bench benchsubject --obj --pub 1 --msgs 100 --size 1KB,1MB,16MB
+
+  nats bench benchsubject --obj --sub 4 --msgs 100 --size 1KB,1MB,16MB
+
+Open-loop publishing at a fixed rate with latency percentiles:
+
+  nats bench benchsubject --pub 1 --sub 1 --rate 10000/s
+
+  Publishers send messages at the given rate even when the server slows
+  down, latencies are measured from the time a message was scheduled to be
+  sent rather than when it was sent, avoiding coordinated omission.
+  Subscribers record end to end latency using the timestamp embedded in the
+  message, publishers record the round trip time of requests, synchronous
+  JetStream publishes and KV puts.
+
+Mixed workloads described in a scenario file:
+
+  nats bench --scenario load.yaml
+
+  name: orders
+  timeout: 10s            # stop subscribers once no messages arrived for
+                          # this long after all publishers completed
+  workloads:
+    - name: publishers
+      type: js-pub        # pub, sub, request, reply, js-pub, js-ordered,
+      clients: 5          # js-pull, js-push, kv-put, kv-get, kv-watch,
+                          # kv-update, obj-put or obj-get
+      subject: orders
+      multisubject: true
+      rate: 2000/s        # per client
+    - name: processors
+      type: js-pull
+      clients: 10
+      subject: orders
+      multisubject: true
+
+  Workloads run concurrently, settings not in the scenario such as msgs,
+  size, storage or replicas are taken from the command line flags. Reply
+  workloads run until all other workloads completed, subscribers expecting
+  more messages than were published are stopped after the timeout.
+
+Distributing the clients over agents running on other hosts:
+
+  nats bench agent
+
+  nats bench benchsubject --pub 8 --sub 8 --agents 4
+
+Comparing the results of two benchmarks:
+
+  nats bench benchsubject --pub 1 --sub 1 --results new.json
+
+  nats bench compare base.json new.json
+
 Remember to use --no-progress to measure performance more accurately
 `
-	bench := app.Command("bench", "Benchmark utility").Action(c.bench)
+	bench := app.Command("bench", "Benchmark utility")
 	if !opts.NoCheats {
 		bench.CheatFile(fs, "bench", "cheats/bench.md")
 	}
 	bench.HelpLong(benchHelp)
-	bench.Arg("subject", "Subject to use for the benchmark").Required().StringVar(&c.subject)
-	bench.Flag("pub", "Number of concurrent publishers").Default("0").IntVar(&c.numPubs)
-	bench.Flag("sub", "Number of concurrent subscribers").Default("0").IntVar(&c.numSubs)
-	bench.Flag("js", "Use JetStream").UnNegatableBoolVar(&c.js)
-	bench.Flag("request", "Request-Reply mode: publishers send requests waits for a reply").UnNegatableBoolVar(&c.request)
-	bench.Flag("reply", "Request-Reply mode: subscribers send replies").UnNegatableBoolVar(&c.reply)
-	bench.Flag("kv", "KV mode, subscribers get from the bucket and publishers put in the bucket").UnNegatableBoolVar(&c.kv)
-	bench.Flag("msgs", "Number of messages to publish").Default("100000").IntVar(&c.numMsg)
-	bench.Flag("size", "Size of the test messages").Default("128").StringVar(&c.msgSizeString)
-	bench.Flag("no-progress", "Disable progress bar while publishing").UnNegatableBoolVar(&c.noProgress)
-	bench.Flag("csv", "Save benchmark data to CSV file").StringVar(&c.csvFile)
-	bench.Flag("purge", "Purge the stream before running").UnNegatableBoolVar(&c.purge)
-	bench.Flag("storage", "JetStream storage (memory/file) for the \"benchstream\" stream").Default("file").EnumVar(&c.storage, "memory", "file")
-	bench.Flag("replicas", "Number of stream replicas for the \"benchstream\" stream").Default("1").IntVar(&c.replicas)
-	bench.Flag("maxbytes", "The maximum size of the stream or KV bucket in bytes").Default("1GB").StringVar(&c.streamMaxBytesString)
-	bench.Flag("stream", "When set to something else than \"benchstream\": use (and do not attempt to define) the specified stream when creating durable subscribers. Otherwise define and use the \"benchstream\" stream").Default(DefaultStreamName).StringVar(&c.streamName)
-	bench.Flag("bucket", "When set to something else than \"benchbucket\": use (and do not attempt to define) the specified bucket when in KV mode. Otherwise define and use the \"benchbucket\" bucket").Default(DefaultBucketName).StringVar(&c.bucketName)
-	bench.Flag("consumer", "Specify the durable consumer name to use").Default(DefaultDurableConsumerName).StringVar(&c.consumerName)
-	bench.Flag("jstimeout", "Timeout for JS operations").Default("30s").DurationVar(&c.jsTimeout)
-	bench.Flag("syncpub", "Synchronously publish to the stream").UnNegatableBoolVar(&c.syncPub)
-	bench.Flag("pubbatch", "Sets the batch size for JS asynchronous publishing").Default("100").IntVar(&c.pubBatch)
-	bench.Flag("pull", "Use a shared durable explicitly acknowledged JS pull consumer rather than individual ephemeral consumers").UnNegatableBoolVar(&c.pull)
-	bench.Flag("push", "Use a shared durable explicitly acknowledged JS push consumer with a queue group rather than individual ephemeral consumers").UnNegatableBoolVar(&c.pushDurable)
-	bench.Flag("consumerbatch", "Sets the batch size for the JS durable pull consumer, or the max ack pending value for the JS durable push consumer").Default("100").IntVar(&c.consumerBatch)
-	bench.Flag("pullbatch", "Sets the batch size for the JS durable pull consumer, or the max ack pending value for the JS durable push consumer").Hidden().Default("100").IntVar(&c.consumerBatch)
-	bench.Flag("subsleep", "Sleep for the specified interval before sending the subscriber acknowledgement back in --js mode, or sending the reply back in --reply mode,  or doing the next get in --kv mode").Default("0s").DurationVar(&c.subSleep)
-	bench.Flag("pubsleep", "Sleep for the specified interval after publishing each message").Default("0s").DurationVar(&c.pubSleep)
-	bench.Flag("history", "History depth for the bucket in KV mode").Default("1").Uint8Var(&c.history)
-	bench.Flag("multisubject", "Multi-subject mode, each message is published on a subject that includes the publisher's message sequence number as a token").UnNegatableBoolVar(&c.multiSubject)
-	bench.Flag("multisubjectmax", "The maximum number of subjects to use in multi-subject mode (0 means no max)").Default("100000").IntVar(&c.multiSubjectMax)
-	bench.Flag("retries", "The maximum number of retries in JS operations").Default("3").IntVar(&c.retries)
-	bench.Flag("dedup", "Sets a message id in the header to use JS Publish de-duplication").Default("false").UnNegatableBoolVar(&c.deDuplication)
-	bench.Flag("dedupwindow", "Sets the duration of the stream's deduplication functionality").Default("2m").DurationVar(&c.deDuplicationWindow)
+
+	run := bench.Command("run", "Runs a benchmark, the default when no command is given").Default().Action(c.bench)
+	run.Arg("subject", "Subject to use for the benchmark").StringVar(&c.subject)
+	run.Flag("pub", "Number of concurrent publishers").Default("0").IntVar(&c.numPubs)
+	run.Flag("sub", "Number of concurrent subscribers").Default("0").IntVar(&c.numSubs)
+	run.Flag("js", "Use JetStream").UnNegatableBoolVar(&c.js)
+	run.Flag("request", "Request-Reply mode: publishers send requests waits for a reply").UnNegatableBoolVar(&c.request)
+	run.Flag("reply", "Request-Reply mode: subscribers send replies").UnNegatableBoolVar(&c.reply)
+	run.Flag("kv", "KV mode, subscribers get from the bucket and publishers put in the bucket").UnNegatableBoolVar(&c.kv)
+	run.Flag("watch", "KV watch mode, subscribers watch the bucket and receive every put").UnNegatableBoolVar(&c.watch)
+	run.Flag("cas", "KV compare-and-set mode, publishers update a shared set of keys and retry on conflicts").UnNegatableBoolVar(&c.cas)
+	run.Flag("keys", "The number of keys updated in --cas mode").Default("10").IntVar(&c.casKeys)
+	run.Flag("obj", "Object Store mode, subscribers get objects from the bucket and publishers put objects in the bucket").UnNegatableBoolVar(&c.obj)
+	run.Flag("msgs", "Number of messages to publish").Default("100000").IntVar(&c.numMsg)
+	run.Flag("size", "Size of the test messages, a comma separated list of object sizes in --obj mode").Default("128").StringVar(&c.msgSizeString)
+	run.Flag("no-progress", "Disable progress bar while publishing").UnNegatableBoolVar(&c.noProgress)
+	run.Flag("csv", "Save benchmark data to CSV file").StringVar(&c.csvFile)
+	run.Flag("results", "Save the results as JSON for use with nats bench compare").PlaceHolder("FILE").StringVar(&c.resultsFile)
+	run.Flag("purge", "Purge the stream before running").UnNegatableBoolVar(&c.purge)
+	run.Flag("storage", "JetStream storage (memory/file) for the \"benchstream\" stream").Default("file").EnumVar(&c.storage, "memory", "file")
+	run.Flag("replicas", "Number of stream replicas for the \"benchstream\" stream").Default("1").IntVar(&c.replicas)
+	run.Flag("maxbytes", "The maximum size of the stream or KV bucket in bytes").Default("1GB").StringVar(&c.streamMaxBytesString)
+	run.Flag("stream", "When set to something else than \"benchstream\": use (and do not attempt to define) the specified stream when creating durable subscribers. Otherwise define and use the \"benchstream\" stream").Default(DefaultStreamName).StringVar(&c.streamName)
+	run.Flag("bucket", "When set to something else than \"benchbucket\": use (and do not attempt to define) the specified bucket when in KV mode. Otherwise define and use the \"benchbucket\" bucket").Default(DefaultBucketName).StringVar(&c.bucketName)
+	run.Flag("consumer", "Specify the durable consumer name to use").Default(DefaultDurableConsumerName).StringVar(&c.consumerName)
+	run.Flag("jstimeout", "Timeout for JS operations").Default("30s").DurationVar(&c.jsTimeout)
+	run.Flag("syncpub", "Synchronously publish to the stream").UnNegatableBoolVar(&c.syncPub)
+	run.Flag("pubbatch", "Sets the batch size for JS asynchronous publishing").Default("100").IntVar(&c.pubBatch)
+	run.Flag("pull", "Use a shared durable explicitly acknowledged JS pull consumer rather than individual ephemeral consumers").UnNegatableBoolVar(&c.pull)
+	run.Flag("push", "Use a shared durable explicitly acknowledged JS push consumer with a queue group rather than individual ephemeral consumers").UnNegatableBoolVar(&c.pushDurable)
+	run.Flag("consumerbatch", "Sets the batch size for the JS durable pull consumer, or the max ack pending value for the JS durable push consumer").Default("100").IntVar(&c.consumerBatch)
+	run.Flag("pullbatch", "Sets the batch size for the JS durable pull consumer, or the max ack pending value for the JS durable push consumer").Hidden().Default("100").IntVar(&c.consumerBatch)
+	run.Flag("subsleep", "Sleep for the specified interval before sending the subscriber acknowledgement back in --js mode, or sending the reply back in --reply mode,  or doing the next get in --kv mode").Default("0s").DurationVar(&c.subSleep)
+	run.Flag("pubsleep", "Sleep for the specified interval after publishing each message").Default("0s").DurationVar(&c.pubSleep)
+	run.Flag("rate", "Publish at a fixed rate per publisher, like 1000/s, and report latency percentiles").PlaceHolder("RATE").StringVar(&c.rateString)
+	run.Flag("history", "History depth for the bucket in KV mode").Default("1").Uint8Var(&c.history)
+	run.Flag("multisubject", "Multi-subject mode, each message is published on a subject that includes the publisher's message sequence number as a token").UnNegatableBoolVar(&c.multiSubject)
+	run.Flag("multisubjectmax", "The maximum number of subjects to use in multi-subject mode (0 means no max)").Default("100000").IntVar(&c.multiSubjectMax)
+	run.Flag("retries", "The maximum number of retries in JS operations").Default("3").IntVar(&c.retries)
+	run.Flag("dedup", "Sets a message id in the header to use JS Publish de-duplication").Default("false").UnNegatableBoolVar(&c.deDuplication)
+	run.Flag("dedupwindow", "Sets the duration of the stream's deduplication functionality").Default("2m").DurationVar(&c.deDuplicationWindow)
+	run.Flag("scenario", "Runs the workloads described in a scenario file concurrently").PlaceHolder("FILE").ExistingFileVar(&c.scenario)
+	run.Flag("agents", "Distributes the publishers and subscribers over a number of nats bench agent processes").Default("0").IntVar(&c.numAgents)
+	run.Flag("control", "The subject nats bench agent processes listen on").Default(DefaultBenchControlSubject).StringVar(&c.controlSubject)
+	run.Flag("agent-timeout", "How long to wait for agents to be discovered, to prepare their clients and between their heartbeats while running").Default("10s").DurationVar(&c.agentTimeout)
+
+	configureBenchCompareCommand(bench)
+	configureBenchAgentCommand(bench)
 }
 
 func init() {
@@ -155,15 +261,215 @@ func init() {
 }
 
 func (c *benchCmd) bench(_ *fisk.ParseContext) error {
+	if c.scenario != "" {
+		if c.numAgents > 0 {
+			return fmt.Errorf("scenarios can not be distributed over agents")
+		}
+		return c.runScenario()
+	}
+
+	if c.subject == "" {
+		return fmt.Errorf("a subject is required unless a scenario is given")
+	}
+
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+
+	c.defineConsumer = c.consumerName == DefaultDurableConsumerName
+
+	// Print the banner to repeat the arguments being used
+	if c.js {
+		if c.streamName == DefaultStreamName {
+			log.Printf("Starting JetStream benchmark [subject=%s, multisubject=%v, multisubjectmax=%d, js=%v, msgs=%s, msgsize=%s, pubs=%d, subs=%d, stream=%s, maxbytes=%s, storage=%s, syncpub=%v, pubbatch=%s, jstimeout=%v, pull=%v, consumerbatch=%s, push=%v, consumername=%s, replicas=%d, purge=%v, pubsleep=%v, subsleep=%v, dedup=%v, dedupwindow=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, c.js, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.streamName, humanize.IBytes(uint64(c.streamMaxBytes)), c.storage, c.syncPub, f(c.pubBatch), c.jsTimeout, c.pull, f(c.consumerBatch), c.pushDurable, c.consumerName, c.replicas, c.purge, c.pubSleep, c.subSleep, c.deDuplication, c.deDuplicationWindow)
+		} else {
+			log.Printf("Starting JetStream benchmark [subject=%s,  multisubject=%v, multisubjectmax=%d, js=%v, msgs=%s, msgsize=%s, pubs=%d, subs=%d, stream=%s, maxbytes=%s, syncpub=%v, pubbatch=%s, jstimeout=%v, pull=%v, consumerbatch=%s, push=%v, consumername=%s, purge=%v, pubsleep=%v, subsleep=%v, deduplication=%v, dedupwindow=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, c.js, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.streamName, humanize.IBytes(uint64(c.streamMaxBytes)), c.syncPub, f(c.pubBatch), c.jsTimeout, c.pull, f(c.consumerBatch), c.pushDurable, c.consumerName, c.purge, c.pubSleep, c.subSleep, c.deDuplication, c.deDuplicationWindow)
+		}
+	} else if c.obj {
+		log.Printf("Starting Object Store benchmark [bucket=%s, obj=%v, msgs=%s, sizes=%s, maxbytes=%s, pubs=%d, sub=%d, storage=%s, replicas=%d, pubsleep=%v, subsleep=%v]", c.bucketName, c.obj, f(c.numMsg), benchSizes(c.objSizes), humanize.IBytes(uint64(c.streamMaxBytes)), c.numPubs, c.numSubs, c.storage, c.replicas, c.pubSleep, c.subSleep)
+	} else if c.kv && (c.watch || c.cas) {
+		log.Printf("Starting KV benchmark [bucket=%s, kv=%v, watch=%v, cas=%v, keys=%d, msgs=%s, msgsize=%s, maxbytes=%s, pubs=%d, sub=%d, storage=%s, replicas=%d, pubsleep=%v]", c.bucketName, c.kv, c.watch, c.cas, c.casKeys, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), humanize.IBytes(uint64(c.streamMaxBytes)), c.numPubs, c.numSubs, c.storage, c.replicas, c.pubSleep)
+	} else if c.kv {
+		log.Printf("Starting KV benchmark [bucket=%s, kv=%v, msgs=%s, msgsize=%s, maxbytes=%s, pubs=%d, sub=%d, storage=%s, replicas=%d, pubsleep=%v, subsleep=%v]", c.bucketName, c.kv, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), humanize.IBytes(uint64(c.streamMaxBytes)), c.numPubs, c.numSubs, c.storage, c.replicas, c.pubSleep, c.subSleep)
+	} else {
+		if c.request || c.reply {
+			log.Printf("Starting request-reply benchmark [subject=%s, multisubject=%v, multisubjectmax=%d, request=%v, reply=%v, msgs=%s, msgsize=%s, pubs=%d, subs=%d, pubsleep=%v, subsleep=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, c.request, c.reply, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.pubSleep, c.subSleep)
+		} else {
+			log.Printf("Starting Core NATS pub/sub benchmark [subject=%s, multisubject=%v, multisubjectmax=%d, msgs=%s, msgsize=%s, pubs=%d, subs=%d, pubsleep=%v, subsleep=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.pubSleep, c.subSleep)
+		}
+	}
+
+	benchId := strconv.FormatInt(time.Now().UnixMilli(), 16)
+
+	if c.js || c.kv || c.obj {
+		defer c.prepareJetStream()()
+	}
+
+	defer c.closeConnections()
+
+	var agents []*benchAgentInfo
+	if c.numAgents > 0 {
+		agents, err = c.discoverAgents()
+		if err != nil {
+			return err
+		}
+	}
+
+	// object store benchmarks are repeated for every object size
+	sizes := []int{c.msgSize}
+	if c.obj {
+		sizes = c.objSizes
+	}
+
+	var rounds []*bench.Benchmark
+	var workloads []*benchResultWorkload
+	var csv string
+
+	for _, size := range sizes {
+		c.msgSize = size
+		c.pubLatency, c.subLatency, c.casStats = newBenchLatency(), newBenchLatency(), &benchCAS{}
+		c.misses = &atomic.Uint64{}
+
+		name := "NATS"
+		if len(sizes) > 1 {
+			name = fmt.Sprintf("NATS %s", humanize.IBytes(uint64(size)))
+		}
+
+		bm := bench.NewBenchmark(name, c.numSubs, c.numPubs)
+		if agents != nil {
+			err = c.runAgentsRound(bm, agents)
+		} else {
+			err = c.runRound(bm, benchId)
+		}
+		if err != nil {
+			return err
+		}
+
+		c.printWarnings()
+
+		fmt.Println()
+		if len(sizes) > 1 {
+			fmt.Printf("Object size %s\n", humanize.IBytes(uint64(size)))
+		}
+		fmt.Println(bm.Report())
+		renderBenchLatency(c.pubLatencyLabel(), c.pubLatency, c.subLatency)
+		c.casStats.render()
+
+		rounds = append(rounds, bm)
+		workloads = append(workloads, c.resultWorkload(name, c.modeName(), c.numPubs+c.numSubs, bm))
+		csv += bm.CSV()
+	}
+
+	if len(rounds) > 1 {
+		renderBenchObjectSizes(sizes, rounds)
+	}
+
+	if c.csvFile != "" {
+		err := os.WriteFile(c.csvFile, []byte(csv), 0644)
+		if err != nil {
+			log.Printf("error writing file %s: %v", c.csvFile, err)
+		}
+		fmt.Printf("Saved metric data in csv file %s\n", c.csvFile)
+	}
+
+	if c.resultsFile != "" {
+		res := newBenchResult("NATS", c.servers())
+		res.Workloads = workloads
+
+		err = res.save(c.resultsFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved results in %s\n", c.resultsFile)
+	}
+
+	return nil
+}
+
+// runRound runs the subscribers and publishers once and waits for them to complete
+func (c *benchCmd) runRound(bm *bench.Benchmark, benchId string) error {
+	startwg := &sync.WaitGroup{}
+	donewg := &sync.WaitGroup{}
+
+	err := c.startSubscribers(bm, startwg, donewg)
+	if err != nil {
+		return err
+	}
+	startwg.Wait()
+
+	trigger := make(chan struct{})
+	err = c.startPublishers(bm, startwg, donewg, trigger, benchId)
+	if err != nil {
+		return err
+	}
+
+	if !c.noProgress {
+		uiprogress.Start()
+	}
+
+	startwg.Wait()
+	close(trigger)
+	donewg.Wait()
+
+	bm.Close()
+
+	if !c.noProgress {
+		uiprogress.Stop()
+	}
+
+	return nil
+}
+
+func (c *benchCmd) validate() error {
 	// first check the sanity of the arguments
 	if c.numMsg <= 0 {
 		return fmt.Errorf("number of messages should be greater than 0")
 	}
-	msgSize, err := parseStringAsBytes(c.msgSizeString)
-	if err != nil || msgSize <= 0 {
-		log.Fatal("Can not parse or invalid the value specified for the message size: %s", c.msgSizeString)
+	c.objSizes = nil
+	for _, s := range strings.Split(c.msgSizeString, ",") {
+		msgSize, err := parseStringAsBytes(strings.TrimSpace(s))
+		if err != nil || msgSize <= 0 {
+			log.Fatalf("Can not parse or invalid the value specified for the message size: %s", c.msgSizeString)
+		}
+		c.objSizes = append(c.objSizes, int(msgSize))
+	}
+	if len(c.objSizes) > 1 && !c.obj {
+		return fmt.Errorf("multiple sizes can only be given in --obj mode")
+	}
+	c.msgSize = c.objSizes[0]
+
+	err := c.validateModes()
+	if err != nil {
+		return err
+	}
+	if c.numAgents < 0 {
+		return fmt.Errorf("the number of agents can not be negative")
+	}
+	if c.numAgents > 0 && c.reply {
+		return fmt.Errorf("repliers run until interrupted and can not be distributed over agents")
+	}
+	if c.numAgents > c.numPubs+c.numSubs {
+		return fmt.Errorf("can not distribute %d clients over %d agents", c.numPubs+c.numSubs, c.numAgents)
+	}
+	if c.numAgents > 0 && c.agentTimeout <= 0 {
+		return fmt.Errorf("the agent timeout has to be positive")
+	}
+	if c.rateString != "" {
+		if c.pubSleep > 0 {
+			return fmt.Errorf("--rate and --pubsleep can not be used together")
+		}
+		if c.msgSize < 8 {
+			return fmt.Errorf("the message size must be at least 8 bytes to embed timestamps when using --rate")
+		}
+		c.pubInterval, err = parseRate(c.rateString)
+		if err != nil {
+			return err
+		}
+		if c.numPubs > 0 {
+			log.Printf("Open-loop mode, publishers publish at a fixed rate of %s each and record latency", c.rateString)
+		}
 	}
-	c.msgSize = int(msgSize)
 	if c.js && c.numSubs > 0 && c.pull {
 		log.Print("JetStream durable pull consumer mode, subscriber(s) will explicitly acknowledge the consumption of messages")
 	}
@@ -202,7 +508,7 @@ func (c *benchCmd) bench(_ *fisk.ParseContext) error {
 		log.Print("KV mode, using the subject name as the KV bucket name. Publishers do puts, subscribers do gets")
 	}
 
-	if c.js || c.kv {
+	if c.js || c.kv || c.obj {
 		size, err := parseStringAsBytes(c.streamMaxBytesString)
 
 		if err != nil || size <= 0 {
@@ -211,31 +517,13 @@ func (c *benchCmd) bench(_ *fisk.ParseContext) error {
 		c.streamMaxBytes = size
 	}
 
-	// Print the banner to repeat the arguments being used
-	if c.js {
-		if c.streamName == DefaultStreamName {
-			log.Printf("Starting JetStream benchmark [subject=%s, multisubject=%v, multisubjectmax=%d, js=%v, msgs=%s, msgsize=%s, pubs=%d, subs=%d, stream=%s, maxbytes=%s, storage=%s, syncpub=%v, pubbatch=%s, jstimeout=%v, pull=%v, consumerbatch=%s, push=%v, consumername=%s, replicas=%d, purge=%v, pubsleep=%v, subsleep=%v, dedup=%v, dedupwindow=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, c.js, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.streamName, humanize.IBytes(uint64(c.streamMaxBytes)), c.storage, c.syncPub, f(c.pubBatch), c.jsTimeout, c.pull, f(c.consumerBatch), c.pushDurable, c.consumerName, c.replicas, c.purge, c.pubSleep, c.subSleep, c.deDuplication, c.deDuplicationWindow)
-		} else {
-			log.Printf("Starting JetStream benchmark [subject=%s,  multisubject=%v, multisubjectmax=%d, js=%v, msgs=%s, msgsize=%s, pubs=%d, subs=%d, stream=%s, maxbytes=%s, syncpub=%v, pubbatch=%s, jstimeout=%v, pull=%v, consumerbatch=%s, push=%v, consumername=%s, purge=%v, pubsleep=%v, subsleep=%v, deduplication=%v, dedupwindow=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, c.js, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.streamName, humanize.IBytes(uint64(c.streamMaxBytes)), c.syncPub, f(c.pubBatch), c.jsTimeout, c.pull, f(c.consumerBatch), c.pushDurable, c.consumerName, c.purge, c.pubSleep, c.subSleep, c.deDuplication, c.deDuplicationWindow)
-		}
-	} else if c.kv {
-		log.Printf("Starting KV benchmark [bucket=%s, kv=%v, msgs=%s, msgsize=%s, maxbytes=%s, pubs=%d, sub=%d, storage=%s, replicas=%d, pubsleep=%v, subsleep=%v]", c.bucketName, c.kv, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), humanize.IBytes(uint64(c.streamMaxBytes)), c.numPubs, c.numSubs, c.storage, c.replicas, c.pubSleep, c.subSleep)
-	} else {
-		if c.request || c.reply {
-			log.Printf("Starting request-reply benchmark [subject=%s, multisubject=%v, multisubjectmax=%d, request=%v, reply=%v, msgs=%s, msgsize=%s, pubs=%d, subs=%d, pubsleep=%v, subsleep=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, c.request, c.reply, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.pubSleep, c.subSleep)
-		} else {
-			log.Printf("Starting Core NATS pub/sub benchmark [subject=%s, multisubject=%v, multisubjectmax=%d, msgs=%s, msgsize=%s, pubs=%d, subs=%d, pubsleep=%v, subsleep=%v]", getSubscribeSubject(c), c.multiSubject, c.multiSubjectMax, f(c.numMsg), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs, c.pubSleep, c.subSleep)
-		}
-	}
-
-	bm := bench.NewBenchmark("NATS", c.numSubs, c.numPubs)
-
-	benchId := strconv.FormatInt(time.Now().UnixMilli(), 16)
-
-	startwg := &sync.WaitGroup{}
-	donewg := &sync.WaitGroup{}
+	return nil
+}
 
+// prepareJetStream creates the stream, bucket and durable consumers used by the benchmark, the returned function deletes the consumers again
+func (c *benchCmd) prepareJetStream() func() {
 	var js nats.JetStreamContext
+	var cleanup []func()
 
 	storageType := func() nats.StorageType {
 		switch c.storage {
@@ -251,170 +539,238 @@ func (c *benchCmd) bench(_ *fisk.ParseContext) error {
 		}
 	}()
 
-	if c.js || c.kv {
-		// create the stream for the benchmark (and purge it)
-		nc, err := nats.Connect(opts.Config.ServerURL(), natsOpts()...)
-		if err != nil {
-			log.Fatalf("NATS connection failed: %v", err)
-		}
+	// create the stream for the benchmark (and purge it)
+	nc, err := nats.Connect(opts.Config.ServerURL(), natsOpts()...)
+	if err != nil {
+		log.Fatalf("NATS connection failed: %v", err)
+	}
 
-		js, err = nc.JetStream(append(jsOpts(), nats.MaxWait(c.jsTimeout))...)
-		if err != nil {
-			log.Fatalf("Couldn't get the JetStream context: %v", err)
+	js, err = nc.JetStream(append(jsOpts(), nats.MaxWait(c.jsTimeout))...)
+	if err != nil {
+		log.Fatalf("Couldn't get the JetStream context: %v", err)
+	}
+	if c.kv {
+
+		// There is no way to purge all the keys in a KV bucket in a single operation so deleting the bucket instead
+		if c.purge {
+			err = js.PurgeStream("KV_" + c.bucketName)
+			// err = js.DeleteKeyValue(c.subject)
+			if err != nil {
+				log.Fatalf("Error trying to purge the bucket: %v", err)
+			}
 		}
-		if c.kv {
 
-			// There is no way to purge all the keys in a KV bucket in a single operation so deleting the bucket instead
-			if c.purge {
-				err = js.PurgeStream("KV_" + c.bucketName)
-				// err = js.DeleteKeyValue(c.subject)
-				if err != nil {
-					log.Fatalf("Error trying to purge the bucket: %v", err)
-				}
+		if c.bucketName == DefaultBucketName {
+			// create bucket
+			_, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: c.bucketName, History: c.history, Storage: storageType, Description: "nats bench bucket", Replicas: c.replicas, MaxBytes: c.streamMaxBytes})
+			if err != nil {
+				log.Fatalf("Couldn't create the KV bucket: %v", err)
+			}
+		}
+	} else if c.obj {
+		if c.purge {
+			err = js.PurgeStream("OBJ_" + c.bucketName)
+			if err != nil {
+				log.Fatalf("Error trying to purge the object store: %v", err)
 			}
+		}
 
-			if c.bucketName == DefaultBucketName {
-				// create bucket
-				_, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: c.bucketName, History: c.history, Storage: storageType, Description: "nats bench bucket", Replicas: c.replicas, MaxBytes: c.streamMaxBytes})
-				if err != nil {
-					log.Fatalf("Couldn't create the KV bucket: %v", err)
-				}
+		if c.bucketName == DefaultBucketName {
+			_, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: c.bucketName, Storage: storageType, Description: "nats bench object store", Replicas: c.replicas, MaxBytes: c.streamMaxBytes})
+			if err != nil {
+				log.Fatalf("Couldn't create the object store: %v", err)
 			}
-		} else if c.js {
-			if c.streamName == DefaultStreamName {
-				// create the stream with our attributes, will create it if it doesn't exist or make sure the existing one has the same attributes
-				_, err = js.AddStream(&nats.StreamConfig{Name: c.streamName, Subjects: []string{getSubscribeSubject(c)}, Retention: nats.LimitsPolicy, Discard: nats.DiscardNew, Storage: storageType, Replicas: c.replicas, MaxBytes: c.streamMaxBytes, Duplicates: c.deDuplicationWindow})
-				if err != nil {
-					log.Fatalf("%v. If you want to delete and re-define the stream use `nats stream delete %s`.", err, c.streamName)
-				}
-			} else if (c.pull || c.pushDurable) && c.numSubs > 0 {
-				log.Printf("Using stream: %s", c.streamName)
+		}
+	} else if c.js {
+		if c.streamName == DefaultStreamName {
+			// create the stream with our attributes, will create it if it doesn't exist or make sure the existing one has the same attributes
+			_, err = js.AddStream(&nats.StreamConfig{Name: c.streamName, Subjects: []string{getSubscribeSubject(c)}, Retention: nats.LimitsPolicy, Discard: nats.DiscardNew, Storage: storageType, Replicas: c.replicas, MaxBytes: c.streamMaxBytes, Duplicates: c.deDuplicationWindow})
+			if err != nil {
+				log.Fatalf("%v. If you want to delete and re-define the stream use `nats stream delete %s`.", err, c.streamName)
 			}
+		} else if (c.pull || c.pushDurable) && c.numSubs > 0 {
+			log.Printf("Using stream: %s", c.streamName)
+		}
 
-			if c.purge {
-				log.Printf("Purging the stream")
-				err = js.PurgeStream(c.streamName)
-				if err != nil {
-					log.Fatalf("Error purging stream %s: %v", c.streamName, err)
-				}
+		if c.purge {
+			log.Printf("Purging the stream")
+			err = js.PurgeStream(c.streamName)
+			if err != nil {
+				log.Fatalf("Error purging stream %s: %v", c.streamName, err)
 			}
+		}
 
-			// create the pull consumer
-			if c.numSubs > 0 {
-				if c.pull && c.consumerName == DefaultDurableConsumerName {
-					_, err = js.AddConsumer(c.streamName, &nats.ConsumerConfig{
-						Durable:       c.consumerName,
-						DeliverPolicy: nats.DeliverAllPolicy,
-						AckPolicy:     nats.AckExplicitPolicy,
-						ReplayPolicy:  nats.ReplayInstantPolicy,
-						MaxAckPending: func(a int) int {
-							if a >= 10000 {
-								return a
-							} else {
-								return 10000
-							}
-						}(c.numSubs * c.consumerBatch),
-					})
+		// create the pull consumer
+		if c.numSubs > 0 {
+			if c.pull && c.defineConsumer {
+				_, err = js.AddConsumer(c.streamName, &nats.ConsumerConfig{
+					Durable:       c.consumerName,
+					DeliverPolicy: nats.DeliverAllPolicy,
+					AckPolicy:     nats.AckExplicitPolicy,
+					ReplayPolicy:  nats.ReplayInstantPolicy,
+					MaxAckPending: func(a int) int {
+						if a >= 10000 {
+							return a
+						} else {
+							return 10000
+						}
+					}(c.numSubs * c.consumerBatch),
+				})
+				if err != nil {
+					log.Fatalf("Error creating the pull consumer: %v", err)
+				}
+				cleanup = append(cleanup, func() {
+					err := js.DeleteConsumer(c.streamName, c.consumerName)
 					if err != nil {
-						log.Fatalf("Error creating the pull consumer: %v", err)
+						log.Printf("Error deleting the pull consumer on stream %s: %v", c.streamName, err)
 					}
-					defer func() {
-						err := js.DeleteConsumer(c.streamName, c.consumerName)
-						if err != nil {
-							log.Printf("Error deleting the pull consumer on stream %s: %v", c.streamName, err)
-						}
-						log.Printf("Deleted durable consumer: %s\n", c.consumerName)
-					}()
-					log.Printf("Defined durable explicitly acked pull consumer: %s\n", c.consumerName)
-				} else if c.pushDurable && c.consumerName == DefaultDurableConsumerName {
-					_, err = js.AddConsumer(c.streamName, &nats.ConsumerConfig{
-						Durable:        c.consumerName,
-						DeliverSubject: c.consumerName + "-DELIVERY",
-						DeliverGroup:   c.consumerName + "-GROUP",
-						DeliverPolicy:  nats.DeliverAllPolicy,
-						AckPolicy:      nats.AckExplicitPolicy,
-						ReplayPolicy:   nats.ReplayInstantPolicy,
-						MaxAckPending:  c.consumerBatch * c.numSubs,
-					})
+					log.Printf("Deleted durable consumer: %s\n", c.consumerName)
+				})
+				log.Printf("Defined durable explicitly acked pull consumer: %s\n", c.consumerName)
+			} else if c.pushDurable && c.defineConsumer {
+				_, err = js.AddConsumer(c.streamName, &nats.ConsumerConfig{
+					Durable:        c.consumerName,
+					DeliverSubject: c.consumerName + "-DELIVERY",
+					DeliverGroup:   c.consumerName + "-GROUP",
+					DeliverPolicy:  nats.DeliverAllPolicy,
+					AckPolicy:      nats.AckExplicitPolicy,
+					ReplayPolicy:   nats.ReplayInstantPolicy,
+					MaxAckPending:  c.consumerBatch * c.numSubs,
+				})
+				if err != nil {
+					log.Fatal("Error creating the durable push consumer: ", err)
+				}
+				cleanup = append(cleanup, func() {
+					err := js.DeleteConsumer(c.streamName, c.consumerName)
 					if err != nil {
-						log.Fatal("Error creating the durable push consumer: ", err)
+						log.Fatalf("Error deleting the durable push consumer on stream %s: %v", c.streamName, err)
 					}
-					defer func() {
-						err := js.DeleteConsumer(c.streamName, c.consumerName)
-						if err != nil {
-							log.Fatalf("Error deleting the durable push consumer on stream %s: %v", c.streamName, err)
-						}
-						log.Printf("Deleted durable consumer: %s\n", c.consumerName)
-					}()
+					log.Printf("Deleted durable consumer: %s\n", c.consumerName)
+				})
 
-					log.Printf("Defined durable explicitly acked push consumer: %s\n", c.consumerName)
-				}
+				log.Printf("Defined durable explicitly acked push consumer: %s\n", c.consumerName)
 			}
 		}
 	}
 
-	var offset = func(putter int, counts []int) int {
-		var position = 0
-
-		for i := 0; i < putter; i++ {
-			position = position + counts[i]
+	return func() {
+		for _, cb := range cleanup {
+			cb()
 		}
-		return position
 	}
+}
+
+func clientOffset(client int, counts []int) int {
+	var position = 0
 
+	for i := 0; i < client; i++ {
+		position = position + counts[i]
+	}
+	return position
+}
+
+func (c *benchCmd) connect() (*nats.Conn, error) {
+	nc, err := nats.Connect(opts.Config.ServerURL(), natsOpts()...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.conns = append(c.conns, nc)
+
+	return nc, nil
+}
+
+func (c *benchCmd) closeConnections() {
+	for _, nc := range c.conns {
+		nc.Close()
+	}
+	c.conns = nil
+}
+
+func (c *benchCmd) startSubscribers(bm *bench.Benchmark, startwg *sync.WaitGroup, donewg *sync.WaitGroup) error {
 	subCounts := bench.MsgsPerClient(c.numMsg, c.numSubs)
 
 	for i := 0; i < c.numSubs; i++ {
-		nc, err := nats.Connect(opts.Config.ServerURL(), natsOpts()...)
+		if c.subClients != nil && !slices.Contains(c.subClients, i) {
+			continue
+		}
+
+		nc, err := c.connect()
 		if err != nil {
 			return fmt.Errorf("nats connection %d failed: %s", i, err)
 		}
-		defer nc.Close()
 
 		startwg.Add(1)
 		donewg.Add(1)
 
 		numMsg := func() int {
-			if c.pull || c.reply || c.pushDurable || c.kv {
+			if c.pull || c.reply || c.pushDurable || (c.kv && !c.watch) || c.obj {
 				return subCounts[i]
 			} else {
 				return c.numMsg
 			}
 		}()
 
-		go c.runSubscriber(bm, nc, startwg, donewg, numMsg, offset(i, subCounts))
+		go c.runSubscriber(bm, nc, startwg, donewg, numMsg, clientOffset(i, subCounts))
 	}
-	startwg.Wait()
 
+	return nil
+}
+
+func (c *benchCmd) startPublishers(bm *bench.Benchmark, startwg *sync.WaitGroup, donewg *sync.WaitGroup, trigger chan struct{}, benchId string) error {
 	pubCounts := bench.MsgsPerClient(c.numMsg, c.numPubs)
-	trigger := make(chan struct{})
+
 	for i := 0; i < c.numPubs; i++ {
-		nc, err := nats.Connect(opts.Config.ServerURL(), natsOpts()...)
+		if c.pubClients != nil && !slices.Contains(c.pubClients, i) {
+			continue
+		}
+
+		nc, err := c.connect()
 		if err != nil {
 			return fmt.Errorf("nats connection %d failed: %s", i, err)
 		}
-		defer nc.Close()
 
 		startwg.Add(1)
 		donewg.Add(1)
 
-		go c.runPublisher(bm, nc, startwg, donewg, trigger, pubCounts[i], offset(i, pubCounts), benchId, strconv.Itoa(i))
+		go c.runPublisher(bm, nc, startwg, donewg, trigger, pubCounts[i], clientOffset(i, pubCounts), benchId, strconv.Itoa(i))
 	}
 
-	if !c.noProgress {
-		uiprogress.Start()
-	}
+	return nil
+}
 
-	startwg.Wait()
-	close(trigger)
-	donewg.Wait()
+func (c *benchCmd) pubLatencyLabel() string {
+	switch {
+	case c.request:
+		return "Request"
+	case c.obj:
+		return "Object put"
+	case c.cas:
+		return "KV update"
+	case c.kv:
+		return "KV put"
+	case c.js:
+		return "JS publish"
+	default:
+		return "Publish"
+	}
+}
 
-	bm.Close()
+// countReceived records progress made by subscribers in scenarios, used to detect subscribers waiting for messages that will never arrive
+func (c *benchCmd) countReceived() {
+	if c.receivedTotal != nil {
+		c.receivedTotal.Add(1)
+	}
+}
 
-	if !c.noProgress {
-		uiprogress.Stop()
+// countMiss records a get of a key or object that does not exist, in scenarios gets can run before the puts storing them
+func (c *benchCmd) countMiss() {
+	if c.misses != nil {
+		c.misses.Add(1)
 	}
+}
 
+func (c *benchCmd) printWarnings() {
 	if c.fetchTimeout {
 		log.Print("WARNING: at least one of the pull consumer Fetch operation timed out. These results are not optimal!")
 	}
@@ -423,19 +779,9 @@ func (c *benchCmd) bench(_ *fisk.ParseContext) error {
 		log.Print("WARNING: at least one of the JS publish operations had to be retried. These results are not optimal!")
 	}
 
-	fmt.Println()
-	fmt.Println(bm.Report())
-
-	if c.csvFile != "" {
-		csv := bm.CSV()
-		err := os.WriteFile(c.csvFile, []byte(csv), 0644)
-		if err != nil {
-			log.Printf("error writing file %s: %v", c.csvFile, err)
-		}
-		fmt.Printf("Saved metric data in csv file %s\n", c.csvFile)
+	if c.misses != nil && c.misses.Load() > 0 {
+		log.Printf("WARNING: %s of the get operations did not find the key or object. These results are not optimal!", f(c.misses.Load()))
 	}
-
-	return nil
 }
 
 func min(a, b int) int {
@@ -465,7 +811,7 @@ func getPublishSubject(c *benchCmd, number int) string {
 	}
 }
 
-func coreNATSPublisher(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, offset int) {
+func coreNATSPublisher(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, offset int, pacer *benchPacer) {
 
 	var m *nats.Msg
 	var err error
@@ -486,6 +832,10 @@ func coreNATSPublisher(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg
 			progress.Incr()
 		}
 
+		if pacer != nil {
+			pacer.stamp(msg)
+		}
+
 		if !c.request {
 			err = nc.Publish(getPublishSubject(&c, i+offset), msg)
 			if err != nil {
@@ -500,13 +850,17 @@ func coreNATSPublisher(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg
 			if len(m.Data) == 0 || m.Data[0] == minusByte || bytes.Contains(m.Data, errBytes) {
 				log.Fatalf("Publish Request did not receive a positive ACK: %q", m.Data)
 			}
+
+			if pacer != nil {
+				pacer.record()
+			}
 		}
 		time.Sleep(c.pubSleep)
 	}
 	state = "Finished  "
 }
 
-func jsPublisher(c *benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, idPrefix string, pubNumber string, offset int) {
+func jsPublisher(c *benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, idPrefix string, pubNumber string, offset int, pacer *benchPacer) {
 	js, err := nc.JetStream(jsOpts()...)
 	if err != nil {
 		log.Fatalf("Couldn't get the JetStream context: %v", err)
@@ -525,6 +879,9 @@ func jsPublisher(c *benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byt
 			state = "Publishing"
 			futures := make([]nats.PubAckFuture, min(c.pubBatch, numMsg-i))
 			for j := 0; j < c.pubBatch && (i+j) < numMsg; j++ {
+				if pacer != nil {
+					pacer.stamp(msg)
+				}
 				if c.deDuplication {
 					header := nats.Header{}
 					header.Set(nats.MsgIdHdr, idPrefix+"-"+pubNumber+"-"+strconv.Itoa(i+j+offset))
@@ -575,6 +932,9 @@ func jsPublisher(c *benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byt
 			if progress != nil {
 				progress.Incr()
 			}
+			if pacer != nil {
+				pacer.stamp(msg)
+			}
 			if c.deDuplication {
 				header := nats.Header{}
 				header.Set(nats.MsgIdHdr, idPrefix+"-"+pubNumber+"-"+strconv.Itoa(i+offset))
@@ -590,13 +950,15 @@ func jsPublisher(c *benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byt
 				log.Printf("Publish error: %v (retrying)", err)
 				c.retriesUsed = true
 				i--
+			} else if pacer != nil {
+				pacer.record()
 			}
 			time.Sleep(c.pubSleep)
 		}
 	}
 }
 
-func kvPutter(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, offset int) {
+func kvPutter(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, offset int, pacer *benchPacer) {
 	js, err := nc.JetStream(jsOpts()...)
 	if err != nil {
 		log.Fatalf("Couldn't get the JetStream context: %v", err)
@@ -619,10 +981,18 @@ func kvPutter(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, n
 		if progress != nil {
 			progress.Incr()
 		}
+		if pacer != nil {
+			pacer.stamp(msg)
+		} else if c.watch {
+			stampBenchMsg(msg)
+		}
 		_, err = kvBucket.Put(fmt.Sprintf("%d", offset+i), msg)
 		if err != nil {
 			log.Fatalf("Put: %s", err)
 		}
+		if pacer != nil {
+			pacer.record()
+		}
 		time.Sleep(c.pubSleep)
 	}
 }
@@ -632,7 +1002,11 @@ func (c *benchCmd) runPublisher(bm *bench.Benchmark, nc *nats.Conn, startwg *syn
 
 	var progress *uiprogress.Bar
 
-	if c.kv {
+	if c.obj {
+		log.Printf("Starting object putter, putting %s objects", f(numMsg))
+	} else if c.cas {
+		log.Printf("Starting KV updater, updating %s times", f(numMsg))
+	} else if c.kv {
 		log.Printf("Starting KV putter, putting %s messages", f(numMsg))
 	} else {
 		log.Printf("Starting publisher, publishing %s messages", f(numMsg))
@@ -656,14 +1030,29 @@ func (c *benchCmd) runPublisher(bm *bench.Benchmark, nc *nats.Conn, startwg *syn
 		time.Sleep(time.Duration(n))
 	}
 
+	var pacer *benchPacer
+	if c.pubInterval > 0 {
+		// spreads the publishers evenly over the interval
+		n, _ := strconv.Atoi(pubNumber)
+		pacer = newBenchPacer(c.pubInterval, c.pubInterval*time.Duration(n)/time.Duration(c.numPubs))
+	}
+
 	start := time.Now()
 
-	if !c.js && !c.kv {
-		coreNATSPublisher(*c, nc, progress, msg, numMsg, offset)
+	if c.obj {
+		objPutter(*c, nc, progress, msg, numMsg, offset, pacer)
+	} else if c.cas {
+		c.casStats.add(kvUpdater(*c, nc, progress, msg, numMsg, offset, pacer))
+	} else if !c.js && !c.kv {
+		coreNATSPublisher(*c, nc, progress, msg, numMsg, offset, pacer)
 	} else if c.kv {
-		kvPutter(*c, nc, progress, msg, numMsg, offset)
+		kvPutter(*c, nc, progress, msg, numMsg, offset, pacer)
 	} else if c.js {
-		jsPublisher(c, nc, progress, msg, numMsg, idPrefix, pubNumber, offset)
+		jsPublisher(c, nc, progress, msg, numMsg, idPrefix, pubNumber, offset, pacer)
+	}
+
+	if pacer != nil {
+		c.pubLatency.merge(pacer.hist)
 	}
 
 	err := nc.Flush()
@@ -677,6 +1066,14 @@ func (c *benchCmd) runPublisher(bm *bench.Benchmark, nc *nats.Conn, startwg *syn
 }
 
 func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sync.WaitGroup, donewg *sync.WaitGroup, numMsg int, offset int) {
+	if c.obj {
+		c.runObjGetter(bm, nc, startwg, donewg, numMsg, offset)
+		return
+	} else if c.kv && c.watch {
+		c.runKVWatcher(bm, nc, startwg, donewg, numMsg)
+		return
+	}
+
 	received := 0
 
 	ch := make(chan time.Time, 2)
@@ -689,6 +1086,9 @@ func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sy
 		} else {
 			log.Printf("Starting subscriber, expecting %s messages", f(numMsg))
 		}
+	} else if c.stop != nil {
+		log.Print("Starting replier")
+		c.noProgress = true
 	} else {
 		log.Print("Starting replier, hit control-c to stop")
 		c.noProgress = true
@@ -707,9 +1107,21 @@ func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sy
 		})
 	}
 
+	// latencies are recorded when publishers embed timestamps, repliers leave that to the requesters
+	var latency *hdrhistogram.Histogram
+
 	// Message handler
 	mh := func(msg *nats.Msg) {
 		received++
+		c.countReceived()
+		if !c.reply && received <= numMsg {
+			if sent := benchMsgTime(msg.Data); !sent.IsZero() {
+				if latency == nil {
+					latency = newBenchHistogram()
+				}
+				recordBenchLatency(latency, time.Since(sent))
+			}
+		}
 		if c.reply || (c.js && (c.pull || c.pushDurable)) {
 			time.Sleep(c.subSleep)
 			err := msg.Ack()
@@ -820,13 +1232,16 @@ func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sy
 		state = "Getting   "
 		for i := 0; i < numMsg; i++ {
 			entry, err := kvBucket.Get(fmt.Sprintf("%d", offset+i))
-			if err != nil {
+			switch {
+			case errors.Is(err, nats.ErrKeyNotFound):
+				c.countMiss()
+			case err != nil:
 				log.Fatalf("Error getting key %d: %v", offset+i, err)
-			}
-			if entry.Value() == nil {
+			case entry.Value() == nil:
 				log.Printf("Warning: got no value for key %d", offset+i)
 			}
 
+			c.countReceived()
 			if progress != nil {
 				progress.Incr()
 			}
@@ -834,7 +1249,14 @@ func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sy
 		}
 		ch <- time.Now()
 	} else if c.js && c.pull {
+	pull:
 		for i := 0; i < numMsg; {
+			select {
+			case <-c.stop:
+				break pull
+			default:
+			}
+
 			batchSize := func() int {
 				if c.consumerBatch <= (numMsg - i) {
 					return c.consumerBatch
@@ -870,16 +1292,67 @@ func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sy
 		}
 	}
 
-	start := <-ch
-	end := <-ch
+	var start, end time.Time
+
+	if c.reply && c.stop != nil {
+		// in scenarios repliers handle requests until all other workloads completed
+		select {
+		case start = <-ch:
+		case <-c.stop:
+		}
+		<-c.stop
+		end = time.Now()
+
+		delivered, _ := sub.Delivered()
+		numMsg = int(delivered)
+	} else {
+		// in scenarios subscribers are stopped once all publishers completed and no more messages arrive
+		next := func() (time.Time, bool) {
+			select {
+			case t := <-ch:
+				return t, true
+			case <-c.stop:
+				select {
+				case t := <-ch:
+					return t, true
+				default:
+					return time.Time{}, false
+				}
+			}
+		}
+
+		var ok bool
+		start, ok = next()
+		if ok {
+			end, ok = next()
+		}
+		if !ok {
+			end = time.Now()
+			expected := numMsg
+			if c.js && c.pull {
+				numMsg = received
+			} else {
+				delivered, _ := sub.Delivered()
+				numMsg = int(delivered)
+			}
+			log.Printf("Warning: subscriber stopped after receiving %s of %s messages", f(numMsg), f(expected))
+			if start.IsZero() {
+				numMsg = 0
+			}
+		}
+	}
 
 	if !c.kv {
 		_ = sub.Drain()
 	}
 
+	c.subLatency.merge(latency)
+
 	state = "Finished  "
 
-	bm.AddSubSample(bench.NewSample(numMsg, c.msgSize, start, end, nc))
+	if numMsg > 0 {
+		bm.AddSubSample(bench.NewSample(numMsg, c.msgSize, start, end, nc))
+	}
 
 	donewg.Done()
 }
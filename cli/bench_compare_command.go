@@ -0,0 +1,508 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/dustin/go-humanize"
+	"github.com/nats-io/nats.go/bench"
+)
+
+// benchResult is the JSON format benchmark results are saved in
+type benchResult struct {
+	Name      string                 `json:"name"`
+	Time      time.Time              `json:"time"`
+	Servers   []*benchResultServer   `json:"servers"`
+	Workloads []*benchResultWorkload `json:"workloads"`
+}
+
+type benchResultServer struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+type benchResultWorkload struct {
+	Name            string                 `json:"name"`
+	Type            string                 `json:"type"`
+	Config          *benchResultConfig     `json:"config"`
+	Publish         *benchResultThroughput `json:"publish,omitempty"`
+	Subscribe       *benchResultThroughput `json:"subscribe,omitempty"`
+	PublishLatency  *benchResultLatency    `json:"publish_latency,omitempty"`
+	EndToEndLatency *benchResultLatency    `json:"end_to_end_latency,omitempty"`
+	CompareAndSet   *benchResultCAS        `json:"compare_and_set,omitempty"`
+}
+
+type benchResultConfig struct {
+	Subject       string `json:"subject,omitempty"`
+	Clients       int    `json:"clients"`
+	Messages      int    `json:"messages"`
+	Size          int    `json:"size"`
+	Rate          string `json:"rate,omitempty"`
+	MultiSubject  bool   `json:"multi_subject,omitempty"`
+	Stream        string `json:"stream,omitempty"`
+	Bucket        string `json:"bucket,omitempty"`
+	Storage       string `json:"storage,omitempty"`
+	Replicas      int    `json:"replicas,omitempty"`
+	SyncPublish   bool   `json:"sync_publish,omitempty"`
+	PublishBatch  int    `json:"publish_batch,omitempty"`
+	ConsumerBatch int    `json:"consumer_batch,omitempty"`
+	Keys          int    `json:"keys,omitempty"`
+}
+
+type benchResultThroughput struct {
+	Clients     int           `json:"clients"`
+	Messages    int           `json:"messages"`
+	MsgsPerSec  int64         `json:"msgs_per_sec"`
+	BytesPerSec float64       `json:"bytes_per_sec"`
+	Duration    time.Duration `json:"duration"`
+}
+
+type benchResultCAS struct {
+	Attempts     int     `json:"attempts"`
+	Conflicts    int     `json:"conflicts"`
+	ConflictRate float64 `json:"conflict_rate"`
+}
+
+type benchResultLatency struct {
+	Samples     int64                    `json:"samples"`
+	Min         time.Duration            `json:"min"`
+	Max         time.Duration            `json:"max"`
+	Percentiles map[string]time.Duration `json:"percentiles"`
+}
+
+func newBenchResult(name string, servers []*benchResultServer) *benchResult {
+	res := &benchResult{
+		Name:      name,
+		Time:      time.Now().UTC(),
+		Servers:   []*benchResultServer{},
+		Workloads: []*benchResultWorkload{},
+	}
+
+	seen := map[benchResultServer]bool{}
+	for _, srv := range servers {
+		if seen[*srv] {
+			continue
+		}
+		seen[*srv] = true
+		res.Servers = append(res.Servers, srv)
+	}
+
+	return res
+}
+
+func loadBenchResult(file string) (*benchResult, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &benchResult{}
+	err = json.Unmarshal(data, res)
+	if err != nil {
+		return nil, fmt.Errorf("invalid benchmark results %s: %v", file, err)
+	}
+
+	return res, nil
+}
+
+func (r *benchResult) save(file string) error {
+	j, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, j, 0644)
+}
+
+func (r *benchResult) workload(name string) *benchResultWorkload {
+	for _, w := range r.Workloads {
+		if w.Name == name {
+			return w
+		}
+	}
+
+	return nil
+}
+
+func (r *benchResult) serverVersions() string {
+	var versions []string
+	for _, srv := range r.Servers {
+		if !slices.Contains(versions, srv.Version) {
+			versions = append(versions, srv.Version)
+		}
+	}
+
+	return strings.Join(versions, ", ")
+}
+
+// servers reports the servers the benchmark clients are connected to
+func (c *benchCmd) servers() []*benchResultServer {
+	var servers []*benchResultServer
+	for _, nc := range c.conns {
+		servers = append(servers, &benchResultServer{
+			Name:    nc.ConnectedServerName(),
+			Version: nc.ConnectedServerVersion(),
+			Cluster: nc.ConnectedClusterName(),
+		})
+	}
+
+	return servers
+}
+
+func (c *benchCmd) modeName() string {
+	switch {
+	case c.obj:
+		return "obj"
+	case c.kv && c.cas:
+		return "kv-cas"
+	case c.kv && c.watch:
+		return "kv-watch"
+	case c.kv:
+		return "kv"
+	case c.js && c.pull:
+		return "js-pull"
+	case c.js && c.pushDurable:
+		return "js-push"
+	case c.js:
+		return "js"
+	case c.request:
+		return "request"
+	case c.reply:
+		return "reply"
+	default:
+		return "core"
+	}
+}
+
+func (c *benchCmd) resultWorkload(name string, kind string, clients int, bm *bench.Benchmark) *benchResultWorkload {
+	w := &benchResultWorkload{
+		Name: name,
+		Type: kind,
+		Config: &benchResultConfig{
+			Subject:      c.subject,
+			Clients:      clients,
+			Messages:     c.numMsg,
+			Size:         c.msgSize,
+			Rate:         c.rateString,
+			MultiSubject: c.multiSubject,
+		},
+		Publish:         benchThroughput(bm.Pubs),
+		Subscribe:       benchThroughput(bm.Subs),
+		PublishLatency:  c.pubLatency.result(),
+		EndToEndLatency: c.subLatency.result(),
+	}
+
+	switch {
+	case c.kv || c.obj:
+		w.Config.Bucket = c.bucketName
+		w.Config.Storage = c.storage
+		w.Config.Replicas = c.replicas
+		if c.cas {
+			w.Config.Keys = c.casKeys
+			w.CompareAndSet = c.casStats.result()
+		}
+	case c.js:
+		w.Config.Stream = c.streamName
+		w.Config.Storage = c.storage
+		w.Config.Replicas = c.replicas
+		w.Config.SyncPublish = c.syncPub
+		w.Config.PublishBatch = c.pubBatch
+		w.Config.ConsumerBatch = c.consumerBatch
+	}
+
+	return w
+}
+
+func benchThroughput(sg *bench.SampleGroup) *benchResultThroughput {
+	if !sg.HasSamples() {
+		return nil
+	}
+
+	return &benchResultThroughput{
+		Clients:     len(sg.Samples),
+		Messages:    sg.JobMsgCnt,
+		MsgsPerSec:  sg.Rate(),
+		BytesPerSec: sg.Throughput(),
+		Duration:    sg.Duration(),
+	}
+}
+
+func (l *benchLatency) result() *benchResultLatency {
+	if l.count() == 0 {
+		return nil
+	}
+
+	res := &benchResultLatency{
+		Samples:     l.hist.TotalCount(),
+		Min:         time.Duration(l.hist.Min()),
+		Max:         time.Duration(l.hist.Max()),
+		Percentiles: map[string]time.Duration{},
+	}
+
+	for _, p := range benchPercentiles {
+		res.Percentiles[benchPercentileName(p)] = time.Duration(l.hist.ValueAtQuantile(p))
+	}
+
+	return res
+}
+
+func benchPercentileName(p float64) string {
+	return fmt.Sprintf("p%v", p)
+}
+
+type benchCompareCmd struct {
+	baseFile            string
+	newFile             string
+	throughputThreshold float64
+	latencyThreshold    float64
+	json                bool
+}
+
+type benchComparison struct {
+	Base        string              `json:"base"`
+	New         string              `json:"new"`
+	Metrics     []*benchMetricDelta `json:"metrics"`
+	Missing     []string            `json:"missing,omitempty"`
+	Changed     []string            `json:"changed_config,omitempty"`
+	Regressions int                 `json:"regressions"`
+}
+
+type benchMetricDelta struct {
+	Workload   string  `json:"workload"`
+	Metric     string  `json:"metric"`
+	Base       float64 `json:"base"`
+	New        float64 `json:"new"`
+	Change     float64 `json:"change"`
+	Regression bool    `json:"regression"`
+	Missing    bool    `json:"missing,omitempty"`
+
+	latency bool
+}
+
+func configureBenchCompareCommand(bench *fisk.CmdClause) {
+	c := &benchCompareCmd{}
+
+	help := `Compares the results of two benchmarks
+
+Results saved using nats bench --results are compared per workload. A
+decrease in throughput or an increase in latency percentiles beyond the
+thresholds is a regression, as are workloads and metrics in the baseline
+that are missing from the new results. The command exits with status 1 when
+any regression is found.
+`
+
+	compare := bench.Command("compare", "Compares the results of two benchmarks").Action(c.compareAction)
+	compare.HelpLong(help)
+	compare.Arg("base", "Results of the baseline benchmark").Required().ExistingFileVar(&c.baseFile)
+	compare.Arg("new", "Results of the benchmark to compare to the baseline").Required().ExistingFileVar(&c.newFile)
+	compare.Flag("throughput-threshold", "Percentage decrease in throughput that is a regression").Default("5").Float64Var(&c.throughputThreshold)
+	compare.Flag("latency-threshold", "Percentage increase in latency that is a regression").Default("10").Float64Var(&c.latencyThreshold)
+	compare.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+}
+
+func (c *benchCompareCmd) compareAction(_ *fisk.ParseContext) error {
+	base, err := loadBenchResult(c.baseFile)
+	if err != nil {
+		return err
+	}
+
+	current, err := loadBenchResult(c.newFile)
+	if err != nil {
+		return err
+	}
+
+	cmp, err := c.compare(base, current)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		err = printJSON(cmp)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.renderComparison(base, current, cmp)
+	}
+
+	if cmp.Regressions > 0 {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func (c *benchCompareCmd) compare(base *benchResult, current *benchResult) (*benchComparison, error) {
+	cmp := &benchComparison{Base: c.baseFile, New: c.newFile, Metrics: []*benchMetricDelta{}}
+
+	for _, bw := range base.Workloads {
+		nw := current.workload(bw.Name)
+		if nw == nil {
+			cmp.Missing = append(cmp.Missing, bw.Name)
+			cmp.Regressions++
+			continue
+		}
+
+		if !reflect.DeepEqual(bw.Config, nw.Config) {
+			cmp.Changed = append(cmp.Changed, bw.Name)
+		}
+
+		if bw.Publish != nil {
+			if nw.Publish != nil {
+				cmp.add(c.throughputDelta(bw.Name, "Publish msgs/sec", bw.Publish.MsgsPerSec, nw.Publish.MsgsPerSec))
+			} else {
+				cmp.add(missingBenchMetric(bw.Name, "Publish msgs/sec", float64(bw.Publish.MsgsPerSec), false))
+			}
+		}
+		if bw.Subscribe != nil {
+			if nw.Subscribe != nil {
+				cmp.add(c.throughputDelta(bw.Name, "Subscribe msgs/sec", bw.Subscribe.MsgsPerSec, nw.Subscribe.MsgsPerSec))
+			} else {
+				cmp.add(missingBenchMetric(bw.Name, "Subscribe msgs/sec", float64(bw.Subscribe.MsgsPerSec), false))
+			}
+		}
+
+		for _, l := range []struct {
+			name string
+			base *benchResultLatency
+			new  *benchResultLatency
+		}{
+			{"Publish latency", bw.PublishLatency, nw.PublishLatency},
+			{"End to end latency", bw.EndToEndLatency, nw.EndToEndLatency},
+		} {
+			if l.base == nil {
+				continue
+			}
+
+			for _, p := range benchPercentiles {
+				name := benchPercentileName(p)
+				metric := fmt.Sprintf("%s %s", l.name, name)
+
+				bv, ok := l.base.Percentiles[name]
+				if !ok {
+					continue
+				}
+
+				var nv time.Duration
+				if l.new != nil {
+					nv, ok = l.new.Percentiles[name]
+				}
+				if l.new == nil || !ok {
+					cmp.add(missingBenchMetric(bw.Name, metric, float64(bv), true))
+					continue
+				}
+
+				cmp.add(c.latencyDelta(bw.Name, metric, bv, nv))
+			}
+		}
+	}
+
+	if len(cmp.Metrics) == 0 {
+		return nil, fmt.Errorf("no metrics to compare, the results have no workloads in common")
+	}
+
+	return cmp, nil
+}
+
+func (cmp *benchComparison) add(d *benchMetricDelta) {
+	cmp.Metrics = append(cmp.Metrics, d)
+	if d.Regression {
+		cmp.Regressions++
+	}
+}
+
+// missingBenchMetric is a regression for a metric in the baseline that is not in the new results
+func missingBenchMetric(workload string, metric string, base float64, latency bool) *benchMetricDelta {
+	return &benchMetricDelta{Workload: workload, Metric: metric, Base: base, Regression: true, Missing: true, latency: latency}
+}
+
+func benchChange(base float64, current float64) float64 {
+	if base == 0 {
+		return 0
+	}
+
+	return (current - base) / base * 100
+}
+
+func (c *benchCompareCmd) throughputDelta(workload string, metric string, base int64, current int64) *benchMetricDelta {
+	d := &benchMetricDelta{Workload: workload, Metric: metric, Base: float64(base), New: float64(current)}
+	d.Change = benchChange(d.Base, d.New)
+	d.Regression = -d.Change > c.throughputThreshold
+
+	return d
+}
+
+func (c *benchCompareCmd) latencyDelta(workload string, metric string, base time.Duration, current time.Duration) *benchMetricDelta {
+	d := &benchMetricDelta{Workload: workload, Metric: metric, Base: float64(base), New: float64(current), latency: true}
+	d.Change = benchChange(d.Base, d.New)
+	d.Regression = d.Change > c.latencyThreshold
+
+	return d
+}
+
+func (d *benchMetricDelta) format(v float64) string {
+	if d.latency {
+		return benchDuration(int64(v))
+	}
+
+	return humanize.Comma(int64(v))
+}
+
+func (c *benchCompareCmd) renderComparison(base *benchResult, current *benchResult, cmp *benchComparison) {
+	cols := newColumns("Comparison of benchmark %s and %s", c.baseFile, c.newFile)
+	cols.AddRow("Base", fmt.Sprintf("%s at %s", base.Name, f(base.Time)))
+	cols.AddRowIfNotEmpty("Base Servers", base.serverVersions())
+	cols.AddRow("New", fmt.Sprintf("%s at %s", current.Name, f(current.Time)))
+	cols.AddRowIfNotEmpty("New Servers", current.serverVersions())
+	cols.AddRow("Throughput Threshold", fmt.Sprintf("%v%%", c.throughputThreshold))
+	cols.AddRow("Latency Threshold", fmt.Sprintf("%v%%", c.latencyThreshold))
+	cols.AddRow("Regressions", cmp.Regressions)
+	cols.Frender(os.Stdout)
+	fmt.Println()
+
+	for _, name := range cmp.Missing {
+		fmt.Printf("REGRESSION: workload %s is not in %s\n", name, c.newFile)
+	}
+	for _, name := range cmp.Changed {
+		fmt.Printf("WARNING: workload %s was run using a different configuration\n", name)
+	}
+	if len(cmp.Missing) > 0 || len(cmp.Changed) > 0 {
+		fmt.Println()
+	}
+
+	table := newTableWriter("Changes")
+	table.AddHeaders("Workload", "Metric", "Base", "New", "Change", "Status")
+	for _, d := range cmp.Metrics {
+		if d.Missing {
+			table.AddRow(d.Workload, d.Metric, d.format(d.Base), "", "", "missing")
+			continue
+		}
+
+		status := "ok"
+		if d.Regression {
+			status = "regression"
+		}
+		table.AddRow(d.Workload, d.Metric, d.format(d.Base), d.format(d.New), fmt.Sprintf("%+.1f%%", d.Change), status)
+	}
+	fmt.Println(table.Render())
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestBenchCommandParsing(t *testing.T) {
+	app := fisk.New("nats", "")
+	configureBenchCommand(app)
+
+	for _, tc := range []struct {
+		args    []string
+		command string
+	}{
+		{[]string{"bench", "subject", "--pub", "1"}, "bench run"},
+		{[]string{"bench", "--scenario", "bench_compare_command_test.go"}, "bench run"},
+		{[]string{"bench", "compare", "bench_compare_command_test.go", "bench_compare_command_test.go"}, "bench compare"},
+	} {
+		pc, err := app.ParseContext(tc.args)
+		checkErr(t, err, "parse failed: %v", err)
+		if pc.SelectedCommand.FullCommand() != tc.command {
+			t.Fatalf("expected %q for %v got %q", tc.command, tc.args, pc.SelectedCommand.FullCommand())
+		}
+	}
+}
+
+func TestBenchCompare(t *testing.T) {
+	latency := func(p50, p99 time.Duration) *benchResultLatency {
+		return &benchResultLatency{Samples: 100, Percentiles: map[string]time.Duration{"p50": p50, "p99": p99}}
+	}
+
+	base := &benchResult{Workloads: []*benchResultWorkload{
+		{
+			Name:            "orders",
+			Config:          &benchResultConfig{Subject: "orders", Clients: 2},
+			Publish:         &benchResultThroughput{MsgsPerSec: 100000},
+			Subscribe:       &benchResultThroughput{MsgsPerSec: 50000},
+			EndToEndLatency: latency(time.Millisecond, 10*time.Millisecond),
+		},
+		{
+			Name:    "removed",
+			Config:  &benchResultConfig{},
+			Publish: &benchResultThroughput{MsgsPerSec: 1000},
+		},
+	}}
+
+	current := &benchResult{Workloads: []*benchResultWorkload{
+		{
+			Name:            "orders",
+			Config:          &benchResultConfig{Subject: "orders", Clients: 4},
+			Publish:         &benchResultThroughput{MsgsPerSec: 97000},
+			Subscribe:       &benchResultThroughput{MsgsPerSec: 40000},
+			EndToEndLatency: latency(900*time.Microsecond, 12*time.Millisecond),
+		},
+	}}
+
+	c := &benchCompareCmd{throughputThreshold: 5, latencyThreshold: 10}
+	cmp, err := c.compare(base, current)
+	checkErr(t, err, "compare failed: %v", err)
+
+	assertListEquals(t, cmp.Missing, "removed")
+	assertListEquals(t, cmp.Changed, "orders")
+
+	expected := map[string]bool{
+		"Publish msgs/sec":       false,
+		"Subscribe msgs/sec":     true,
+		"End to end latency p50": false,
+		"End to end latency p99": true,
+	}
+
+	if len(cmp.Metrics) != len(expected) {
+		t.Fatalf("expected %d metrics got %d", len(expected), len(cmp.Metrics))
+	}
+
+	for _, d := range cmp.Metrics {
+		regression, ok := expected[d.Metric]
+		if !ok {
+			t.Fatalf("unexpected metric %q", d.Metric)
+		}
+		if d.Regression != regression {
+			t.Fatalf("expected regression %v for %s, change %.1f%%", regression, d.Metric, d.Change)
+		}
+	}
+
+	// the missing workload is a regression too
+	if cmp.Regressions != 3 {
+		t.Fatalf("expected 3 regressions got %d", cmp.Regressions)
+	}
+
+	current.Workloads[0].Subscribe = nil
+	current.Workloads[0].EndToEndLatency = nil
+	cmp, err = c.compare(base, current)
+	checkErr(t, err, "compare failed: %v", err)
+
+	var missing []string
+	for _, d := range cmp.Metrics {
+		if d.Missing {
+			missing = append(missing, d.Metric)
+		}
+	}
+	assertListEquals(t, missing, "Subscribe msgs/sec", "End to end latency p50", "End to end latency p99")
+	if cmp.Regressions != 4 {
+		t.Fatalf("expected 4 regressions got %d", cmp.Regressions)
+	}
+
+	_, err = c.compare(base, &benchResult{})
+	if err == nil {
+		t.Fatalf("expected an error comparing results without common workloads")
+	}
+}
+
+func TestBenchResults(t *testing.T) {
+	withBenchContext(t, func(_ *server.Server, _ *nats.Conn, _ *jsm.Manager) {
+		file := filepath.Join(t.TempDir(), "results.json")
+
+		cmd := &benchCmd{
+			subject:       "bench",
+			numPubs:       1,
+			numSubs:       1,
+			numMsg:        100,
+			msgSizeString: "16",
+			noProgress:    true,
+			rateString:    "10000/s",
+			consumerName:  DefaultDurableConsumerName,
+			resultsFile:   file,
+		}
+
+		err := cmd.bench(nil)
+		checkErr(t, err, "bench failed: %v", err)
+
+		res, err := loadBenchResult(file)
+		checkErr(t, err, "load failed: %v", err)
+
+		if len(res.Servers) != 1 || res.Servers[0].Version != server.VERSION {
+			t.Fatalf("invalid servers: %+v", res.Servers)
+		}
+
+		w := res.workload("NATS")
+		if w == nil || w.Type != "core" || w.Config.Clients != 2 || w.Config.Rate != "10000/s" {
+			t.Fatalf("invalid workload: %+v", w)
+		}
+		if w.Publish == nil || w.Publish.Messages != 100 || w.Subscribe == nil || w.Subscribe.Messages != 100 {
+			t.Fatalf("invalid throughput: %+v %+v", w.Publish, w.Subscribe)
+		}
+		if w.EndToEndLatency == nil || w.EndToEndLatency.Samples != 100 || w.EndToEndLatency.Percentiles["p99.9"] == 0 {
+			t.Fatalf("invalid latency: %+v", w.EndToEndLatency)
+		}
+
+		// identical results do not regress
+		cmp, err := (&benchCompareCmd{}).compare(res, res)
+		checkErr(t, err, "compare failed: %v", err)
+		if cmp.Regressions != 0 {
+			t.Fatalf("expected no regressions got %d", cmp.Regressions)
+		}
+	})
+}
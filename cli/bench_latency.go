@@ -0,0 +1,167 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const benchLatencyMax = int64(10 * time.Minute)
+
+var benchPercentiles = []float64{50, 90, 99, 99.9, 99.99}
+
+// benchLatency collects the latencies recorded by all the clients in a benchmark
+type benchLatency struct {
+	hist *hdrhistogram.Histogram
+	mu   sync.Mutex
+}
+
+func newBenchLatency() *benchLatency {
+	return &benchLatency{hist: newBenchHistogram()}
+}
+
+func newBenchHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, benchLatencyMax, 3)
+}
+
+func (l *benchLatency) merge(h *hdrhistogram.Histogram) {
+	if l == nil || h == nil {
+		return
+	}
+
+	l.mu.Lock()
+	l.hist.Merge(h)
+	l.mu.Unlock()
+}
+
+func (l *benchLatency) count() int64 {
+	if l == nil {
+		return 0
+	}
+
+	return l.hist.TotalCount()
+}
+
+func recordBenchLatency(h *hdrhistogram.Histogram, d time.Duration) {
+	v := int64(d)
+	switch {
+	case v < 1:
+		v = 1
+	case v > benchLatencyMax:
+		v = benchLatencyMax
+	}
+
+	h.RecordValue(v)
+}
+
+// benchMsgTime extracts the time a message was scheduled to be sent at, messages without a timestamp give a zero time
+func benchMsgTime(data []byte) time.Time {
+	if len(data) < 8 {
+		return time.Time{}
+	}
+
+	ts := int64(binary.LittleEndian.Uint64(data))
+	if ts <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ts)
+}
+
+// stampBenchMsg embeds the current time in msg for subscribers to measure end to end latency
+func stampBenchMsg(msg []byte) {
+	binary.LittleEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+}
+
+// benchPacer schedules messages at a fixed interval regardless of how long publishing takes, latencies are
+// measured from the scheduled time rather than the actual send time to avoid coordinated omission
+type benchPacer struct {
+	start     time.Time
+	interval  time.Duration
+	n         int64
+	scheduled time.Time
+	hist      *hdrhistogram.Histogram
+}
+
+func newBenchPacer(interval time.Duration, delay time.Duration) *benchPacer {
+	return &benchPacer{
+		start:    time.Now().Add(delay),
+		interval: interval,
+		hist:     newBenchHistogram(),
+	}
+}
+
+// stamp waits for the next scheduled send time and embeds it in msg, falling behind the schedule does not delay later messages
+func (p *benchPacer) stamp(msg []byte) {
+	p.scheduled = p.start.Add(time.Duration(p.n) * p.interval)
+	p.n++
+
+	d := time.Until(p.scheduled)
+	if d > 0 {
+		time.Sleep(d)
+	}
+
+	binary.LittleEndian.PutUint64(msg, uint64(p.scheduled.UnixNano()))
+}
+
+// record records the time since the last message was scheduled to be sent
+func (p *benchPacer) record() {
+	recordBenchLatency(p.hist, time.Since(p.scheduled))
+}
+
+func renderBenchLatency(pubLabel string, pub *benchLatency, sub *benchLatency) {
+	if pub.count() == 0 && sub.count() == 0 {
+		return
+	}
+
+	table := newTableWriter("Latency")
+	headers := []any{"", "Samples", "Min"}
+	for _, p := range benchPercentiles {
+		headers = append(headers, benchPercentileName(p))
+	}
+	headers = append(headers, "Max")
+	table.AddHeaders(headers...)
+
+	for _, l := range []struct {
+		label   string
+		latency *benchLatency
+	}{
+		{pubLabel, pub},
+		{"End to end", sub},
+	} {
+		if l.latency.count() == 0 {
+			continue
+		}
+
+		h := l.latency.hist
+		row := []any{l.label, f(h.TotalCount()), benchDuration(h.Min())}
+		for _, p := range benchPercentiles {
+			row = append(row, benchDuration(h.ValueAtQuantile(p)))
+		}
+		row = append(row, benchDuration(h.Max()))
+		table.AddRow(row...)
+	}
+
+	fmt.Println(table.Render())
+}
+
+// benchDuration formats latencies with microsecond precision, e.g 234µs, 4.567ms
+func benchDuration(v int64) string {
+	return time.Duration(v).Truncate(time.Microsecond).String()
+}
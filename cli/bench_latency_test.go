@@ -0,0 +1,92 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestBenchPacer(t *testing.T) {
+	pacer := newBenchPacer(10*time.Millisecond, 0)
+	msg := make([]byte, 16)
+
+	for i := 0; i < 5; i++ {
+		pacer.stamp(msg)
+
+		sent := benchMsgTime(msg)
+		if !sent.Equal(pacer.start.Add(time.Duration(i) * 10 * time.Millisecond)) {
+			t.Fatalf("message %d was not scheduled at the expected time: %v", i, sent)
+		}
+	}
+
+	if time.Since(pacer.start) < 40*time.Millisecond {
+		t.Fatalf("messages were not paced: %v", time.Since(pacer.start))
+	}
+
+	// falling behind the schedule sends immediately, the latency includes the time spent behind
+	time.Sleep(50 * time.Millisecond)
+	pacer.stamp(msg)
+	pacer.record()
+	if pacer.hist.Max() < int64(40*time.Millisecond) {
+		t.Fatalf("latency did not include the delay: %v", time.Duration(pacer.hist.Max()))
+	}
+
+	if !benchMsgTime(make([]byte, 16)).IsZero() || !benchMsgTime([]byte("x")).IsZero() {
+		t.Fatalf("messages without timestamps had a time")
+	}
+}
+
+func TestBenchRate(t *testing.T) {
+	withBenchContext(t, func(_ *server.Server, _ *nats.Conn, _ *jsm.Manager) {
+		cmd := &benchCmd{
+			subject:       "bench",
+			numPubs:       2,
+			numSubs:       1,
+			numMsg:        200,
+			msgSizeString: "16",
+			noProgress:    true,
+			rateString:    "1000/s",
+			consumerName:  DefaultDurableConsumerName,
+		}
+
+		start := time.Now()
+		err := cmd.bench(nil)
+		checkErr(t, err, "bench failed: %v", err)
+
+		// 2 publishers each sending 100 messages at 1000/s
+		if time.Since(start) < 90*time.Millisecond {
+			t.Fatalf("publishing was not rate limited: %v", time.Since(start))
+		}
+
+		if cmd.subLatency.count() != 200 {
+			t.Fatalf("expected 200 latency samples got %d", cmd.subLatency.count())
+		}
+
+		// core publishing does not wait for the server
+		if cmd.pubLatency.count() != 0 {
+			t.Fatalf("expected no publish latency samples got %d", cmd.pubLatency.count())
+		}
+
+		cmd = &benchCmd{subject: "bench", numPubs: 1, numMsg: 10, msgSizeString: "4", rateString: "10/s"}
+		err = cmd.bench(nil)
+		if err == nil || err.Error() != "the message size must be at least 8 bytes to embed timestamps when using --rate" {
+			t.Fatalf("expected size error got %v", err)
+		}
+	})
+}
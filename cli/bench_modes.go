@@ -0,0 +1,381 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gosuri/uiprogress"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/bench"
+)
+
+// benchCAS counts the compare-and-set attempts made by all publishers and how many of them conflicted
+type benchCAS struct {
+	attempts  int
+	conflicts int
+	mu        sync.Mutex
+}
+
+func (s *benchCAS) add(attempts int, conflicts int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.attempts += attempts
+	s.conflicts += conflicts
+	s.mu.Unlock()
+}
+
+func (s *benchCAS) conflictRate() float64 {
+	if s == nil || s.attempts == 0 {
+		return 0
+	}
+
+	return float64(s.conflicts) / float64(s.attempts) * 100
+}
+
+func (s *benchCAS) render() {
+	if s == nil || s.attempts == 0 {
+		return
+	}
+
+	table := newTableWriter("Compare and set")
+	table.AddHeaders("Attempts", "Updates", "Conflicts", "Conflict rate")
+	table.AddRow(f(s.attempts), f(s.attempts-s.conflicts), f(s.conflicts), fmt.Sprintf("%.2f%%", s.conflictRate()))
+
+	fmt.Println(table.Render())
+}
+
+func (s *benchCAS) result() *benchResultCAS {
+	if s == nil || s.attempts == 0 {
+		return nil
+	}
+
+	return &benchResultCAS{Attempts: s.attempts, Conflicts: s.conflicts, ConflictRate: s.conflictRate()}
+}
+
+func (c *benchCmd) validateModes() error {
+	switch {
+	case c.obj && (c.js || c.kv):
+		return fmt.Errorf("can not operate in --obj mode together with --js or --kv")
+	case c.obj && (c.request || c.reply):
+		return fmt.Errorf("request-reply mode is not applicable to Object Store benchmarking")
+	case (c.watch || c.cas) && !c.kv:
+		return fmt.Errorf("--watch and --cas can only be used in --kv mode")
+	case c.cas && c.casKeys < 1:
+		return fmt.Errorf("at least 1 key is required in --cas mode")
+	case c.cas && c.numSubs > 0 && !c.watch:
+		return fmt.Errorf("subscribers can only be used in --cas mode when watching the bucket with --watch")
+	case c.watch && c.msgSize < 8:
+		return fmt.Errorf("the message size must be at least 8 bytes to embed timestamps when using --watch")
+	}
+
+	switch {
+	case c.obj:
+		log.Print("Object Store mode, publishers put objects, subscribers get objects")
+	case c.cas:
+		log.Printf("KV compare-and-set mode, publishers update %d shared keys and retry on conflicts", c.casKeys)
+	}
+
+	if c.watch {
+		log.Print("KV watch mode, every subscriber watches the bucket and receives every put")
+	}
+
+	return nil
+}
+
+func benchObjectName(size int, n int) string {
+	return fmt.Sprintf("%d/%d", size, n)
+}
+
+func benchSizes(sizes []int) string {
+	var parts []string
+	for _, size := range sizes {
+		parts = append(parts, humanize.IBytes(uint64(size)))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func objPutter(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, offset int, pacer *benchPacer) {
+	js, err := nc.JetStream(jsOpts()...)
+	if err != nil {
+		log.Fatalf("Couldn't get the JetStream context: %v", err)
+	}
+
+	obs, err := js.ObjectStore(c.bucketName)
+	if err != nil {
+		log.Fatalf("Couldn't find object store %s: %v", c.bucketName, err)
+	}
+
+	var state string = "Putting   "
+
+	if progress != nil {
+		progress.PrependFunc(func(b *uiprogress.Bar) string {
+			return state
+		})
+	}
+
+	for i := 0; i < numMsg; i++ {
+		if progress != nil {
+			progress.Incr()
+		}
+		if pacer != nil {
+			pacer.stamp(msg)
+		}
+		_, err = obs.PutBytes(benchObjectName(c.msgSize, offset+i), msg)
+		if err != nil {
+			log.Fatalf("Put: %s", err)
+		}
+		if pacer != nil {
+			pacer.record()
+		}
+		time.Sleep(c.pubSleep)
+	}
+}
+
+// kvUpdater updates a shared set of keys using optimistic concurrency, retrying when another client updated the key first
+func kvUpdater(c benchCmd, nc *nats.Conn, progress *uiprogress.Bar, msg []byte, numMsg int, offset int, pacer *benchPacer) (attempts int, conflicts int) {
+	js, err := nc.JetStream(jsOpts()...)
+	if err != nil {
+		log.Fatalf("Couldn't get the JetStream context: %v", err)
+	}
+
+	kvBucket, err := js.KeyValue(c.bucketName)
+	if err != nil {
+		log.Fatalf("Couldn't find kv bucket %s: %v", c.bucketName, err)
+	}
+
+	var state string = "Updating  "
+
+	if progress != nil {
+		progress.PrependFunc(func(b *uiprogress.Bar) string {
+			return state
+		})
+	}
+
+	for i := 0; i < numMsg; i++ {
+		if progress != nil {
+			progress.Incr()
+		}
+		if pacer != nil {
+			pacer.stamp(msg)
+		} else if c.watch {
+			stampBenchMsg(msg)
+		}
+
+		key := strconv.Itoa((offset + i) % c.casKeys)
+		for {
+			attempts++
+
+			entry, err := kvBucket.Get(key)
+			switch {
+			case errors.Is(err, nats.ErrKeyNotFound):
+				_, err = kvBucket.Create(key, msg)
+			case err != nil:
+				log.Fatalf("Get: %s", err)
+			default:
+				_, err = kvBucket.Update(key, msg, entry.Revision())
+			}
+
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, nats.ErrKeyExists) {
+				log.Fatalf("Update: %s", err)
+			}
+			conflicts++
+		}
+
+		if pacer != nil {
+			pacer.record()
+		}
+		time.Sleep(c.pubSleep)
+	}
+
+	return attempts, conflicts
+}
+
+func (c *benchCmd) runObjGetter(bm *bench.Benchmark, nc *nats.Conn, startwg *sync.WaitGroup, donewg *sync.WaitGroup, numMsg int, offset int) {
+	log.Printf("Starting object getter, trying to get %s objects", f(numMsg))
+
+	js, err := nc.JetStream(jsOpts()...)
+	if err != nil {
+		log.Fatalf("Couldn't get the JetStream context: %v", err)
+	}
+
+	obs, err := js.ObjectStore(c.bucketName)
+	if err != nil {
+		log.Fatalf("Couldn't find object store %s: %v", c.bucketName, err)
+	}
+
+	var progress *uiprogress.Bar
+	if !c.noProgress {
+		progress = uiprogress.AddBar(numMsg).AppendCompleted().PrependElapsed()
+		progress.Width = progressWidth()
+		progress.PrependFunc(func(b *uiprogress.Bar) string {
+			return "Getting   "
+		})
+	}
+
+	startwg.Done()
+
+	start := time.Now()
+	if progress != nil {
+		progress.TimeStarted = start
+	}
+
+	for i := 0; i < numMsg; i++ {
+		data, err := obs.GetBytes(benchObjectName(c.msgSize, offset+i))
+		switch {
+		case errors.Is(err, nats.ErrObjectNotFound):
+			c.countMiss()
+		case err != nil:
+			log.Fatalf("Error getting object %d: %v", offset+i, err)
+		case len(data) != c.msgSize:
+			log.Printf("Warning: got %s for object %d, expected %s", humanize.IBytes(uint64(len(data))), offset+i, humanize.IBytes(uint64(c.msgSize)))
+		}
+
+		c.countReceived()
+		if progress != nil {
+			progress.Incr()
+		}
+		time.Sleep(c.subSleep)
+	}
+
+	bm.AddSubSample(newBenchSample(numMsg, c.msgSize, start, time.Now(), nc))
+
+	donewg.Done()
+}
+
+// runKVWatcher receives every put made to the bucket while the benchmark runs and records the fan-out latency
+func (c *benchCmd) runKVWatcher(bm *bench.Benchmark, nc *nats.Conn, startwg *sync.WaitGroup, donewg *sync.WaitGroup, numMsg int) {
+	log.Printf("Starting KV watcher, expecting %s updates", f(numMsg))
+
+	js, err := nc.JetStream(jsOpts()...)
+	if err != nil {
+		log.Fatalf("Couldn't get the JetStream context: %v", err)
+	}
+
+	kvBucket, err := js.KeyValue(c.bucketName)
+	if err != nil {
+		log.Fatalf("Couldn't find kv bucket %s: %v", c.bucketName, err)
+	}
+
+	watcher, err := kvBucket.WatchAll(nats.UpdatesOnly())
+	if err != nil {
+		log.Fatalf("Couldn't watch kv bucket %s: %v", c.bucketName, err)
+	}
+	defer watcher.Stop()
+
+	var progress *uiprogress.Bar
+	if !c.noProgress {
+		progress = uiprogress.AddBar(numMsg).AppendCompleted().PrependElapsed()
+		progress.Width = progressWidth()
+		progress.PrependFunc(func(b *uiprogress.Bar) string {
+			return "Watching  "
+		})
+	}
+
+	latency := newBenchHistogram()
+
+	startwg.Done()
+
+	var start time.Time
+	received := 0
+watch:
+	for received < numMsg {
+		var entry nats.KeyValueEntry
+		var ok bool
+
+		select {
+		case entry, ok = <-watcher.Updates():
+		case <-c.stop:
+			log.Printf("Warning: KV watcher stopped after receiving %s of %s updates", f(received), f(numMsg))
+			numMsg = received
+			break watch
+		}
+
+		if !ok {
+			log.Fatalf("The watcher on kv bucket %s stopped", c.bucketName)
+		}
+		if entry == nil || entry.Operation() != nats.KeyValuePut {
+			continue
+		}
+
+		received++
+		c.countReceived()
+		if received == 1 {
+			start = time.Now()
+		}
+		if sent := benchMsgTime(entry.Value()); !sent.IsZero() {
+			recordBenchLatency(latency, time.Since(sent))
+		}
+
+		if progress != nil {
+			progress.Incr()
+		}
+	}
+
+	c.subLatency.merge(latency)
+
+	if numMsg > 0 {
+		bm.AddSubSample(newBenchSample(numMsg, c.msgSize, start, time.Now(), nc))
+	}
+
+	donewg.Done()
+}
+
+// newBenchSample is like bench.NewSample but reads the connection statistics safely, object store
+// and KV watch clients keep using their connection in the background after the benchmark completed
+func newBenchSample(jobCount int, msgSize int, start time.Time, end time.Time, nc *nats.Conn) *bench.Sample {
+	stats := nc.Stats()
+
+	return &bench.Sample{
+		JobMsgCnt: jobCount,
+		MsgCnt:    stats.OutMsgs + stats.InMsgs,
+		MsgBytes:  uint64(jobCount * msgSize),
+		IOBytes:   stats.OutBytes + stats.InBytes,
+		Start:     start,
+		End:       end,
+	}
+}
+
+func renderBenchObjectSizes(sizes []int, rounds []*bench.Benchmark) {
+	table := newTableWriter("Object sizes")
+	table.AddHeaders("Size", "Puts/sec", "Put throughput", "Gets/sec", "Get throughput")
+
+	throughput := func(sg *bench.SampleGroup) (any, any) {
+		if !sg.HasSamples() {
+			return "", ""
+		}
+		return f(sg.Rate()), humanize.IBytes(uint64(sg.Throughput())) + "/sec"
+	}
+
+	for i, bm := range rounds {
+		putRate, putThroughput := throughput(bm.Pubs)
+		getRate, getThroughput := throughput(bm.Subs)
+		table.AddRow(humanize.IBytes(uint64(sizes[i])), putRate, putThroughput, getRate, getThroughput)
+	}
+
+	fmt.Println(table.Render())
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func newBenchStoreCmd() *benchCmd {
+	return &benchCmd{
+		subject:              "bench",
+		noProgress:           true,
+		jsTimeout:            5 * time.Second,
+		storage:              "memory",
+		replicas:             1,
+		streamMaxBytesString: "1GB",
+		bucketName:           DefaultBucketName,
+		consumerName:         DefaultDurableConsumerName,
+		history:              1,
+		casKeys:              10,
+	}
+}
+
+func TestBenchObjectStore(t *testing.T) {
+	withBenchContext(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		file := filepath.Join(t.TempDir(), "results.json")
+
+		put := newBenchStoreCmd()
+		put.obj = true
+		put.numPubs = 2
+		put.numMsg = 10
+		put.msgSizeString = "1KB, 256KB"
+		put.resultsFile = file
+
+		err := put.bench(nil)
+		checkErr(t, err, "put failed: %v", err)
+
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+		obs, err := js.ObjectStore(DefaultBucketName)
+		checkErr(t, err, "object store failed: %v", err)
+		nfo, err := obs.GetInfo("262144/9")
+		checkErr(t, err, "object info failed: %v", err)
+		if nfo.Size != 256*1024 {
+			t.Fatalf("expected a 256KiB object got %d", nfo.Size)
+		}
+
+		res, err := loadBenchResult(file)
+		checkErr(t, err, "load failed: %v", err)
+		if len(res.Workloads) != 2 {
+			t.Fatalf("expected a workload per size got %d", len(res.Workloads))
+		}
+		w := res.workload("NATS 256 KiB")
+		if w == nil || w.Type != "obj" || w.Config.Size != 256*1024 || w.Publish == nil || w.Publish.Messages != 10 {
+			t.Fatalf("invalid workload: %+v", w)
+		}
+
+		get := newBenchStoreCmd()
+		get.obj = true
+		get.numSubs = 2
+		get.numMsg = 10
+		get.msgSizeString = "1KB,256KB"
+
+		err = get.bench(nil)
+		checkErr(t, err, "get failed: %v", err)
+
+		get = newBenchStoreCmd()
+		get.numSubs = 1
+		get.numMsg = 10
+		get.msgSizeString = "1KB,256KB"
+		err = get.bench(nil)
+		if err == nil || err.Error() != "multiple sizes can only be given in --obj mode" {
+			t.Fatalf("expected sizes error got %v", err)
+		}
+	})
+}
+
+func TestBenchKVWatch(t *testing.T) {
+	withBenchContext(t, func(_ *server.Server, _ *nats.Conn, _ *jsm.Manager) {
+		cmd := newBenchStoreCmd()
+		cmd.kv = true
+		cmd.watch = true
+		cmd.numPubs = 2
+		cmd.numSubs = 3
+		cmd.numMsg = 100
+		cmd.msgSizeString = "16"
+
+		err := cmd.bench(nil)
+		checkErr(t, err, "bench failed: %v", err)
+
+		// every watcher receives every put
+		if cmd.subLatency.count() != 300 {
+			t.Fatalf("expected 300 latency samples got %d", cmd.subLatency.count())
+		}
+
+		cmd = newBenchStoreCmd()
+		cmd.watch = true
+		cmd.numSubs = 1
+		cmd.numMsg = 10
+		cmd.msgSizeString = "16"
+		err = cmd.bench(nil)
+		if err == nil || err.Error() != "--watch and --cas can only be used in --kv mode" {
+			t.Fatalf("expected mode error got %v", err)
+		}
+	})
+}
+
+func TestBenchKVCAS(t *testing.T) {
+	withBenchContext(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		cmd := newBenchStoreCmd()
+		cmd.kv = true
+		cmd.cas = true
+		cmd.casKeys = 2
+		cmd.numPubs = 4
+		cmd.numMsg = 200
+		cmd.msgSizeString = "16"
+
+		err := cmd.bench(nil)
+		checkErr(t, err, "bench failed: %v", err)
+
+		stats := cmd.casStats
+		if stats.attempts-stats.conflicts != 200 {
+			t.Fatalf("expected 200 successful updates got %d of %d attempts", stats.attempts-stats.conflicts, stats.attempts)
+		}
+
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+		kv, err := js.KeyValue(DefaultBucketName)
+		checkErr(t, err, "bucket failed: %v", err)
+		keys, err := kv.Keys()
+		checkErr(t, err, "keys failed: %v", err)
+		sort.Strings(keys)
+		assertListEquals(t, keys, "0", "1")
+
+		// every successful update is a revision of one of the keys
+		nfo, err := kv.Status()
+		checkErr(t, err, "status failed: %v", err)
+		if nfo.(*nats.KeyValueBucketStatus).StreamInfo().State.LastSeq != 200 {
+			t.Fatalf("expected 200 revisions got %d", nfo.(*nats.KeyValueBucketStatus).StreamInfo().State.LastSeq)
+		}
+
+		cmd = newBenchStoreCmd()
+		cmd.kv = true
+		cmd.cas = true
+		cmd.numPubs = 1
+		cmd.numSubs = 1
+		cmd.numMsg = 10
+		cmd.msgSizeString = "16"
+		err = cmd.bench(nil)
+		if err == nil || err.Error() != "subscribers can only be used in --cas mode when watching the bucket with --watch" {
+			t.Fatalf("expected subscriber error got %v", err)
+		}
+	})
+}
@@ -0,0 +1,507 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gosuri/uiprogress"
+	"github.com/nats-io/nats.go/bench"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	benchWorkloadPub       = "pub"
+	benchWorkloadSub       = "sub"
+	benchWorkloadRequest   = "request"
+	benchWorkloadReply     = "reply"
+	benchWorkloadJSPub     = "js-pub"
+	benchWorkloadJSOrdered = "js-ordered"
+	benchWorkloadJSPull    = "js-pull"
+	benchWorkloadJSPush    = "js-push"
+	benchWorkloadKVPut     = "kv-put"
+	benchWorkloadKVGet     = "kv-get"
+	benchWorkloadKVWatch   = "kv-watch"
+	benchWorkloadKVUpdate  = "kv-update"
+	benchWorkloadObjPut    = "obj-put"
+	benchWorkloadObjGet    = "obj-get"
+)
+
+var benchWorkloadTypes = []string{benchWorkloadPub, benchWorkloadSub, benchWorkloadRequest, benchWorkloadReply, benchWorkloadJSPub, benchWorkloadJSOrdered, benchWorkloadJSPull, benchWorkloadJSPush, benchWorkloadKVPut, benchWorkloadKVGet, benchWorkloadKVWatch, benchWorkloadKVUpdate, benchWorkloadObjPut, benchWorkloadObjGet}
+
+// benchScenarioTimeout is how long subscribers may go without receiving messages once all publishers completed
+const benchScenarioTimeout = 10 * time.Second
+
+// benchScenario describes a number of workloads that are run concurrently
+type benchScenario struct {
+	Name      string           `yaml:"name"`
+	Timeout   time.Duration    `yaml:"timeout"`
+	Workloads []*benchWorkload `yaml:"workloads"`
+}
+
+type benchWorkload struct {
+	Name         string        `yaml:"name"`
+	Type         string        `yaml:"type"`
+	Clients      int           `yaml:"clients"`
+	Subject      string        `yaml:"subject"`
+	MultiSubject bool          `yaml:"multisubject"`
+	Msgs         int           `yaml:"msgs"`
+	Size         string        `yaml:"size"`
+	Rate         string        `yaml:"rate"`
+	Sleep        time.Duration `yaml:"sleep"`
+	Stream       string        `yaml:"stream"`
+	Consumer     string        `yaml:"consumer"`
+	Bucket       string        `yaml:"bucket"`
+	Batch        int           `yaml:"batch"`
+	SyncPub      bool          `yaml:"syncpub"`
+	Keys         int           `yaml:"keys"`
+}
+
+type benchWorkloadRun struct {
+	workload *benchWorkload
+	cmd      *benchCmd
+	bm       *bench.Benchmark
+}
+
+func loadBenchScenario(file string) (*benchScenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario, err := parseBenchScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", file, err)
+	}
+
+	return scenario, nil
+}
+
+func parseBenchScenario(data []byte) (*benchScenario, error) {
+	scenario := &benchScenario{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(scenario)
+	if err != nil {
+		return nil, err
+	}
+
+	if scenario.Name == "" {
+		scenario.Name = "Scenario"
+	}
+
+	if scenario.Timeout == 0 {
+		scenario.Timeout = benchScenarioTimeout
+	}
+	if scenario.Timeout < 0 {
+		return nil, fmt.Errorf("timeout can not be negative")
+	}
+
+	if len(scenario.Workloads) == 0 {
+		return nil, fmt.Errorf("no workloads defined")
+	}
+
+	names := map[string]bool{}
+	streams := map[string]*benchWorkload{}
+	replies := 0
+
+	for i, w := range scenario.Workloads {
+		if !slices.Contains(benchWorkloadTypes, w.Type) {
+			return nil, fmt.Errorf("workload %d has invalid type %q", i+1, w.Type)
+		}
+
+		if w.Name == "" {
+			w.Name = fmt.Sprintf("%s-%d", w.Type, i+1)
+		}
+		if names[w.Name] {
+			return nil, fmt.Errorf("duplicate workload name %q", w.Name)
+		}
+		names[w.Name] = true
+
+		if w.Clients == 0 {
+			w.Clients = 1
+		}
+		if w.Clients < 0 {
+			return nil, fmt.Errorf("workload %s: clients can not be negative", w.Name)
+		}
+
+		if w.Msgs < 0 {
+			return nil, fmt.Errorf("workload %s: msgs can not be negative", w.Name)
+		}
+
+		if w.Subject == "" && !w.isKV() && !w.isObj() {
+			return nil, fmt.Errorf("workload %s: a subject is required", w.Name)
+		}
+
+		if w.Keys != 0 && w.Type != benchWorkloadKVUpdate {
+			return nil, fmt.Errorf("workload %s: keys can only be set for kv-update workloads", w.Name)
+		}
+
+		if strings.Contains(w.Size, ",") {
+			return nil, fmt.Errorf("workload %s: only a single size can be set", w.Name)
+		}
+
+		if w.Rate != "" {
+			if !w.publishes() {
+				return nil, fmt.Errorf("workload %s: a rate can only be set for publishers", w.Name)
+			}
+
+			_, err = parseRate(w.Rate)
+			if err != nil {
+				return nil, fmt.Errorf("workload %s: %v", w.Name, err)
+			}
+		}
+
+		if w.Type == benchWorkloadReply {
+			replies++
+		}
+
+		// the default stream is defined using the subject of the workload, all workloads using it have to agree on the subject
+		if w.isJS() && (w.Stream == "" || w.Stream == DefaultStreamName) {
+			other, ok := streams[DefaultStreamName]
+			if ok && (other.Subject != w.Subject || other.MultiSubject != w.MultiSubject) {
+				return nil, fmt.Errorf("workloads %s and %s use different subjects in the %s stream, set a stream for one of them", other.Name, w.Name, DefaultStreamName)
+			}
+			streams[DefaultStreamName] = w
+		}
+	}
+
+	if replies == len(scenario.Workloads) {
+		return nil, fmt.Errorf("at least one workload other than reply is required")
+	}
+
+	return scenario, nil
+}
+
+func (w *benchWorkload) isJS() bool {
+	return slices.Contains([]string{benchWorkloadJSPub, benchWorkloadJSOrdered, benchWorkloadJSPull, benchWorkloadJSPush}, w.Type)
+}
+
+func (w *benchWorkload) isKV() bool {
+	return slices.Contains([]string{benchWorkloadKVPut, benchWorkloadKVGet, benchWorkloadKVWatch, benchWorkloadKVUpdate}, w.Type)
+}
+
+func (w *benchWorkload) isObj() bool {
+	return slices.Contains([]string{benchWorkloadObjPut, benchWorkloadObjGet}, w.Type)
+}
+
+func (w *benchWorkload) publishes() bool {
+	return slices.Contains([]string{benchWorkloadPub, benchWorkloadRequest, benchWorkloadJSPub, benchWorkloadKVPut, benchWorkloadKVUpdate, benchWorkloadObjPut}, w.Type)
+}
+
+// benchCmd creates the benchmark settings for the workload, settings not in the workload are taken from defaults
+func (w *benchWorkload) benchCmd(defaults benchCmd, idx int) *benchCmd {
+	c := defaults
+	c.scenario = ""
+	c.conns = nil
+	c.subject = w.Subject
+	c.multiSubject = w.MultiSubject
+	c.numPubs, c.numSubs = 0, 0
+	c.js, c.kv, c.pull, c.pushDurable, c.request, c.reply = false, false, false, false, false, false
+	c.obj, c.watch, c.cas = false, false, false
+
+	if w.Msgs > 0 {
+		c.numMsg = w.Msgs
+	}
+	if w.Size != "" {
+		c.msgSizeString = w.Size
+	}
+	if w.Sleep > 0 {
+		c.subSleep = w.Sleep
+	}
+	if w.Stream != "" {
+		c.streamName = w.Stream
+	}
+	if w.Bucket != "" {
+		c.bucketName = w.Bucket
+	}
+	if w.Batch > 0 {
+		c.consumerBatch = w.Batch
+	}
+	if w.SyncPub {
+		c.syncPub = true
+	}
+	if w.Keys > 0 {
+		c.casKeys = w.Keys
+	}
+
+	if w.Rate != "" {
+		c.rateString = w.Rate
+		c.pubSleep = 0
+	}
+
+	// every workload gets its own durable consumer unless one is named in the scenario
+	if w.Consumer != "" {
+		c.consumerName = w.Consumer
+		c.defineConsumer = w.Consumer == DefaultDurableConsumerName
+	} else {
+		c.consumerName = DefaultDurableConsumerName + "-" + strconv.Itoa(idx+1)
+		c.defineConsumer = true
+	}
+
+	switch w.Type {
+	case benchWorkloadPub:
+		c.numPubs = w.Clients
+	case benchWorkloadSub:
+		c.numSubs = w.Clients
+	case benchWorkloadRequest:
+		c.numPubs = w.Clients
+		c.request = true
+	case benchWorkloadReply:
+		c.numSubs = w.Clients
+		c.reply = true
+	case benchWorkloadJSPub:
+		c.numPubs = w.Clients
+		c.js = true
+	case benchWorkloadJSOrdered:
+		c.numSubs = w.Clients
+		c.js = true
+	case benchWorkloadJSPull:
+		c.numSubs = w.Clients
+		c.js = true
+		c.pull = true
+	case benchWorkloadJSPush:
+		c.numSubs = w.Clients
+		c.js = true
+		c.pushDurable = true
+	case benchWorkloadKVPut:
+		c.numPubs = w.Clients
+		c.kv = true
+	case benchWorkloadKVGet:
+		c.numSubs = w.Clients
+		c.kv = true
+	case benchWorkloadKVWatch:
+		c.numSubs = w.Clients
+		c.kv = true
+		c.watch = true
+	case benchWorkloadKVUpdate:
+		c.numPubs = w.Clients
+		c.kv = true
+		c.cas = true
+	case benchWorkloadObjPut:
+		c.numPubs = w.Clients
+		c.obj = true
+	case benchWorkloadObjGet:
+		c.numSubs = w.Clients
+		c.obj = true
+	}
+
+	return &c
+}
+
+func (c *benchCmd) runScenario() error {
+	scenario, err := loadBenchScenario(c.scenario)
+	if err != nil {
+		return err
+	}
+
+	var runs []*benchWorkloadRun
+	stop := make(chan struct{})
+	received := &atomic.Uint64{}
+
+	for i, w := range scenario.Workloads {
+		wc := w.benchCmd(*c, i)
+
+		err = wc.validate()
+		if err != nil {
+			return fmt.Errorf("workload %s: %v", w.Name, err)
+		}
+
+		wc.stop = stop
+		wc.receivedTotal = received
+		wc.misses = &atomic.Uint64{}
+		wc.pubLatency, wc.subLatency, wc.casStats = newBenchLatency(), newBenchLatency(), &benchCAS{}
+
+		runs = append(runs, &benchWorkloadRun{
+			workload: w,
+			cmd:      wc,
+			bm:       bench.NewBenchmark(w.Name, wc.numSubs, wc.numPubs),
+		})
+	}
+
+	log.Printf("Starting scenario %s with %d workloads", scenario.Name, len(runs))
+	for _, run := range runs {
+		rate := "unlimited"
+		if run.workload.Rate != "" {
+			rate = run.workload.Rate + " per client"
+		}
+		target := "subject=" + getSubscribeSubject(run.cmd)
+		if run.cmd.kv || run.cmd.obj {
+			target = "bucket=" + run.cmd.bucketName
+		}
+		log.Printf("Workload %s [type=%s, clients=%d, %s, msgs=%s, msgsize=%s, rate=%s]", run.workload.Name, run.workload.Type, run.workload.Clients, target, f(run.cmd.numMsg), humanize.IBytes(uint64(run.cmd.msgSize)), rate)
+	}
+
+	benchId := strconv.FormatInt(time.Now().UnixMilli(), 16)
+
+	startwg := &sync.WaitGroup{}
+	donewg := &sync.WaitGroup{}
+	pubwg := &sync.WaitGroup{}
+	replywg := &sync.WaitGroup{}
+
+	for _, run := range runs {
+		if run.cmd.js || run.cmd.kv || run.cmd.obj {
+			defer run.cmd.prepareJetStream()()
+		}
+	}
+
+	for _, run := range runs {
+		defer run.cmd.closeConnections()
+
+		wg := donewg
+		if run.cmd.reply {
+			wg = replywg
+		}
+
+		err = run.cmd.startSubscribers(run.bm, startwg, wg)
+		if err != nil {
+			return err
+		}
+	}
+	startwg.Wait()
+
+	trigger := make(chan struct{})
+	progress := false
+	for _, run := range runs {
+		err = run.cmd.startPublishers(run.bm, startwg, pubwg, trigger, benchId)
+		if err != nil {
+			return err
+		}
+		progress = progress || !run.cmd.noProgress
+	}
+
+	if progress {
+		uiprogress.Start()
+	}
+
+	startwg.Wait()
+	close(trigger)
+	pubwg.Wait()
+	waitBenchSubscribers(donewg, received, scenario.Timeout)
+	close(stop)
+	donewg.Wait()
+	replywg.Wait()
+
+	if progress {
+		uiprogress.Stop()
+	}
+
+	combined := bench.NewBenchmark(scenario.Name, 0, 0)
+	for _, run := range runs {
+		run.bm.Close()
+		run.cmd.printWarnings()
+
+		for _, s := range run.bm.Pubs.Samples {
+			combined.Pubs.AddSample(s)
+		}
+		for _, s := range run.bm.Subs.Samples {
+			combined.Subs.AddSample(s)
+		}
+	}
+	combined.Close()
+
+	for _, run := range runs {
+		fmt.Println()
+		fmt.Printf("Workload %s (%s)\n", run.workload.Name, run.workload.Type)
+		fmt.Println(run.bm.Report())
+		renderBenchLatency(run.cmd.pubLatencyLabel(), run.cmd.pubLatency, run.cmd.subLatency)
+		run.cmd.casStats.render()
+	}
+
+	renderBenchScenario(combined, runs)
+
+	if c.csvFile != "" {
+		err := os.WriteFile(c.csvFile, []byte(combined.CSV()), 0644)
+		if err != nil {
+			log.Printf("error writing file %s: %v", c.csvFile, err)
+		}
+		fmt.Printf("Saved metric data in csv file %s\n", c.csvFile)
+	}
+
+	if c.resultsFile != "" {
+		var servers []*benchResultServer
+		for _, run := range runs {
+			servers = append(servers, run.cmd.servers()...)
+		}
+
+		res := newBenchResult(scenario.Name, servers)
+		for _, run := range runs {
+			res.Workloads = append(res.Workloads, run.cmd.resultWorkload(run.workload.Name, run.workload.Type, run.workload.Clients, run.bm))
+		}
+
+		err = res.save(c.resultsFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved results in %s\n", c.resultsFile)
+	}
+
+	return nil
+}
+
+// waitBenchSubscribers waits for subscribers to complete, giving up once no messages were received for timeout
+func waitBenchSubscribers(donewg *sync.WaitGroup, received *atomic.Uint64, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		donewg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(timeout)
+	defer ticker.Stop()
+
+	last := received.Load()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			current := received.Load()
+			if current == last {
+				log.Printf("Stopping subscribers that received no messages for %v after all publishers completed", timeout)
+				return
+			}
+			last = current
+		}
+	}
+}
+
+func renderBenchScenario(combined *bench.Benchmark, runs []*benchWorkloadRun) {
+	table := newTableWriter(fmt.Sprintf("Scenario %s", combined.Name))
+	table.AddHeaders("Workload", "Type", "Clients", "Messages", "Msgs/sec", "Throughput", "Duration")
+	clients := 0
+	for _, run := range runs {
+		clients += run.workload.Clients
+
+		if run.bm.JobMsgCnt == 0 {
+			table.AddRow(run.workload.Name, run.workload.Type, run.workload.Clients, 0, "", "", "")
+			continue
+		}
+		table.AddRow(run.workload.Name, run.workload.Type, run.workload.Clients, f(run.bm.JobMsgCnt), f(run.bm.Rate()), humanize.IBytes(uint64(run.bm.Throughput()))+"/sec", f(run.bm.Duration()))
+	}
+	table.AddFooter("Total", "", clients, f(combined.JobMsgCnt), f(combined.Rate()), humanize.IBytes(uint64(combined.Throughput()))+"/sec", f(combined.Duration()))
+
+	fmt.Println()
+	fmt.Println(table.Render())
+}
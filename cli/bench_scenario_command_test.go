@@ -0,0 +1,243 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/natscontext"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestParseBenchScenario(t *testing.T) {
+	scenario, err := parseBenchScenario([]byte(`
+name: orders
+workloads:
+  - name: publishers
+    type: js-pub
+    clients: 5
+    subject: orders
+    multisubject: true
+    rate: 2000/s
+  - name: processors
+    type: js-pull
+    clients: 10
+    subject: orders
+    multisubject: true
+  - type: request
+    clients: 3
+    subject: api
+  - type: kv-put
+    bucket: CONFIG
+  - type: kv-update
+    keys: 5
+  - type: obj-put
+    size: 1MB
+`))
+	checkErr(t, err, "parse failed: %v", err)
+
+	if scenario.Name != "orders" || len(scenario.Workloads) != 6 || scenario.Timeout != benchScenarioTimeout {
+		t.Fatalf("invalid scenario: %+v", scenario)
+	}
+	if scenario.Workloads[2].Name != "request-3" || scenario.Workloads[3].Clients != 1 {
+		t.Fatalf("defaults were not set: %+v %+v", scenario.Workloads[2], scenario.Workloads[3])
+	}
+
+	defaults := benchCmd{numMsg: 1000, msgSizeString: "128", consumerName: DefaultDurableConsumerName, streamName: DefaultStreamName, bucketName: DefaultBucketName}
+
+	pub := scenario.Workloads[0].benchCmd(defaults, 0)
+	if !pub.js || pub.numPubs != 5 || pub.numSubs != 0 || pub.rateString != "2000/s" || getSubscribeSubject(pub) != "orders.*" {
+		t.Fatalf("invalid publisher settings: %+v", pub)
+	}
+
+	pull := scenario.Workloads[1].benchCmd(defaults, 1)
+	if !pull.js || !pull.pull || pull.numSubs != 10 || pull.consumerName != "natscli-bench-2" || !pull.defineConsumer {
+		t.Fatalf("invalid pull consumer settings: %+v", pull)
+	}
+
+	kv := scenario.Workloads[3].benchCmd(defaults, 3)
+	if !kv.kv || kv.bucketName != "CONFIG" || kv.numMsg != 1000 {
+		t.Fatalf("invalid kv settings: %+v", kv)
+	}
+
+	update := scenario.Workloads[4].benchCmd(defaults, 4)
+	if !update.kv || !update.cas || update.watch || update.casKeys != 5 || update.numPubs != 1 {
+		t.Fatalf("invalid kv update settings: %+v", update)
+	}
+
+	obj := scenario.Workloads[5].benchCmd(defaults, 5)
+	if !obj.obj || obj.kv || obj.msgSizeString != "1MB" || obj.numPubs != 1 {
+		t.Fatalf("invalid object settings: %+v", obj)
+	}
+
+	for _, tc := range []struct {
+		scenario string
+		err      string
+	}{
+		{"name: x", "no workloads defined"},
+		{"workloads: [{type: bogus}]", `invalid type "bogus"`},
+		{"workloads: [{type: pub}]", "a subject is required"},
+		{"workloads: [{type: sub, subject: x, rate: 10}]", "rate can only be set for publishers"},
+		{"workloads: [{type: pub, subject: x, rate: fast}]", `invalid rate "fast"`},
+		{"workloads: [{type: reply, subject: x}]", "other than reply"},
+		{"workloads: [{type: pub, subject: x, name: a}, {type: sub, subject: x, name: a}]", "duplicate workload name"},
+		{"workloads: [{type: js-pub, subject: x}, {type: js-pull, subject: y}]", "use different subjects"},
+		{"workloads: [{type: pub, subject: x, bogus: 1}]", "field bogus not found"},
+		{"workloads: [{type: kv-put, keys: 5}]", "keys can only be set for kv-update"},
+		{"workloads: [{type: obj-put, size: '1KB,1MB'}]", "only a single size"},
+		{"{timeout: -1s, workloads: [{type: pub, subject: x}]}", "timeout can not be negative"},
+	} {
+		_, err = parseBenchScenario([]byte(tc.scenario))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("expected error %q for %q, got %v", tc.err, tc.scenario, err)
+		}
+	}
+}
+
+// withBenchContext runs cb with a JetStream server and a context for nats bench to connect to it
+func withBenchContext(t *testing.T, cb func(srv *server.Server, nc *nats.Conn, mgr *jsm.Manager)) {
+	t.Helper()
+
+	withJetStream(t, func(srv *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		var err error
+
+		SetLogger(goLogger{})
+		SetContext(context.Background())
+
+		config := opts.Config
+		opts.Config, err = natscontext.New("bench", false, natscontext.WithServerURL(srv.ClientURL()))
+		checkErr(t, err, "context failed: %v", err)
+		timeout := opts.Timeout
+		opts.Timeout = 5 * time.Second
+		defer func() { opts.Config, opts.Timeout = config, timeout }()
+
+		cb(srv, nc, mgr)
+	})
+}
+
+func TestBenchScenario(t *testing.T) {
+	withBenchContext(t, func(srv *server.Server, _ *nats.Conn, mgr *jsm.Manager) {
+		file := filepath.Join(t.TempDir(), "scenario.yaml")
+		err := os.WriteFile(file, []byte(`
+name: mixed
+workloads:
+  - name: orders
+    type: js-pub
+    clients: 2
+    subject: orders
+    multisubject: true
+  - name: processors
+    type: js-pull
+    clients: 2
+    subject: orders
+    multisubject: true
+  - name: api
+    type: request
+    clients: 2
+    subject: api
+  - name: responders
+    type: reply
+    clients: 2
+    subject: api
+  - name: config
+    type: kv-put
+    msgs: 100
+`), 0600)
+		checkErr(t, err, "write failed: %v", err)
+
+		cmd := &benchCmd{
+			scenario:             file,
+			numMsg:               1000,
+			msgSizeString:        "128",
+			noProgress:           true,
+			jsTimeout:            5 * time.Second,
+			storage:              "memory",
+			replicas:             1,
+			streamName:           DefaultStreamName,
+			streamMaxBytesString: "1GB",
+			bucketName:           DefaultBucketName,
+			consumerName:         DefaultDurableConsumerName,
+			consumerBatch:        100,
+			pubBatch:             100,
+			history:              1,
+			multiSubjectMax:      100,
+		}
+
+		err = cmd.runScenario()
+		checkErr(t, err, "scenario failed: %v", err)
+
+		stream, err := mgr.LoadStream(DefaultStreamName)
+		checkErr(t, err, "stream load failed: %v", err)
+		nfo, err := stream.LatestInformation()
+		checkErr(t, err, "stream info failed: %v", err)
+		if nfo.State.Msgs != 1000 {
+			t.Fatalf("expected 1000 messages got %d", nfo.State.Msgs)
+		}
+
+		consumers, err := stream.ConsumerNames()
+		checkErr(t, err, "consumer names failed: %v", err)
+		if len(consumers) != 0 {
+			t.Fatalf("consumers were not removed: %v", consumers)
+		}
+
+		kv, err := mgr.LoadStream("KV_" + DefaultBucketName)
+		checkErr(t, err, "bucket load failed: %v", err)
+		nfo, err = kv.LatestInformation()
+		checkErr(t, err, "bucket info failed: %v", err)
+		if nfo.State.Msgs != 100 {
+			t.Fatalf("expected 100 keys got %d", nfo.State.Msgs)
+		}
+
+		// subscribers expecting more messages than were published are stopped
+		err = os.WriteFile(file, []byte(`
+name: short
+timeout: 200ms
+workloads:
+  - type: pub
+    subject: short
+    msgs: 100
+  - type: sub
+    subject: short
+    msgs: 200
+  - type: js-pull
+    subject: orders
+    multisubject: true
+    msgs: 2000
+  - type: kv-watch
+  - type: kv-get
+    msgs: 200
+  - type: obj-get
+    msgs: 10
+`), 0600)
+		checkErr(t, err, "write failed: %v", err)
+
+		cmd.resultsFile = filepath.Join(t.TempDir(), "results.json")
+		err = cmd.runScenario()
+		checkErr(t, err, "scenario failed: %v", err)
+
+		res, err := loadBenchResult(cmd.resultsFile)
+		checkErr(t, err, "load failed: %v", err)
+		sub := res.workload("sub-2")
+		if sub == nil || sub.Subscribe == nil || sub.Subscribe.Messages != 100 {
+			t.Fatalf("invalid subscriber results: %+v", sub)
+		}
+	})
+}
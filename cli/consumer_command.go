@@ -16,6 +16,7 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -47,6 +48,7 @@ type consumerCmd struct {
 	ackSetByUser   bool
 	term           bool
 	raw            bool
+	interactive    bool
 	destination    string
 	inputFile      string
 	outFile        string
@@ -91,6 +93,8 @@ type consumerCmd struct {
 	metadataIsSet       bool
 	metadata            map[string]string
 	pauseUntil          string
+	resetSeq            uint64
+	resetTime           string
 
 	dryRun bool
 	mgr    *jsm.Manager
@@ -203,6 +207,7 @@ func configureConsumerCommand(app commandHost) {
 	consNext.Flag("raw", "Show only the message").Short('r').UnNegatableBoolVar(&c.raw)
 	consNext.Flag("wait", "Wait up to this period to acknowledge messages").DurationVar(&c.ackWait)
 	consNext.Flag("count", "Number of messages to try to fetch from the pull consumer").Default("1").IntVar(&c.pullCount)
+	consNext.Flag("interactive", "Prompt for an acknowledgement action on every message until no more are received").Short('i').UnNegatableBoolVar(&c.interactive)
 
 	consSub := cons.Command("sub", "Retrieves messages from Consumers").Action(c.subAction)
 	consSub.Arg("stream", "Stream name").StringVar(&c.stream)
@@ -222,11 +227,33 @@ func configureConsumerCommand(app commandHost) {
 	conResume.Arg("consumer", "Consumer name").StringVar(&c.consumer)
 	conResume.Flag("force", "Force resume without prompting").Short('f').UnNegatableBoolVar(&c.force)
 
+	conReset := cons.Command("reset", "Moves a durable Consumer back to a sequence or time by recreating it").Alias("rewind").Action(c.resetAction)
+	conReset.HelpLong(`The Consumer is removed and created again using the same configuration
+with a new delivery policy. This is not atomic, clients bound to the Consumer
+will receive errors while it does not exist.
+
+The new configuration is validated before the Consumer is removed, should
+creating the new Consumer still fail the original configuration is created
+again delivering messages after its acknowledgment floor, unacknowledged
+messages will then be delivered again.
+
+The time can be given as a timestamp like 2006-01-02 15:04:05, which is taken
+to be in UTC, or RFC3339 or as a duration like 1h30m meaning that long ago.`)
+	conReset.Arg("stream", "Stream name").StringVar(&c.stream)
+	conReset.Arg("consumer", "Consumer name").StringVar(&c.consumer)
+	conReset.Flag("to-seq", "Deliver messages starting at this stream sequence").PlaceHolder("SEQUENCE").Uint64Var(&c.resetSeq)
+	conReset.Flag("to-time", "Deliver messages received since a time or duration").PlaceHolder("TIME").StringVar(&c.resetTime)
+	conReset.Flag("force", "Force reset without prompting").Short('f').UnNegatableBoolVar(&c.force)
+
 	conReport := cons.Command("report", "Reports on Consumer statistics").Action(c.reportAction)
 	conReport.Arg("stream", "Stream name").StringVar(&c.stream)
 	conReport.Flag("raw", "Show un-formatted numbers").Short('r').UnNegatableBoolVar(&c.raw)
 	conReport.Flag("leaders", "Show details about the leaders").Short('l').UnNegatableBoolVar(&c.reportLeaderDistrib)
 
+	configureConsumerDLQCommand(cons)
+	configureConsumerWatchCommand(cons)
+	configureConsumerPendingCommand(cons)
+
 	conCluster := cons.Command("cluster", "Manages a clustered Consumer").Alias("c")
 	conClusterDown := conCluster.Command("step-down", "Force a new leader election by standing down the current leader").Alias("elect").Alias("down").Alias("d").Action(c.leaderStandDown)
 	conClusterDown.Arg("stream", "Stream to act on").StringVar(&c.stream)
@@ -1389,6 +1416,150 @@ func (c *consumerCmd) pauseAction(_ *fisk.ParseContext) error {
 	return nil
 }
 
+func (c *consumerCmd) parseResetTime(t string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateTime} {
+		ts, err := time.Parse(layout, t)
+		if err == nil {
+			return ts, nil
+		}
+	}
+
+	d, err := parseDurationString(t)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse the time as either timestamp or duration")
+	}
+
+	return time.Now().Add(-d), nil
+}
+
+// resetConfig returns cfg with a delivery policy starting at the requested sequence or time
+func (c *consumerCmd) resetConfig(cfg api.ConsumerConfig) (api.ConsumerConfig, error) {
+	cfg.OptStartSeq = 0
+	cfg.OptStartTime = nil
+
+	switch {
+	case c.resetSeq > 0 && c.resetTime != "":
+		return cfg, fmt.Errorf("--to-seq and --to-time can not be used together")
+
+	case c.resetSeq > 0:
+		cfg.DeliverPolicy = api.DeliverByStartSequence
+		cfg.OptStartSeq = c.resetSeq
+
+	case c.resetTime != "":
+		ts, err := c.parseResetTime(c.resetTime)
+		if err != nil {
+			return cfg, err
+		}
+		ts = ts.UTC()
+		cfg.DeliverPolicy = api.DeliverByStartTime
+		cfg.OptStartTime = &ts
+
+	default:
+		return cfg, fmt.Errorf("either --to-seq or --to-time is required")
+	}
+
+	return cfg, nil
+}
+
+func (c *consumerCmd) resetAction(_ *fisk.ParseContext) error {
+	c.connectAndSetup(true, true)
+
+	if !c.selectedConsumer.IsDurable() {
+		return fmt.Errorf("only durable consumers can be reset")
+	}
+
+	cfg := c.selectedConsumer.Configuration()
+	ncfg, err := c.resetConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	if !c.force {
+		ok, err := askConfirmation(fmt.Sprintf("Really reset Consumer %s > %s by deleting and recreating it, bound clients will see errors until it is recreated and will receive messages again", c.stream, c.consumer), false)
+		fisk.FatalIfError(err, "could not obtain confirmation")
+
+		if !ok {
+			return nil
+		}
+	}
+
+	// the acknowledgment floor is needed should the consumer have to be restored, so avoid cached state
+	before, err := c.selectedConsumer.State()
+	if err != nil {
+		return err
+	}
+
+	after, err := c.resetConsumer(before, ncfg)
+	if err != nil {
+		return err
+	}
+
+	startPolicy := func(cfg api.ConsumerConfig) string {
+		switch cfg.DeliverPolicy {
+		case api.DeliverByStartSequence:
+			return fmt.Sprintf("from sequence %d", cfg.OptStartSeq)
+		case api.DeliverByStartTime:
+			return fmt.Sprintf("since %s", f(*cfg.OptStartTime))
+		default:
+			return cfg.DeliverPolicy.String()
+		}
+	}
+
+	table := newTableWriter(fmt.Sprintf("Consumer %s > %s was reset", c.stream, c.consumer))
+	table.AddHeaders("", "Before", "After")
+	table.AddRow("Deliver Policy", startPolicy(before.Config), startPolicy(after.Config))
+	table.AddRow("Last Delivered", f(before.Delivered.Stream), f(after.Delivered.Stream))
+	table.AddRow("Acknowledgment Floor", f(before.AckFloor.Stream), f(after.AckFloor.Stream))
+	table.AddRow("Unprocessed Messages", f(before.NumPending), f(after.NumPending))
+	table.AddRow("Outstanding Acks", f(before.NumAckPending), f(after.NumAckPending))
+	table.AddRow("Redelivered Messages", f(before.NumRedelivered), f(after.NumRedelivered))
+	fmt.Println(table.Render())
+
+	return nil
+}
+
+// resetConsumer recreates the selected consumer using ncfg, when that fails the consumer is recreated using its
+// original configuration delivering messages after the acknowledgment floor recorded in before
+func (c *consumerCmd) resetConsumer(before api.ConsumerInfo, ncfg api.ConsumerConfig) (*api.ConsumerInfo, error) {
+	valid, _, errs, err := c.validateCfg(&ncfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not validate Consumer configuration, the Consumer was not changed: %v", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid Consumer configuration, the Consumer was not changed: %s", strings.Join(errs, ", "))
+	}
+
+	err = c.selectedConsumer.Delete()
+	if err != nil {
+		return nil, fmt.Errorf("could not remove Consumer: %v", err)
+	}
+
+	consumer, err := c.mgr.NewConsumerFromDefault(c.stream, ncfg)
+	if err != nil {
+		rcfg := before.Config
+		rcfg.DeliverPolicy = api.DeliverByStartSequence
+		rcfg.OptStartSeq = before.AckFloor.Stream + 1
+		rcfg.OptStartTime = nil
+
+		restored, rerr := c.mgr.NewConsumerFromDefault(c.stream, rcfg)
+		if rerr != nil {
+			return nil, fmt.Errorf("reset failed: %v, the Consumer was removed and recreating it also failed: %v", err, rerr)
+		}
+		c.selectedConsumer = restored
+
+		return nil, fmt.Errorf("reset failed: %v, the Consumer was recreated using its original configuration delivering messages from sequence %d after its acknowledgment floor, unacknowledged messages will be delivered again", err, rcfg.OptStartSeq)
+	}
+
+	c.selectedConsumer = consumer
+
+	nfo, err := consumer.LatestState()
+	if err != nil {
+		return nil, err
+	}
+
+	return &nfo, nil
+}
+
 func (c *consumerCmd) askBackoffPolicy() error {
 	ok, err := askConfirmation("Add a Retry Backoff Policy", false)
 	if err != nil {
@@ -1551,39 +1722,7 @@ func (c *consumerCmd) getNextMsgDirect(stream string, consumer string) error {
 		fatalIfNotPull()
 	}
 
-	if !c.raw {
-		info, err := jsm.ParseJSMsgMetadata(msg)
-		if err != nil {
-			if msg.Reply == "" {
-				fmt.Printf("--- subject: %s\n", msg.Subject)
-			} else {
-				fmt.Printf("--- subject: %s reply: %s\n", msg.Subject, msg.Reply)
-			}
-
-		} else {
-			fmt.Printf("[%s] subj: %s / tries: %d / cons seq: %d / str seq: %d / pending: %s\n", time.Now().Format("15:04:05"), msg.Subject, info.Delivered(), info.ConsumerSequence(), info.StreamSequence(), f(info.Pending()))
-		}
-
-		if len(msg.Header) > 0 {
-			fmt.Println()
-			fmt.Println("Headers:")
-			fmt.Println()
-			for h, vals := range msg.Header {
-				for _, val := range vals {
-					fmt.Printf("  %s: %s\n", h, val)
-				}
-			}
-
-			fmt.Println()
-			fmt.Println("Data:")
-			fmt.Println()
-		}
-
-		fmt.Println()
-		fmt.Println(string(msg.Data))
-	} else {
-		fmt.Println(string(msg.Data))
-	}
+	c.renderNextMsg(msg)
 
 	if c.term {
 		err = msg.Term()
@@ -1633,6 +1772,225 @@ func (c *consumerCmd) getNextMsgDirect(stream string, consumer string) error {
 	return nil
 }
 
+type consumerInteractiveTally struct {
+	acked      int
+	naked      int
+	termed     int
+	progressed int
+	skipped    int
+}
+
+func (t *consumerInteractiveTally) String() string {
+	return fmt.Sprintf("Acknowledged: %d Negatively Acknowledged: %d Terminated: %d In Progress: %d Skipped: %d", t.acked, t.naked, t.termed, t.progressed, t.skipped)
+}
+
+// interactiveNext fetches messages one at a time and prompts for how each should be acknowledged
+func (c *consumerCmd) interactiveNext() error {
+	if c.nak || c.term {
+		return fmt.Errorf("--interactive can not be used with --nak or --term")
+	}
+
+	if c.selectedConsumer == nil {
+		return fmt.Errorf("could not load Consumer %s > %s", c.stream, c.consumer)
+	}
+	if !c.selectedConsumer.IsPullMode() {
+		return fmt.Errorf("consumer %s > %s is not a Pull consumer", c.stream, c.consumer)
+	}
+	if c.selectedConsumer.AckPolicy() == api.AckNone {
+		return fmt.Errorf("consumer %s > %s does not acknowledge messages", c.stream, c.consumer)
+	}
+
+	tally := &consumerInteractiveTally{}
+	defer func() {
+		fmt.Println()
+		fmt.Println(tally)
+	}()
+
+	for {
+		msg, err := c.requestNextMsg(c.stream, c.consumer)
+		if err != nil {
+			return err
+		}
+		if msg == nil {
+			fmt.Println("No more messages received")
+			return nil
+		}
+
+		c.renderNextMsg(msg)
+		fmt.Println()
+
+		for {
+			action := "a"
+			err = askOne(&survey.Input{
+				Message: "[a]ck, [n]ak, [d]elayed nak, [t]erm, [p]rogress, [s]kip or [q]uit",
+				Default: "a",
+			}, &action, survey.WithValidator(validateInteractiveAction))
+			if err != nil {
+				return err
+			}
+
+			action = strings.ToLower(strings.TrimSpace(action))
+			if action == "q" {
+				return nil
+			}
+
+			var delay time.Duration
+			var reason string
+
+			switch action {
+			case "d":
+				val := ""
+				err = askOne(&survey.Input{Message: "Redelivery delay", Default: "30s"}, &val, survey.WithValidator(survey.Required))
+				if err != nil {
+					return err
+				}
+				delay, err = parseDurationString(val)
+				if err != nil {
+					return err
+				}
+			case "t":
+				err = askOne(&survey.Input{Message: "Termination reason"}, &reason)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = c.interactiveRespond(msg, action, delay, reason, tally)
+			if err != nil {
+				return err
+			}
+
+			// in progress extends the ack wait, the message still needs a final decision
+			if action != "p" {
+				break
+			}
+		}
+
+		fmt.Println(tally)
+		fmt.Println()
+	}
+}
+
+func validateInteractiveAction(v any) error {
+	switch strings.ToLower(strings.TrimSpace(v.(string))) {
+	case "a", "n", "d", "t", "p", "s", "q":
+		return nil
+	default:
+		return fmt.Errorf("unknown action, valid actions are a, n, d, t, p, s and q")
+	}
+}
+
+// interactiveRespond sends the acknowledgement matching action and records it in the tally
+func (c *consumerCmd) interactiveRespond(msg *nats.Msg, action string, delay time.Duration, reason string, tally *consumerInteractiveTally) error {
+	var body []byte
+
+	switch action {
+	case "a":
+		body = api.AckAck
+		tally.acked++
+	case "n":
+		body = api.AckNak
+		tally.naked++
+	case "d":
+		body = []byte(fmt.Sprintf("%s {\"delay\": %d}", api.AckNak, delay))
+		tally.naked++
+	case "t":
+		body = api.AckTerm
+		if reason != "" {
+			body = []byte(fmt.Sprintf("%s %s", api.AckTerm, reason))
+		}
+		tally.termed++
+	case "p":
+		body = api.AckProgress
+		tally.progressed++
+	case "s":
+		tally.skipped++
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	if opts.Trace {
+		log.Printf(">>> %s: %s", msg.Reply, string(body))
+	}
+
+	err := msg.Respond(body)
+	if err != nil {
+		return err
+	}
+
+	return c.nc.Flush()
+}
+
+// requestNextMsg requests a single message from a pull consumer, returns nil when none arrived before the timeout
+func (c *consumerCmd) requestNextMsg(stream string, consumer string) (*nats.Msg, error) {
+	req := &api.JSApiConsumerGetNextRequest{Batch: 1, Expires: opts.Timeout}
+
+	sub, err := c.nc.SubscribeSync(c.nc.NewRespInbox())
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	err = c.mgr.NextMsgRequest(stream, consumer, sub.Subject, req)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := sub.NextMsg(opts.Timeout + time.Second)
+	switch {
+	case errors.Is(err, nats.ErrTimeout):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	switch msg.Header.Get("Status") {
+	case "":
+		return msg, nil
+	case "404", "408":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("could not request next message: %s %s", msg.Header.Get("Status"), msg.Header.Get("Description"))
+	}
+}
+
+func (c *consumerCmd) renderNextMsg(msg *nats.Msg) {
+	if !c.raw {
+		info, err := jsm.ParseJSMsgMetadata(msg)
+		if err != nil {
+			if msg.Reply == "" {
+				fmt.Printf("--- subject: %s\n", msg.Subject)
+			} else {
+				fmt.Printf("--- subject: %s reply: %s\n", msg.Subject, msg.Reply)
+			}
+
+		} else {
+			fmt.Printf("[%s] subj: %s / tries: %d / cons seq: %d / str seq: %d / pending: %s\n", time.Now().Format("15:04:05"), msg.Subject, info.Delivered(), info.ConsumerSequence(), info.StreamSequence(), f(info.Pending()))
+		}
+
+		if len(msg.Header) > 0 {
+			fmt.Println()
+			fmt.Println("Headers:")
+			fmt.Println()
+			for h, vals := range msg.Header {
+				for _, val := range vals {
+					fmt.Printf("  %s: %s\n", h, val)
+				}
+			}
+
+			fmt.Println()
+			fmt.Println("Data:")
+			fmt.Println()
+		}
+
+		fmt.Println()
+		fmt.Println(string(msg.Data))
+	} else {
+		fmt.Println(string(msg.Data))
+	}
+}
+
 func (c *consumerCmd) subscribeConsumer(consumer *jsm.Consumer) (err error) {
 	if !c.raw {
 		fmt.Printf("Subscribing to topic %s auto acknowledgment: %v\n\n", consumer.DeliverySubject(), c.ack)
@@ -1746,6 +2104,10 @@ func (c *consumerCmd) subAction(_ *fisk.ParseContext) error {
 func (c *consumerCmd) nextAction(_ *fisk.ParseContext) error {
 	c.connectAndSetup(false, false, nats.UseOldRequestStyle())
 
+	if c.interactive {
+		return c.interactiveNext()
+	}
+
 	var err error
 
 	for i := 0; i < c.pullCount; i++ {
@@ -0,0 +1,170 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestConsumerReset(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		stream, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		consumer, err := stream.NewConsumer(jsm.DurableName("PROCESSOR"), jsm.AcknowledgeExplicit(), jsm.MaxDeliveryAttempts(10), jsm.ConsumerDescription("orders processor"))
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 5; i++ {
+			_, err = js.Publish(fmt.Sprintf("orders.%d", i), []byte(fmt.Sprintf("order %d", i)))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		sub, err := js.PullSubscribe("", "", nats.Bind("ORDERS", "PROCESSOR"))
+		checkErr(t, err, "subscribe failed: %v", err)
+		msgs, err := sub.Fetch(5)
+		checkErr(t, err, "fetch failed: %v", err)
+		for _, msg := range msgs {
+			checkErr(t, msg.AckSync(), "ack failed")
+		}
+
+		c := &consumerCmd{mgr: mgr, stream: "ORDERS", consumer: "PROCESSOR", selectedConsumer: consumer, resetSeq: 3}
+		cfg := consumer.Configuration()
+		ncfg, err := c.resetConfig(cfg)
+		checkErr(t, err, "config failed: %v", err)
+
+		before, err := consumer.State()
+		checkErr(t, err, "state failed: %v", err)
+		nfo, err := c.resetConsumer(before, ncfg)
+		checkErr(t, err, "reset failed: %v", err)
+		if nfo.NumPending != 3 || nfo.Config.DeliverPolicy != api.DeliverByStartSequence || nfo.Config.OptStartSeq != 3 {
+			t.Fatalf("invalid state after reset: %+v", nfo)
+		}
+		if nfo.Config.MaxDeliver != 10 || nfo.Config.Description != "orders processor" || nfo.Config.AckPolicy != api.AckExplicit {
+			t.Fatalf("configuration was not preserved: %+v", nfo.Config)
+		}
+
+		c.resetSeq = 0
+		c.resetTime = "1h"
+		cfg = c.selectedConsumer.Configuration()
+		ncfg, err = c.resetConfig(cfg)
+		checkErr(t, err, "config failed: %v", err)
+		if ncfg.DeliverPolicy != api.DeliverByStartTime || ncfg.OptStartSeq != 0 || time.Since(*ncfg.OptStartTime) < time.Hour {
+			t.Fatalf("invalid time based config: %+v", ncfg)
+		}
+
+		ncfg.DeliverPolicy = api.DeliverByStartSequence
+		before, err = c.selectedConsumer.State()
+		checkErr(t, err, "state failed: %v", err)
+		_, err = c.resetConsumer(before, ncfg)
+		if err == nil || !strings.Contains(err.Error(), "the Consumer was not changed") {
+			t.Fatalf("expected the reset to fail validation: %v", err)
+		}
+
+		nfo2, err := js.ConsumerInfo("ORDERS", "PROCESSOR")
+		checkErr(t, err, "the consumer was removed: %v", err)
+		if nfo2.Config.OptStartSeq != 3 || nfo2.Config.DeliverPolicy != nats.DeliverByStartSequencePolicy {
+			t.Fatalf("invalid consumer: %+v", nfo2.Config)
+		}
+
+		// a config the server rejects restores the consumer after its acknowledgment floor
+		sub, err = js.PullSubscribe("", "", nats.Bind("ORDERS", "PROCESSOR"))
+		checkErr(t, err, "subscribe failed: %v", err)
+		msgs, err = sub.Fetch(1)
+		checkErr(t, err, "fetch failed: %v", err)
+		checkErr(t, msgs[0].AckSync(), "ack failed")
+
+		ncfg, err = c.resetConfig(c.selectedConsumer.Configuration())
+		checkErr(t, err, "config failed: %v", err)
+		ncfg.DeliverSubject = "deliver.orders"
+		before, err = c.selectedConsumer.State()
+		checkErr(t, err, "state failed: %v", err)
+		_, err = c.resetConsumer(before, ncfg)
+		if err == nil || !strings.Contains(err.Error(), "delivering messages from sequence 4") {
+			t.Fatalf("expected the reset to fail: %v", err)
+		}
+
+		nfo2, err = js.ConsumerInfo("ORDERS", "PROCESSOR")
+		checkErr(t, err, "the consumer was not restored: %v", err)
+		if nfo2.Config.OptStartSeq != 4 || nfo2.Config.DeliverPolicy != nats.DeliverByStartSequencePolicy || nfo2.Config.MaxDeliver != 10 || nfo2.NumPending != 2 {
+			t.Fatalf("invalid restored consumer: %+v", nfo2)
+		}
+	})
+}
+
+func TestConsumerInteractiveNext(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		timeout := opts.Timeout
+		opts.Timeout = 500 * time.Millisecond
+		defer func() { opts.Timeout = timeout }()
+
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		stream, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		consumer, err := stream.NewConsumer(jsm.DurableName("PROCESSOR"), jsm.AcknowledgeExplicit(), jsm.AckWait(time.Hour))
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 4; i++ {
+			_, err = js.Publish(fmt.Sprintf("orders.%d", i), []byte(fmt.Sprintf("order %d", i)))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		c := &consumerCmd{nc: nc, mgr: mgr, stream: "ORDERS", consumer: "PROCESSOR", selectedConsumer: consumer}
+		tally := &consumerInteractiveTally{}
+
+		for _, actions := range [][]string{{"a"}, {"p", "t"}, {"d"}, {"s"}} {
+			msg, err := c.requestNextMsg(c.stream, c.consumer)
+			checkErr(t, err, "request failed: %v", err)
+			if msg == nil {
+				t.Fatalf("expected a message")
+			}
+
+			for _, action := range actions {
+				err = c.interactiveRespond(msg, action, time.Hour, "invalid order", tally)
+				checkErr(t, err, "respond failed: %v", err)
+			}
+		}
+
+		msg, err := c.requestNextMsg(c.stream, c.consumer)
+		checkErr(t, err, "request failed: %v", err)
+		if msg != nil {
+			t.Fatalf("expected no message, got %s", msg.Subject)
+		}
+
+		if tally.acked != 1 || tally.progressed != 1 || tally.termed != 1 || tally.naked != 1 || tally.skipped != 1 {
+			t.Fatalf("invalid tally: %s", tally)
+		}
+
+		nfo, err := js.ConsumerInfo("ORDERS", "PROCESSOR")
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.AckFloor.Stream != 2 || nfo.NumAckPending != 2 || nfo.NumPending != 0 {
+			t.Fatalf("invalid consumer state: %+v", nfo)
+		}
+
+		if validateInteractiveAction("x") == nil || validateInteractiveAction(" T ") != nil {
+			t.Fatalf("invalid action validation")
+		}
+	})
+}
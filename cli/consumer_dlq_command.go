@@ -0,0 +1,337 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/jsm.go/api/jetstream/advisory"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	dlqStreamHeader     = "DLQ-Stream"
+	dlqConsumerHeader   = "DLQ-Consumer"
+	dlqSequenceHeader   = "DLQ-Sequence"
+	dlqSubjectHeader    = "DLQ-Subject"
+	dlqTimeHeader       = "DLQ-Time"
+	dlqDeliveriesHeader = "DLQ-Deliveries"
+	dlqReasonHeader     = "DLQ-Reason"
+
+	dlqMaxDeliveriesAdvisory = api.JSAdvisoryPrefix + ".CONSUMER.MAX_DELIVERIES"
+	dlqTerminatedAdvisory    = api.JSAdvisoryPrefix + ".CONSUMER.MSG_TERMINATED"
+)
+
+type consumerDLQCmd struct {
+	stream     string
+	consumer   string
+	dlqStream  string
+	subject    string
+	create     bool
+	remove     bool
+	terminated bool
+	count      int
+	subjects   []string
+	seqRange   string
+	force      bool
+
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+func configureConsumerDLQCommand(cons *fisk.CmdClause) {
+	c := &consumerDLQCmd{}
+
+	help := `Moves undeliverable messages into a dead letter Stream
+
+Listens for advisories published when messages on a Consumer reach the
+maximum delivery attempts or are terminated, retrieves the message from the
+Stream and publishes it into a dead letter Stream.
+
+Messages in the dead letter Stream carry headers recording where they came
+from, DLQ-Stream, DLQ-Consumer, DLQ-Sequence, DLQ-Subject, DLQ-Time,
+DLQ-Deliveries and DLQ-Reason. Using nats consumer dlq redrive the messages
+can later be published back to their original Stream.
+`
+
+	dlq := cons.Command("dlq", "Dead letter handling for Consumers")
+	dlq.HelpLong(help)
+
+	capture := dlq.Command("capture", "Moves messages that exceeded their deliveries into a dead letter Stream").Default().Action(c.captureAction)
+	capture.Arg("stream", "Stream name").Required().StringVar(&c.stream)
+	capture.Arg("consumer", "Consumer name").Required().StringVar(&c.consumer)
+	capture.Flag("to", "The dead letter Stream").PlaceHolder("STREAM").Required().StringVar(&c.dlqStream)
+	capture.Flag("subject", "Subject to publish dead letters to, defaults to dlq.STREAM.CONSUMER").StringVar(&c.subject)
+	capture.Flag("create", "Creates the dead letter Stream if it does not exist").UnNegatableBoolVar(&c.create)
+	capture.Flag("terminated", "Also moves messages terminated by clients").Default("true").BoolVar(&c.terminated)
+	capture.Flag("remove", "Removes messages from the original Stream once stored in the dead letter Stream").UnNegatableBoolVar(&c.remove)
+	capture.Flag("count", "Stop after moving this many messages").IntVar(&c.count)
+
+	redrive := dlq.Command("redrive", "Publishes messages in a dead letter Stream back to their original Stream").Action(c.redriveAction)
+	redrive.Arg("stream", "The dead letter Stream").Required().StringVar(&c.dlqStream)
+	redrive.Flag("subject", "Only redrive messages matching a subject (pass multiple times)").StringsVar(&c.subjects)
+	redrive.Flag("seq-range", "Only redrive messages in a sequence range like 10-20, 10- or -20").PlaceHolder("RANGE").StringVar(&c.seqRange)
+	redrive.Flag("count", "Maximum number of messages to redrive").IntVar(&c.count)
+	redrive.Flag("remove", "Removes messages from the dead letter Stream once published").Default("true").BoolVar(&c.remove)
+	redrive.Flag("force", "Force redrive without prompting").Short('f').UnNegatableBoolVar(&c.force)
+}
+
+func (c *consumerDLQCmd) captureAction(_ *fisk.ParseContext) error {
+	var err error
+
+	c.nc, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	if c.subject == "" {
+		c.subject = fmt.Sprintf("dlq.%s.%s", c.stream, c.consumer)
+	}
+
+	_, err = c.js.ConsumerInfo(c.stream, c.consumer)
+	if err != nil {
+		return fmt.Errorf("could not load Consumer %s > %s: %v", c.stream, c.consumer, err)
+	}
+
+	_, err = c.js.StreamInfo(c.dlqStream)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound) && c.create:
+		_, err = c.js.AddStream(&nats.StreamConfig{Name: c.dlqStream, Subjects: []string{c.subject}})
+		if err != nil {
+			return fmt.Errorf("could not create dead letter Stream %s: %v", c.dlqStream, err)
+		}
+		log.Printf("Created dead letter Stream %s with subject %s", c.dlqStream, c.subject)
+	case err != nil:
+		return fmt.Errorf("could not load dead letter Stream %s: %v", c.dlqStream, err)
+	}
+
+	msgs := make(chan *nats.Msg, 1000)
+	subjects := []string{c.advisorySubject(dlqMaxDeliveriesAdvisory)}
+	if c.terminated {
+		subjects = append(subjects, c.advisorySubject(dlqTerminatedAdvisory))
+	}
+
+	for _, subj := range subjects {
+		sub, err := c.nc.ChanSubscribe(subj, msgs)
+		if err != nil {
+			return err
+		}
+		defer sub.Unsubscribe()
+	}
+
+	log.Printf("Moving dead letters from Consumer %s > %s to Stream %s", c.stream, c.consumer, c.dlqStream)
+
+	moved := 0
+	for {
+		select {
+		case msg := <-msgs:
+			seq, err := c.handleAdvisory(msg)
+			if err != nil {
+				log.Printf("Could not move message %d: %v", seq, err)
+				continue
+			}
+
+			log.Printf("Moved message %d to %s", seq, c.dlqStream)
+
+			moved++
+			if c.count > 0 && moved == c.count {
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// advisorySubject is the subject the advisory is published on for the consumer, honoring the configured event prefix
+func (c *consumerDLQCmd) advisorySubject(advisory string) string {
+	prefix := ""
+	if opts.Config != nil {
+		prefix = opts.Config.JSEventPrefix()
+	}
+
+	return fmt.Sprintf("%s.%s.%s", jsm.EventSubject(advisory, prefix), c.stream, c.consumer)
+}
+
+func (c *consumerDLQCmd) handleAdvisory(msg *nats.Msg) (uint64, error) {
+	if msg.Subject == c.advisorySubject(dlqTerminatedAdvisory) {
+		var adv advisory.JSConsumerDeliveryTerminatedAdvisoryV1
+		err := json.Unmarshal(msg.Data, &adv)
+		if err != nil {
+			return 0, fmt.Errorf("invalid advisory: %v", err)
+		}
+
+		reason := "terminated"
+		if adv.Reason != "" {
+			reason = fmt.Sprintf("terminated: %s", adv.Reason)
+		}
+
+		return adv.StreamSeq, c.moveMessage(adv.StreamSeq, adv.Deliveries, reason)
+	}
+
+	var adv advisory.ConsumerDeliveryExceededAdvisoryV1
+	err := json.Unmarshal(msg.Data, &adv)
+	if err != nil {
+		return 0, fmt.Errorf("invalid advisory: %v", err)
+	}
+
+	return adv.StreamSeq, c.moveMessage(adv.StreamSeq, adv.Deliveries, "max deliveries")
+}
+
+// moveMessage publishes the message at seq into the dead letter stream with headers recording its origin
+func (c *consumerDLQCmd) moveMessage(seq uint64, deliveries uint64, reason string) error {
+	stored, err := c.js.GetMsg(c.stream, seq)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(c.subject)
+	msg.Data = stored.Data
+	for k, v := range stored.Header {
+		if strings.HasPrefix(k, "Nats-Expected-") || k == nats.MsgIdHdr {
+			continue
+		}
+		msg.Header[k] = v
+	}
+
+	msg.Header.Set(dlqStreamHeader, c.stream)
+	msg.Header.Set(dlqConsumerHeader, c.consumer)
+	msg.Header.Set(dlqSequenceHeader, strconv.FormatUint(seq, 10))
+	msg.Header.Set(dlqSubjectHeader, stored.Subject)
+	msg.Header.Set(dlqTimeHeader, stored.Time.UTC().Format(time.RFC3339Nano))
+	msg.Header.Set(dlqDeliveriesHeader, strconv.FormatUint(deliveries, 10))
+	msg.Header.Set(dlqReasonHeader, reason)
+	msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s:%s:%d", c.stream, c.consumer, seq))
+
+	_, err = c.js.PublishMsg(msg, nats.ExpectStream(c.dlqStream))
+	if err != nil {
+		return err
+	}
+
+	if c.remove {
+		return c.js.DeleteMsg(c.stream, seq)
+	}
+
+	return nil
+}
+
+func (c *consumerDLQCmd) redriveAction(_ *fisk.ParseContext) error {
+	var err error
+
+	c.nc, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	if !c.force {
+		ok, err := askConfirmation(fmt.Sprintf("Really publish messages in %s back to their original Streams", c.dlqStream), false)
+		if err != nil {
+			return fmt.Errorf("could not obtain confirmation: %v", err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	cnt, err := c.redrive()
+	if err != nil {
+		return fmt.Errorf("redrive failed after %s messages: %v", f(cnt), err)
+	}
+
+	fmt.Printf("Redrove %s messages from %s\n", f(cnt), c.dlqStream)
+
+	return nil
+}
+
+// redrive publishes dead letters back to their original stream and subject without the dead letter headers
+func (c *consumerDLQCmd) redrive() (int, error) {
+	start, end, err := parseSeqRange(c.seqRange)
+	if err != nil {
+		return 0, err
+	}
+
+	var sopts []nats.SubOpt
+	if start > 0 {
+		sopts = append(sopts, nats.StartSequence(start))
+	}
+
+	subjects := splitCLISubjects(c.subjects)
+	if len(subjects) > 0 {
+		sopts = append(sopts, nats.ConsumerFilterSubjects(subjects...))
+	}
+
+	walker, err := newStreamWalker(c.js, c.dlqStream, sopts...)
+	if err != nil {
+		return 0, err
+	}
+	defer walker.close()
+
+	cnt := 0
+	for {
+		if c.count > 0 && cnt == c.count {
+			return cnt, nil
+		}
+
+		msg, meta, err := walker.next()
+		if msg == nil || err != nil {
+			return cnt, err
+		}
+
+		if end > 0 && meta.Sequence.Stream > end {
+			return cnt, nil
+		}
+
+		stream := msg.Header.Get(dlqStreamHeader)
+		subject := msg.Header.Get(dlqSubjectHeader)
+		if stream == "" || subject == "" {
+			return cnt, fmt.Errorf("message %d does not have %s and %s headers", meta.Sequence.Stream, dlqStreamHeader, dlqSubjectHeader)
+		}
+
+		out := nats.NewMsg(subject)
+		out.Data = msg.Data
+		for k, v := range msg.Header {
+			if strings.HasPrefix(k, "DLQ-") || k == nats.MsgIdHdr {
+				continue
+			}
+			out.Header[k] = v
+		}
+
+		_, err = c.js.PublishMsg(out, nats.ExpectStream(stream))
+		if err != nil {
+			return cnt, fmt.Errorf("could not publish message %d to %s: %v", meta.Sequence.Stream, stream, err)
+		}
+
+		if c.remove {
+			err = c.js.DeleteMsg(c.dlqStream, meta.Sequence.Stream)
+			if err != nil {
+				return cnt, err
+			}
+		}
+
+		cnt++
+
+		if meta.Sequence.Stream == end {
+			return cnt, nil
+		}
+	}
+}
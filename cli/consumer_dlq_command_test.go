@@ -0,0 +1,118 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/natscontext"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestConsumerDLQ(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		stream, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		_, err = stream.NewConsumer(jsm.DurableName("PROCESSOR"), jsm.AcknowledgeExplicit())
+		checkErr(t, err, "create failed: %v", err)
+		dlq, err := mgr.NewStream("DLQ", jsm.Subjects("dlq.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 3; i++ {
+			msg := nats.NewMsg(fmt.Sprintf("orders.%d", i))
+			msg.Data = []byte(fmt.Sprintf("order %d", i))
+			msg.Header.Add("Order", fmt.Sprintf("%d", i))
+			_, err = js.PublishMsg(msg)
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		advisories, err := nc.SubscribeSync(dlqTerminatedAdvisory + ".ORDERS.PROCESSOR")
+		checkErr(t, err, "subscribe failed: %v", err)
+
+		sub, err := js.PullSubscribe("", "", nats.Bind("ORDERS", "PROCESSOR"))
+		checkErr(t, err, "subscribe failed: %v", err)
+		msgs, err := sub.Fetch(2)
+		checkErr(t, err, "fetch failed: %v", err)
+		checkErr(t, msgs[0].Ack(), "ack failed")
+		checkErr(t, msgs[1].Respond([]byte("+TERM invalid order")), "term failed")
+
+		adv, err := advisories.NextMsg(2 * time.Second)
+		checkErr(t, err, "advisory not received: %v", err)
+
+		c := &consumerDLQCmd{nc: nc, js: js, stream: "ORDERS", consumer: "PROCESSOR", dlqStream: "DLQ", subject: "dlq.ORDERS.PROCESSOR", remove: true}
+		seq, err := c.handleAdvisory(adv)
+		checkErr(t, err, "move failed: %v", err)
+		if seq != 2 {
+			t.Fatalf("expected sequence 2 got %d", seq)
+		}
+
+		dead, err := js.GetMsg("DLQ", 1)
+		checkErr(t, err, "get failed: %v", err)
+		if dead.Subject != "dlq.ORDERS.PROCESSOR" || string(dead.Data) != "order 2" || dead.Header.Get("Order") != "2" {
+			t.Fatalf("invalid dead letter: %+v", dead)
+		}
+		if dead.Header.Get(dlqStreamHeader) != "ORDERS" || dead.Header.Get(dlqConsumerHeader) != "PROCESSOR" || dead.Header.Get(dlqSequenceHeader) != "2" || dead.Header.Get(dlqSubjectHeader) != "orders.2" || dead.Header.Get(dlqReasonHeader) != "terminated: invalid order" {
+			t.Fatalf("invalid dead letter headers: %v", dead.Header)
+		}
+
+		_, err = js.GetMsg("ORDERS", 2)
+		if err == nil {
+			t.Fatalf("expected the original message to be removed")
+		}
+
+		cnt, err := c.redrive()
+		checkErr(t, err, "redrive failed: %v", err)
+		if cnt != 1 {
+			t.Fatalf("expected 1 message got %d", cnt)
+		}
+
+		redriven, err := js.GetMsg("ORDERS", 4)
+		checkErr(t, err, "get failed: %v", err)
+		if redriven.Subject != "orders.2" || string(redriven.Data) != "order 2" || redriven.Header.Get("Order") != "2" || redriven.Header.Get(dlqStreamHeader) != "" {
+			t.Fatalf("invalid redriven message: %+v", redriven)
+		}
+
+		nfo, err := dlq.LatestInformation()
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.State.Msgs != 0 {
+			t.Fatalf("expected the dead letter to be removed")
+		}
+	})
+}
+
+func TestConsumerDLQAdvisorySubject(t *testing.T) {
+	config := opts.Config
+	defer func() { opts.Config = config }()
+
+	c := &consumerDLQCmd{stream: "ORDERS", consumer: "PROCESSOR"}
+
+	opts.Config = nil
+	if subj := c.advisorySubject(dlqMaxDeliveriesAdvisory); subj != "$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES.ORDERS.PROCESSOR" {
+		t.Fatalf("invalid subject %q", subj)
+	}
+
+	var err error
+	opts.Config, err = natscontext.New("dlq", false, natscontext.WithJSEventPrefix("hub.events"))
+	checkErr(t, err, "context failed: %v", err)
+	if subj := c.advisorySubject(dlqTerminatedAdvisory); subj != "hub.events.ADVISORY.CONSUMER.MSG_TERMINATED.ORDERS.PROCESSOR" {
+		t.Fatalf("invalid subject %q", subj)
+	}
+}
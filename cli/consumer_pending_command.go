@@ -0,0 +1,323 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/dustin/go-humanize"
+	"github.com/nats-io/nats.go"
+)
+
+type consumerPendingCmd struct {
+	stream   string
+	consumer string
+	limit    int
+	nak      []string
+	term     []string
+	delay    time.Duration
+	reason   string
+	force    bool
+	json     bool
+
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+type consumerPendingMsg struct {
+	Sequence uint64        `json:"seq"`
+	Subject  string        `json:"subject"`
+	Size     int           `json:"size"`
+	Time     time.Time     `json:"time"`
+	Age      time.Duration `json:"age"`
+	Pending  bool          `json:"pending"`
+}
+
+type consumerPendingReport struct {
+	Stream         string                `json:"stream"`
+	Consumer       string                `json:"consumer"`
+	AckFloor       uint64                `json:"ack_floor"`
+	Delivered      uint64                `json:"delivered"`
+	NumAckPending  int                   `json:"num_ack_pending"`
+	NumRedelivered int                   `json:"num_redelivered"`
+	Exact          bool                  `json:"exact"`
+	Truncated      bool                  `json:"truncated"`
+	Messages       []*consumerPendingMsg `json:"messages"`
+
+	deliveredConsumer uint64
+	ackPolicy         nats.AckPolicy
+}
+
+func configureConsumerPendingCommand(cons *fisk.CmdClause) {
+	c := &consumerPendingCmd{}
+
+	help := `Shows messages awaiting acknowledgement on a Consumer
+
+Messages between the acknowledgement floor and the last delivered message
+are retrieved from the Stream and shown with their subjects, sizes and ages.
+
+The server does not expose which individual messages above the floor were
+acknowledged, the first message is always outstanding and when the number
+of messages in the range equals the Ack Pending count all of them are. In
+other cases messages might already have been acknowledged. The server also
+does not expose per message delivery counts, the total number of
+redelivered messages is shown instead.
+
+Messages can be negatively acknowledged, causing redelivery, or terminated
+using --nak and --term on Consumers with explicit acknowledgement. These are
+ignored by the server for messages that are not outstanding. The number of
+times a message was delivered is not known so terminate advisories will
+report a single delivery for messages terminated this way.
+`
+
+	pending := cons.Command("pending", "Shows messages awaiting acknowledgement").Action(c.pendingAction)
+	pending.HelpLong(help)
+	pending.Arg("stream", "Stream name").Required().StringVar(&c.stream)
+	pending.Arg("consumer", "Consumer name").Required().StringVar(&c.consumer)
+	pending.Flag("limit", "Maximum number of messages to show").Default("100").IntVar(&c.limit)
+	pending.Flag("nak", "Negatively acknowledge messages by sequence or range like 10-20 (pass multiple times)").PlaceHolder("SEQ").StringsVar(&c.nak)
+	pending.Flag("delay", "Delay redelivery of messages negatively acknowledged using --nak").DurationVar(&c.delay)
+	pending.Flag("term", "Terminate messages by sequence or range like 10-20 (pass multiple times)").PlaceHolder("SEQ").StringsVar(&c.term)
+	pending.Flag("reason", "Reason recorded when terminating messages").StringVar(&c.reason)
+	pending.Flag("force", "Act on messages without prompting").Short('f').UnNegatableBoolVar(&c.force)
+	pending.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+}
+
+func (c *consumerPendingCmd) pendingAction(_ *fisk.ParseContext) error {
+	var err error
+
+	c.nc, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	report, err := c.pendingReport()
+	if err != nil {
+		return err
+	}
+
+	if len(c.nak) > 0 || len(c.term) > 0 {
+		return c.actOnPending(report)
+	}
+
+	if c.json {
+		return printJSON(report)
+	}
+
+	c.renderReport(report)
+
+	return nil
+}
+
+func (c *consumerPendingCmd) renderReport(report *consumerPendingReport) {
+	cols := newColumns(fmt.Sprintf("Messages awaiting acknowledgement on %s > %s", report.Stream, report.Consumer))
+	cols.AddRow("Ack Floor", report.AckFloor)
+	cols.AddRow("Last Delivered", report.Delivered)
+	cols.AddRow("Ack Pending", report.NumAckPending)
+	cols.AddRow("Redelivered", report.NumRedelivered)
+	cols.Frender(os.Stdout)
+	fmt.Println()
+
+	if len(report.Messages) == 0 {
+		fmt.Println("No messages are awaiting acknowledgement")
+		return
+	}
+
+	title := "Outstanding Messages"
+	if report.Truncated {
+		title = fmt.Sprintf("First %d Outstanding Messages", len(report.Messages))
+	}
+
+	table := newTableWriter(title)
+	table.AddHeaders("Sequence", "Subject", "Size", "Age", "Status")
+	for _, msg := range report.Messages {
+		status := "pending"
+		if !msg.Pending {
+			status = "pending or acknowledged"
+		}
+		table.AddRow(f(msg.Sequence), msg.Subject, humanize.IBytes(uint64(msg.Size)), f(msg.Age.Round(time.Second)), status)
+	}
+	fmt.Println(table.Render())
+}
+
+// pendingReport finds the messages between the ack floor and the last delivered message matching the consumer filter
+func (c *consumerPendingCmd) pendingReport() (*consumerPendingReport, error) {
+	nfo, err := c.js.ConsumerInfo(c.stream, c.consumer)
+	if err != nil {
+		return nil, err
+	}
+
+	if nfo.Config.AckPolicy == nats.AckNonePolicy {
+		return nil, fmt.Errorf("consumer %s > %s does not acknowledge messages", c.stream, c.consumer)
+	}
+
+	report := &consumerPendingReport{
+		Stream:         c.stream,
+		Consumer:       c.consumer,
+		AckFloor:       nfo.AckFloor.Stream,
+		Delivered:      nfo.Delivered.Stream,
+		NumAckPending:  nfo.NumAckPending,
+		NumRedelivered: nfo.NumRedelivered,
+		Messages:       []*consumerPendingMsg{},
+
+		deliveredConsumer: nfo.Delivered.Consumer,
+		ackPolicy:         nfo.Config.AckPolicy,
+	}
+
+	if nfo.NumAckPending == 0 || report.Delivered <= report.AckFloor {
+		report.Exact = true
+		return report, nil
+	}
+
+	sopts := []nats.SubOpt{nats.StartSequence(report.AckFloor + 1)}
+	subjects := nfo.Config.FilterSubjects
+	if nfo.Config.FilterSubject != "" {
+		subjects = append(subjects, nfo.Config.FilterSubject)
+	}
+	if len(subjects) > 0 {
+		sopts = append(sopts, nats.ConsumerFilterSubjects(subjects...))
+	}
+
+	walker, err := newStreamWalker(c.js, c.stream, sopts...)
+	if err != nil {
+		return nil, err
+	}
+	defer walker.close()
+
+	found := 0
+	for {
+		msg, meta, err := walker.next()
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil || meta.Sequence.Stream > report.Delivered {
+			break
+		}
+
+		found++
+		if len(report.Messages) == c.limit {
+			report.Truncated = true
+			continue
+		}
+
+		report.Messages = append(report.Messages, &consumerPendingMsg{
+			Sequence: meta.Sequence.Stream,
+			Subject:  msg.Subject,
+			Size:     len(msg.Data),
+			Time:     meta.Timestamp,
+			Age:      time.Since(meta.Timestamp),
+		})
+	}
+
+	report.Exact = found == nfo.NumAckPending
+	for i, msg := range report.Messages {
+		msg.Pending = report.Exact || i == 0
+	}
+
+	return report, nil
+}
+
+// actOnPending naks or terms the selected messages by publishing to their acknowledgement subject
+func (c *consumerPendingCmd) actOnPending(report *consumerPendingReport) error {
+	var nak, term []uint64
+	var err error
+
+	// with any other policy acknowledging one message acknowledges all earlier ones
+	if report.ackPolicy != nats.AckExplicitPolicy {
+		return fmt.Errorf("messages can only be negatively acknowledged or terminated on Consumers with explicit acknowledgement")
+	}
+
+	nak, err = c.selectPending(report, c.nak)
+	if err != nil {
+		return err
+	}
+	term, err = c.selectPending(report, c.term)
+	if err != nil {
+		return err
+	}
+
+	if len(nak) == 0 && len(term) == 0 {
+		return fmt.Errorf("no outstanding messages matched the selected sequences")
+	}
+
+	if !c.force {
+		ok, err := askConfirmation(fmt.Sprintf("Really negatively acknowledge %d and terminate %d messages on %s > %s", len(nak), len(term), c.stream, c.consumer), false)
+		if err != nil {
+			return fmt.Errorf("could not obtain confirmation: %v", err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	nakBody := "-NAK"
+	if c.delay > 0 {
+		nakBody = fmt.Sprintf("-NAK {\"delay\": %d}", c.delay)
+	}
+	termBody := "+TERM"
+	if c.reason != "" {
+		termBody = fmt.Sprintf("+TERM %s", c.reason)
+	}
+
+	for _, seq := range nak {
+		err = c.nc.Publish(c.ackSubject(report, seq), []byte(nakBody))
+		if err != nil {
+			return err
+		}
+	}
+	for _, seq := range term {
+		err = c.nc.Publish(c.ackSubject(report, seq), []byte(termBody))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.nc.Flush()
+	if err != nil {
+		return err
+	}
+
+	if !c.json {
+		fmt.Printf("Negatively acknowledged %d and terminated %d messages\n", len(nak), len(term))
+	}
+
+	return nil
+}
+
+// ackSubject builds the subject the server expects acknowledgements on, the server finds pending messages by stream sequence
+func (c *consumerPendingCmd) ackSubject(report *consumerPendingReport, seq uint64) string {
+	return fmt.Sprintf("$JS.ACK.%s.%s.1.%d.%d.%d.0", c.stream, c.consumer, seq, report.deliveredConsumer, time.Now().UnixNano())
+}
+
+func (c *consumerPendingCmd) selectPending(report *consumerPendingReport, ranges []string) ([]uint64, error) {
+	var selected []uint64
+
+	for _, r := range ranges {
+		start, end, err := parseSeqRange(r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range report.Messages {
+			if msg.Sequence >= start && (end == 0 || msg.Sequence <= end) {
+				selected = append(selected, msg.Sequence)
+			}
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestConsumerPending(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		stream, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		_, err = stream.NewConsumer(jsm.DurableName("PROCESSOR"), jsm.AcknowledgeExplicit(), jsm.AckWait(time.Hour))
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 6; i++ {
+			_, err = js.Publish(fmt.Sprintf("orders.%d", i), []byte(fmt.Sprintf("order %d", i)))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		sub, err := js.PullSubscribe("", "", nats.Bind("ORDERS", "PROCESSOR"))
+		checkErr(t, err, "subscribe failed: %v", err)
+		msgs, err := sub.Fetch(5)
+		checkErr(t, err, "fetch failed: %v", err)
+		checkErr(t, msgs[0].AckSync(), "ack failed")
+		checkErr(t, msgs[2].AckSync(), "ack failed")
+
+		c := &consumerPendingCmd{nc: nc, js: js, stream: "ORDERS", consumer: "PROCESSOR", limit: 100, force: true}
+		report, err := c.pendingReport()
+		checkErr(t, err, "report failed: %v", err)
+
+		if report.AckFloor != 1 || report.Delivered != 5 || report.NumAckPending != 3 || report.Exact || len(report.Messages) != 4 {
+			t.Fatalf("invalid report: %+v", report)
+		}
+		if report.Messages[0].Sequence != 2 || report.Messages[0].Subject != "orders.2" || !report.Messages[0].Pending || report.Messages[1].Pending {
+			t.Fatalf("invalid messages: %+v %+v", report.Messages[0], report.Messages[1])
+		}
+
+		c.nak = []string{"2"}
+		c.term = []string{"4-5"}
+		checkErr(t, c.actOnPending(report), "act failed")
+
+		msgs, err = sub.Fetch(1, nats.MaxWait(2*time.Second))
+		checkErr(t, err, "fetch failed: %v", err)
+		meta, err := msgs[0].Metadata()
+		checkErr(t, err, "invalid metadata: %v", err)
+		if meta.Sequence.Stream != 2 || meta.NumDelivered != 2 {
+			t.Fatalf("expected redelivery of message 2 got %+v", meta)
+		}
+
+		nfo, err := js.ConsumerInfo("ORDERS", "PROCESSOR")
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.NumAckPending != 1 {
+			t.Fatalf("expected 1 pending message got %d", nfo.NumAckPending)
+		}
+
+		_, err = stream.NewConsumer(jsm.DurableName("ALL"), jsm.AcknowledgeAll(), jsm.AckWait(time.Hour))
+		checkErr(t, err, "create failed: %v", err)
+		sub, err = js.PullSubscribe("", "", nats.Bind("ORDERS", "ALL"))
+		checkErr(t, err, "subscribe failed: %v", err)
+		_, err = sub.Fetch(5)
+		checkErr(t, err, "fetch failed: %v", err)
+
+		c = &consumerPendingCmd{nc: nc, js: js, stream: "ORDERS", consumer: "ALL", limit: 100, force: true, term: []string{"4"}}
+		report, err = c.pendingReport()
+		checkErr(t, err, "report failed: %v", err)
+		err = c.actOnPending(report)
+		if err == nil || !strings.Contains(err.Error(), "explicit acknowledgement") {
+			t.Fatalf("expected act on ack all consumer to fail got %v", err)
+		}
+
+		nfo, err = js.ConsumerInfo("ORDERS", "ALL")
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.AckFloor.Stream != 0 || nfo.NumAckPending != 5 {
+			t.Fatalf("expected no acknowledgements got %+v", nfo)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/guptarohit/asciigraph"
+	"github.com/nats-io/nats.go"
+)
+
+type consumerWatchCmd struct {
+	stream    string
+	consumers []string
+	interval  time.Duration
+	history   int
+	graph     bool
+
+	js      nats.JetStreamContext
+	samples map[string][]*consumerWatchSample
+}
+
+type consumerWatchSample struct {
+	time        time.Time
+	delivered   uint64
+	acked       uint64
+	pending     uint64
+	ackPending  uint64
+	redelivered uint64
+}
+
+type consumerWatchStats struct {
+	deliveredRate float64
+	ackedRate     float64
+	trend         float64
+	outstanding   uint64
+	eta           time.Duration
+	draining      bool
+}
+
+func configureConsumerWatchCommand(cons *fisk.CmdClause) {
+	c := &consumerWatchCmd{}
+
+	help := `Watches the throughput and lag of Consumers
+
+Consumer information is retrieved on an interval and rates are calculated
+from the difference between samples. The Pending Trend shows how fast the
+outstanding messages, those not yet delivered plus those awaiting
+acknowledgement, grow or shrink. The ETA is an estimate of the time needed
+to process all outstanding messages based on that trend.
+
+When no Consumers are given all Consumers on the Stream are watched.
+`
+
+	watch := cons.Command("watch", "Watches Consumer throughput and lag over time").Action(c.watchAction)
+	watch.HelpLong(help)
+	watch.Arg("stream", "Stream name").Required().StringVar(&c.stream)
+	watch.Arg("consumers", "Consumers to watch").StringsVar(&c.consumers)
+	watch.Flag("interval", "How often to retrieve Consumer information").Default("2s").DurationVar(&c.interval)
+	watch.Flag("history", "Number of samples used to calculate trends and draw graphs").Default("60").IntVar(&c.history)
+	watch.Flag("graph", "Draw graphs of outstanding messages").Default("true").BoolVar(&c.graph)
+}
+
+func (c *consumerWatchCmd) watchAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	if c.history < 2 {
+		return fmt.Errorf("history must be at least 2 samples")
+	}
+
+	if len(c.consumers) == 0 {
+		for name := range c.js.ConsumerNames(c.stream) {
+			c.consumers = append(c.consumers, name)
+		}
+		if len(c.consumers) == 0 {
+			return fmt.Errorf("stream %s has no consumers", c.stream)
+		}
+		sort.Strings(c.consumers)
+	}
+
+	c.samples = map[string][]*consumerWatchSample{}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		err = c.sample()
+		if err != nil {
+			return err
+		}
+
+		clearScreen()
+		c.render()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *consumerWatchCmd) sample() error {
+	for _, name := range c.consumers {
+		nfo, err := c.js.ConsumerInfo(c.stream, name)
+		if err != nil {
+			return fmt.Errorf("could not load Consumer %s > %s: %v", c.stream, name, err)
+		}
+
+		// the ack floor only moves once every earlier message is acked, so acks are derived
+		// from what was delivered and is no longer awaiting an ack
+		var acked uint64
+		if nfo.Delivered.Consumer > uint64(nfo.NumAckPending) {
+			acked = nfo.Delivered.Consumer - uint64(nfo.NumAckPending)
+		}
+
+		samples := append(c.samples[name], &consumerWatchSample{
+			time:        time.Now(),
+			delivered:   nfo.Delivered.Consumer,
+			acked:       acked,
+			pending:     nfo.NumPending,
+			ackPending:  uint64(nfo.NumAckPending),
+			redelivered: uint64(nfo.NumRedelivered),
+		})
+		if len(samples) > c.history {
+			samples = samples[len(samples)-c.history:]
+		}
+
+		c.samples[name] = samples
+	}
+
+	return nil
+}
+
+func (c *consumerWatchCmd) render() {
+	table := newTableWriter(fmt.Sprintf("Consumers on Stream %s @ %s", c.stream, time.Now().Format(time.TimeOnly)))
+	table.AddHeaders("Consumer", "Unprocessed", "Ack Pending", "Redelivered", "Delivered/s", "Acked/s", "Pending Trend", "ETA")
+
+	for _, name := range c.consumers {
+		samples := c.samples[name]
+		if len(samples) == 0 {
+			continue
+		}
+
+		last := samples[len(samples)-1]
+		stats := calculateConsumerWatchStats(samples)
+
+		eta := "never"
+		switch {
+		case stats.outstanding == 0:
+			eta = "drained"
+		case len(samples) < 2:
+			eta = "unknown"
+		case stats.draining:
+			eta = f(stats.eta)
+		}
+
+		table.AddRow(name, f(last.pending), f(last.ackPending), f(last.redelivered), fmt.Sprintf("%.1f", stats.deliveredRate), fmt.Sprintf("%.1f", stats.ackedRate), fmt.Sprintf("%+.1f/s", stats.trend), eta)
+	}
+
+	fmt.Println(table.Render())
+
+	if !c.graph {
+		return
+	}
+
+	for _, name := range c.consumers {
+		samples := c.samples[name]
+		if len(samples) < 2 {
+			continue
+		}
+
+		data := make([]float64, len(samples))
+		for i, s := range samples {
+			data[i] = float64(s.pending + s.ackPending)
+		}
+
+		fmt.Println(asciigraph.Plot(
+			data,
+			asciigraph.Height(6),
+			asciigraph.Width(60),
+			asciigraph.Offset(5),
+			asciigraph.Caption(fmt.Sprintf("%s outstanding messages", name)),
+		))
+		fmt.Println()
+	}
+}
+
+// calculateConsumerWatchStats calculates rates over the most recent interval and the outstanding trend over all samples
+func calculateConsumerWatchStats(samples []*consumerWatchSample) *consumerWatchStats {
+	stats := &consumerWatchStats{}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	last := samples[len(samples)-1]
+	stats.outstanding = last.pending + last.ackPending
+
+	if len(samples) < 2 {
+		return stats
+	}
+
+	prev := samples[len(samples)-2]
+	if elapsed := last.time.Sub(prev.time).Seconds(); elapsed > 0 {
+		if last.delivered >= prev.delivered {
+			stats.deliveredRate = float64(last.delivered-prev.delivered) / elapsed
+		}
+		if last.acked >= prev.acked {
+			stats.ackedRate = float64(last.acked-prev.acked) / elapsed
+		}
+	}
+
+	first := samples[0]
+	if elapsed := last.time.Sub(first.time).Seconds(); elapsed > 0 {
+		stats.trend = (float64(stats.outstanding) - float64(first.pending+first.ackPending)) / elapsed
+	}
+
+	if stats.trend < 0 && stats.outstanding > 0 {
+		stats.draining = true
+		stats.eta = time.Duration(float64(stats.outstanding) / -stats.trend * float64(time.Second))
+	}
+
+	return stats
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalculateConsumerWatchStats(t *testing.T) {
+	now := time.Now()
+	samples := []*consumerWatchSample{
+		{time: now, delivered: 0, acked: 0, pending: 100, ackPending: 0},
+		{time: now.Add(5 * time.Second), delivered: 30, acked: 20, pending: 70, ackPending: 10},
+		{time: now.Add(10 * time.Second), delivered: 60, acked: 50, pending: 40, ackPending: 10},
+	}
+
+	stats := calculateConsumerWatchStats(samples)
+	if stats.deliveredRate != 6 || stats.ackedRate != 6 {
+		t.Fatalf("invalid rates: %+v", stats)
+	}
+	if stats.outstanding != 50 || stats.trend != -5 || !stats.draining || stats.eta != 10*time.Second {
+		t.Fatalf("invalid trend: %+v", stats)
+	}
+
+	samples[2].pending = 120
+	stats = calculateConsumerWatchStats(samples)
+	if stats.draining || stats.trend != 3 {
+		t.Fatalf("invalid growing trend: %+v", stats)
+	}
+
+	stats = calculateConsumerWatchStats(samples[:1])
+	if stats.outstanding != 100 || stats.draining || stats.deliveredRate != 0 {
+		t.Fatalf("invalid single sample stats: %+v", stats)
+	}
+}
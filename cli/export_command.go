@@ -0,0 +1,224 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/choria-io/fisk"
+	"github.com/ghodss/yaml"
+	"github.com/nats-io/jsm.go"
+)
+
+type exportCmd struct {
+	directory string
+	kinds     []string
+	json      bool
+	force     bool
+
+	mgr *jsm.Manager
+}
+
+func configureExportCommand(app commandHost) {
+	c := &exportCmd{}
+
+	help := `Exports JetStream assets as declarative manifests
+
+One manifest is written per stream, durable consumer, Key-Value bucket and
+Object Store bucket. Only configuration is exported, state such as creation
+times, message counts and cluster details are not included.
+
+The manifests can be applied to the same or a different account using
+nats apply.
+`
+
+	export := app.Command("export", "Export JetStream configuration as manifests").Action(c.exportAction)
+	export.HelpLong(help)
+	export.Arg("directory", "Directory to write manifests to").Required().StringVar(&c.directory)
+	export.Flag("kind", "Limit the export to certain kinds of assets (stream, consumer, kv, object)").PlaceHolder("KIND").EnumsVar(&c.kinds, manifestKindStream, manifestKindConsumer, manifestKindKV, manifestKindObject)
+	export.Flag("json", "Write manifests in JSON format").Short('j').UnNegatableBoolVar(&c.json)
+	export.Flag("force", "Overwrite existing manifests").Short('f').UnNegatableBoolVar(&c.force)
+
+	addCheat("export", export)
+}
+
+func init() {
+	registerCommand("export", 7, configureExportCommand)
+}
+
+func (c *exportCmd) exportAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.mgr, err = prepareHelper("", natsOpts()...)
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	manifests, err := c.manifests()
+	if err != nil {
+		return err
+	}
+
+	if len(manifests) == 0 {
+		fmt.Println("No JetStream assets found")
+		return nil
+	}
+
+	err = os.MkdirAll(c.directory, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range manifests {
+		file, err := c.writeManifest(m)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Exported %s to %s\n", m.source, file)
+	}
+
+	return nil
+}
+
+func (c *exportCmd) shouldExport(kind string) bool {
+	if len(c.kinds) == 0 {
+		return true
+	}
+
+	for _, k := range c.kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// manifests produces manifests for every asset in the account, source holds a description of the asset
+func (c *exportCmd) manifests() ([]*jsManifest, error) {
+	var manifests []*jsManifest
+
+	streams, missing, err := c.mgr.Streams(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("could not obtain stream information for %d streams", len(missing))
+	}
+
+	add := func(kind string, stream string, name string, cfg any) error {
+		j, err := json.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+
+		source := fmt.Sprintf("%s %s", kind, name)
+		if stream != "" {
+			source = fmt.Sprintf("%s %s > %s", kind, stream, name)
+		}
+
+		manifests = append(manifests, &jsManifest{Kind: kind, Stream: stream, Config: j, source: source})
+
+		return nil
+	}
+
+	for _, stream := range streams {
+		switch {
+		case stream.IsKVBucket():
+			if c.shouldExport(manifestKindKV) {
+				bucket := strings.TrimPrefix(stream.Name(), "KV_")
+				err = add(manifestKindKV, "", bucket, kvManifestFromStream(bucket, stream.Configuration()))
+			}
+
+		case stream.IsObjectBucket():
+			if c.shouldExport(manifestKindObject) {
+				bucket := strings.TrimPrefix(stream.Name(), "OBJ_")
+				err = add(manifestKindObject, "", bucket, objectManifestFromStream(bucket, stream.Configuration()))
+			}
+
+		case stream.IsInternal():
+			continue
+
+		case c.shouldExport(manifestKindStream):
+			err = add(manifestKindStream, "", stream.Name(), stream.Configuration())
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if stream.IsInternal() || !c.shouldExport(manifestKindConsumer) {
+			continue
+		}
+
+		consumers, missing, err := c.mgr.Consumers(stream.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("could not obtain consumer information for %d consumers on stream %s", len(missing), stream.Name())
+		}
+
+		for _, consumer := range consumers {
+			if !consumer.IsDurable() {
+				continue
+			}
+
+			err = add(manifestKindConsumer, stream.Name(), consumer.Name(), consumer.Configuration())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return manifests, nil
+}
+
+func (c *exportCmd) writeManifest(m *jsManifest) (string, error) {
+	name, err := m.name()
+	if err != nil {
+		return "", err
+	}
+
+	if m.Stream != "" {
+		name = m.Stream + "_" + name
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	ext := ".json"
+	if !c.json {
+		ext = ".yaml"
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	file := filepath.Join(c.directory, fmt.Sprintf("%s_%s%s", m.Kind, name, ext))
+	if !c.force && fileExists(file) {
+		return "", fmt.Errorf("%s already exist, use --force to overwrite", file)
+	}
+
+	return file, os.WriteFile(file, data, 0600)
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestExportManifests(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		_, err = mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		_, err = mgr.NewConsumer("ORDERS", jsm.DurableName("PROCESSOR"))
+		checkErr(t, err, "create failed: %v", err)
+		_, err = mgr.NewConsumer("ORDERS")
+		checkErr(t, err, "create failed: %v", err)
+		_, err = js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "CONFIG", History: 5, Storage: nats.MemoryStorage})
+		checkErr(t, err, "create failed: %v", err)
+		_, err = js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "FILES", Storage: nats.MemoryStorage})
+		checkErr(t, err, "create failed: %v", err)
+
+		dir := t.TempDir()
+		c := &exportCmd{mgr: mgr, directory: dir}
+
+		manifests, err := c.manifests()
+		checkErr(t, err, "export failed: %v", err)
+		for _, m := range manifests {
+			_, err = c.writeManifest(m)
+			checkErr(t, err, "write failed: %v", err)
+		}
+
+		files, err := os.ReadDir(dir)
+		checkErr(t, err, "read failed: %v", err)
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		assertListEquals(t, names, "stream_ORDERS.yaml", "consumer_ORDERS_PROCESSOR.yaml", "kv_CONFIG.yaml", "object_FILES.yaml")
+
+		_, err = c.writeManifest(manifests[0])
+		if err == nil {
+			t.Fatalf("expected existing manifests to not be overwritten")
+		}
+
+		_, err = loadManifests([]string{dir, filepath.Join(dir, "missing")})
+		if err == nil {
+			t.Fatalf("expected missing files to fail")
+		}
+
+		loaded, err := loadManifests([]string{dir})
+		checkErr(t, err, "load failed: %v", err)
+
+		a := &applyCmd{nc: nc, mgr: mgr, js: js, prune: true}
+		plan, err := a.plan(loaded)
+		checkErr(t, err, "plan failed: %v", err)
+		if len(plan) != 4 {
+			t.Fatalf("expected 4 plan items got %d", len(plan))
+		}
+		for _, item := range plan {
+			if item.Action != "unchanged" {
+				t.Fatalf("expected exported manifests to be unchanged, got %s for %s %s: %s", item.Action, item.Kind, item.Name, item.Diff)
+			}
+		}
+	})
+}
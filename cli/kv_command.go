@@ -14,25 +14,33 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"os/exec"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/choria-io/fisk"
 	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
+	"github.com/kballard/go-shellquote"
 	"github.com/nats-io/jsm.go"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/natscli/columns"
 	"golang.org/x/term"
 )
 
+// kvWatchCommandValueLimit is the largest value passed to watch commands in NATS_KV_VALUE
+const kvWatchCommandValueLimit = 4096
+
 type kvCommand struct {
 	bucket                string
 	key                   string
@@ -61,6 +69,8 @@ type kvCommand struct {
 	mirrorDomain          string
 	sources               []string
 	compression           bool
+	watchJSON             bool
+	watchCommand          string
 }
 
 func configureKVCommand(app commandHost) {
@@ -144,6 +154,8 @@ for an indefinite period or a per-bucket configured TTL.
 	watch := kv.Command("watch", "Watch the bucket or a specific key for updated").Action(c.watchAction)
 	watch.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
 	watch.Arg("key", "The key to act on").Default(">").StringVar(&c.key)
+	watch.Flag("json", "Produce JSON output, one line per update").Short('j').UnNegatableBoolVar(&c.watchJSON)
+	watch.Flag("exec", "Runs a command for every update with details in NATS_KV_* environment variables and the value on STDIN, NATS_KV_VALUE is only set for text values up to 4KiB").PlaceHolder("COMMAND").StringVar(&c.watchCommand)
 
 	ls := kv.Command("ls", "List available buckets or the keys in a bucket").Alias("list").Action(c.lsAction)
 	ls.Arg("bucket", "The bucket to list the keys").StringVar(&c.bucket)
@@ -154,6 +166,10 @@ for an indefinite period or a per-bucket configured TTL.
 	rmHistory := kv.Command("compact", "Reclaim space used by deleted keys").Action(c.compactAction)
 	rmHistory.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
 	rmHistory.Flag("force", "Act without confirmation").Short('f').UnNegatableBoolVar(&c.force)
+
+	configureKVExportCommand(kv)
+	configureKVDiffCommand(kv)
+	configureKVEditCommand(kv)
 }
 
 func init() {
@@ -179,6 +195,10 @@ func (c *kvCommand) parseLimitStrings(_ *fisk.ParseContext) (err error) {
 }
 
 func (c *kvCommand) strForOp(op nats.KeyValueOp) string {
+	return kvOperationName(op)
+}
+
+func kvOperationName(op nats.KeyValueOp) string {
 	switch op {
 	case nats.KeyValuePut:
 		return "PUT"
@@ -673,22 +693,91 @@ func (c *kvCommand) watchAction(_ *fisk.ParseContext) error {
 	}
 	defer watch.Stop()
 
+	enc := json.NewEncoder(os.Stdout)
+
 	for res := range watch.Updates() {
 		if res == nil {
 			continue
 		}
 
-		switch res.Operation() {
-		case nats.KeyValueDelete, nats.KeyValuePurge:
+		switch {
+		case c.watchJSON:
+			err = enc.Encode(&kvExportEntry{
+				Key:       res.Key(),
+				Revision:  res.Revision(),
+				Operation: kvOperationName(res.Operation()),
+				Created:   res.Created().UTC(),
+				Value:     res.Value(),
+			})
+			if err != nil {
+				return err
+			}
+
+		case res.Operation() == nats.KeyValueDelete || res.Operation() == nats.KeyValuePurge:
 			fmt.Printf("[%s] %s %s > %s\n", f(res.Created()), color.RedString(c.strForOp(res.Operation())), res.Bucket(), res.Key())
-		case nats.KeyValuePut:
+
+		case res.Operation() == nats.KeyValuePut:
 			fmt.Printf("[%s] %s %s > %s: %s\n", f(res.Created()), color.GreenString(c.strForOp(res.Operation())), res.Bucket(), res.Key(), res.Value())
 		}
+
+		if c.watchCommand != "" {
+			err = c.runWatchCommand(res)
+			if err != nil {
+				log.Printf("Command %q failed for %s > %s revision %d: %s", c.watchCommand, res.Bucket(), res.Key(), res.Revision(), err)
+			}
+		}
 	}
 
 	return nil
 }
 
+func (c *kvCommand) runWatchCommand(entry nats.KeyValueEntry) error {
+	parts, err := shellquote.Split(c.watchCommand)
+	if err != nil {
+		return fmt.Errorf("could not parse command: %s", err)
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	if opts.Trace {
+		log.Printf("Executing: %s", strings.Join(parts, " "))
+	}
+
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Env = append(os.Environ(), kvWatchCommandEnv(entry)...)
+	cmd.Stdin = bytes.NewReader(entry.Value())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// keeps the JSON updates on STDOUT parsable
+	if c.watchJSON {
+		cmd.Stdout = os.Stderr
+	}
+
+	return cmd.Run()
+}
+
+// kvWatchCommandEnv describes the entry to watch commands, the value is always passed on STDIN and also
+// in NATS_KV_VALUE when it is text no larger than kvWatchCommandValueLimit since binary or large values
+// can not be held in the environment
+func kvWatchCommandEnv(entry nats.KeyValueEntry) []string {
+	env := []string{
+		fmt.Sprintf("NATS_KV_BUCKET=%s", entry.Bucket()),
+		fmt.Sprintf("NATS_KV_KEY=%s", entry.Key()),
+		fmt.Sprintf("NATS_KV_REVISION=%d", entry.Revision()),
+		fmt.Sprintf("NATS_KV_OPERATION=%s", kvOperationName(entry.Operation())),
+		fmt.Sprintf("NATS_KV_CREATED=%s", entry.Created().UTC().Format(time.RFC3339Nano)),
+	}
+
+	val := entry.Value()
+	if len(val) <= kvWatchCommandValueLimit && utf8.Valid(val) && !bytes.ContainsRune(val, 0) {
+		env = append(env, fmt.Sprintf("NATS_KV_VALUE=%s", val))
+	}
+
+	return env
+}
+
 func (c *kvCommand) purgeAction(_ *fisk.ParseContext) error {
 	_, _, store, err := c.loadBucket()
 	if err != nil {
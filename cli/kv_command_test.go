@@ -0,0 +1,71 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestKVWatchCommand(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		store, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "CONFIG", History: 5})
+		checkErr(t, err, "create failed: %v", err)
+
+		_, err = store.PutString("server.port", "4222")
+		checkErr(t, err, "put failed: %v", err)
+		checkErr(t, store.Delete("server.port"), "delete failed")
+
+		out := filepath.Join(t.TempDir(), "out")
+		c := &kvCommand{watchCommand: fmt.Sprintf(`sh -c 'printf "%%s %%s %%s %%s %%s " "$NATS_KV_BUCKET" "$NATS_KV_KEY" "$NATS_KV_REVISION" "$NATS_KV_OPERATION" "$NATS_KV_VALUE" >> %s; cat >> %s; echo >> %s'`, out, out, out)}
+
+		history, err := store.History("server.port")
+		checkErr(t, err, "history failed: %v", err)
+		for _, entry := range history {
+			checkErr(t, c.runWatchCommand(entry), "command failed")
+		}
+
+		res, err := os.ReadFile(out)
+		checkErr(t, err, "read failed: %v", err)
+		expected := "CONFIG server.port 1 PUT 4222 4222\nCONFIG server.port 2 DELETE  \n"
+		if string(res) != expected {
+			t.Fatalf("expected %q got %q", expected, res)
+		}
+
+		_, err = store.Put("server.cert", []byte{0xff, 0})
+		checkErr(t, err, "put failed: %v", err)
+		entry, err := store.Get("server.cert")
+		checkErr(t, err, "get failed: %v", err)
+		for _, v := range kvWatchCommandEnv(entry) {
+			if strings.HasPrefix(v, "NATS_KV_VALUE=") {
+				t.Fatalf("expected binary values to be left out of the environment")
+			}
+		}
+
+		c.watchCommand = "false"
+		if c.runWatchCommand(history[0]) == nil {
+			t.Fatalf("expected a failing command to return an error")
+		}
+	})
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/nats.go"
+)
+
+type kvDiffCmd struct {
+	bucketA  string
+	bucketB  string
+	contextA string
+	contextB string
+	domainA  string
+	domainB  string
+	json     bool
+	dryRun   bool
+	delete   bool
+	force    bool
+
+	jsA nats.JetStreamContext
+	jsB nats.JetStreamContext
+}
+
+type kvDiffKey struct {
+	Key       string `json:"key"`
+	RevisionA uint64 `json:"revision_a,omitempty"`
+	RevisionB uint64 `json:"revision_b,omitempty"`
+
+	value []byte
+}
+
+type kvDiffResult struct {
+	BucketA string       `json:"bucket_a"`
+	BucketB string       `json:"bucket_b"`
+	Added   []*kvDiffKey `json:"added"`
+	Removed []*kvDiffKey `json:"removed"`
+	Changed []*kvDiffKey `json:"changed"`
+	Equal   int          `json:"equal"`
+}
+
+func (r *kvDiffResult) differs() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Changed) > 0
+}
+
+func configureKVDiffCommand(kv *fisk.CmdClause) {
+	c := &kvDiffCmd{}
+
+	diffHelp := `Compares the keys and values in two buckets
+
+The buckets are usually accessed using different contexts, for example
+buckets holding the same configuration in different regions. Keys only in
+the first bucket are reported as added, keys only in the second bucket as
+removed and keys with different values as changed, these are the changes
+nats kv sync would make to the second bucket.
+
+Values are compared, revisions are shown for reference only as they differ
+between buckets written independently.
+`
+
+	diff := kv.Command("diff", "Compares the contents of two buckets").Action(c.diffAction)
+	diff.HelpLong(diffHelp)
+	c.addFlags(diff)
+	diff.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+
+	syncHelp := `Updates a bucket to hold the same keys and values as another bucket
+
+Keys missing from the second bucket are added and changed keys are updated,
+updates only succeed when the key was not modified since the comparison.
+Keys only present in the second bucket are deleted unless --no-delete is
+given.
+`
+
+	sync := kv.Command("sync", "Copies differences from one bucket to another").Action(c.syncAction)
+	sync.HelpLong(syncHelp)
+	c.addFlags(sync)
+	sync.Flag("delete", "Deletes keys that are not in the first bucket").Default("true").BoolVar(&c.delete)
+	sync.Flag("dry-run", "Show the changes without applying them").UnNegatableBoolVar(&c.dryRun)
+	sync.Flag("force", "Apply the changes without prompting").Short('f').UnNegatableBoolVar(&c.force)
+}
+
+func (c *kvDiffCmd) addFlags(cmd *fisk.CmdClause) {
+	cmd.Arg("bucket", "The bucket to compare").Required().StringVar(&c.bucketA)
+	cmd.Arg("bucket-b", "The bucket to compare to when it has a different name").StringVar(&c.bucketB)
+	cmd.Flag("context-a", "Access the first bucket using a different context").PlaceHolder("CONTEXT").StringVar(&c.contextA)
+	cmd.Flag("context-b", "Access the second bucket using a different context").PlaceHolder("CONTEXT").StringVar(&c.contextB)
+	cmd.Flag("domain-a", "Access the first bucket in a different JetStream domain").PlaceHolder("DOMAIN").StringVar(&c.domainA)
+	cmd.Flag("domain-b", "Access the second bucket in a different JetStream domain").PlaceHolder("DOMAIN").StringVar(&c.domainB)
+}
+
+func (c *kvDiffCmd) connect() (func(), error) {
+	var ncA, ncB *nats.Conn
+	var err error
+
+	if c.bucketB == "" {
+		c.bucketB = c.bucketA
+	}
+
+	if c.bucketA == c.bucketB && c.contextA == c.contextB && c.domainA == c.domainB {
+		return nil, fmt.Errorf("a different bucket, context or domain is needed for the second bucket")
+	}
+
+	ncA, c.jsA, err = prepareContextJSHelper(c.contextA, c.domainA)
+	if err != nil {
+		return nil, fmt.Errorf("setup failed: %v", err)
+	}
+
+	ncB, c.jsB, err = prepareContextJSHelper(c.contextB, c.domainB)
+	if err != nil {
+		return nil, fmt.Errorf("setup failed: %v", err)
+	}
+
+	return func() {
+		if c.contextA != "" {
+			ncA.Close()
+		}
+		if c.contextB != "" {
+			ncB.Close()
+		}
+	}, nil
+}
+
+func (c *kvDiffCmd) diffAction(_ *fisk.ParseContext) error {
+	closer, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	res, err := c.diff()
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		err = printJSON(res)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.renderResult(res)
+	}
+
+	if res.differs() {
+		closer()
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func (c *kvDiffCmd) syncAction(_ *fisk.ParseContext) error {
+	closer, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	res, err := c.diff()
+	if err != nil {
+		return err
+	}
+
+	if !c.delete {
+		res.Removed = nil
+	}
+
+	c.renderResult(res)
+
+	if !res.differs() || c.dryRun {
+		return nil
+	}
+
+	if !c.force {
+		ok, err := askConfirmation(fmt.Sprintf("Really add %d, update %d and delete %d keys in bucket %s", len(res.Added), len(res.Changed), len(res.Removed), c.bucketB), false)
+		if err != nil {
+			return fmt.Errorf("could not obtain confirmation: %v", err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	err = c.sync(res)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %d, updated %d and deleted %d keys in bucket %s\n", len(res.Added), len(res.Changed), len(res.Removed), c.bucketB)
+
+	return nil
+}
+
+func (c *kvDiffCmd) renderResult(res *kvDiffResult) {
+	cols := newColumns(fmt.Sprintf("Differences between bucket %s and %s", res.BucketA, res.BucketB))
+	cols.AddRow("Equal Keys", res.Equal)
+	cols.AddRow("Added Keys", len(res.Added))
+	cols.AddRow("Removed Keys", len(res.Removed))
+	cols.AddRow("Changed Keys", len(res.Changed))
+	cols.Frender(os.Stdout)
+	fmt.Println()
+
+	if !res.differs() {
+		return
+	}
+
+	table := newTableWriter("Differences")
+	table.AddHeaders("Key", "Change", "First Revision", "Second Revision")
+	for _, k := range res.Added {
+		table.AddRow(k.Key, "added", f(k.RevisionA), "")
+	}
+	for _, k := range res.Removed {
+		table.AddRow(k.Key, "removed", "", f(k.RevisionB))
+	}
+	for _, k := range res.Changed {
+		table.AddRow(k.Key, "changed", f(k.RevisionA), f(k.RevisionB))
+	}
+	fmt.Println(table.Render())
+}
+
+func (c *kvDiffCmd) diff() (*kvDiffResult, error) {
+	exportA, err := (&kvExportCmd{js: c.jsA, bucket: c.bucketA}).export()
+	if err != nil {
+		return nil, fmt.Errorf("could not read bucket %s: %v", c.bucketA, err)
+	}
+
+	exportB, err := (&kvExportCmd{js: c.jsB, bucket: c.bucketB}).export()
+	if err != nil {
+		return nil, fmt.Errorf("could not read bucket %s: %v", c.bucketB, err)
+	}
+
+	res := &kvDiffResult{
+		BucketA: c.bucketA,
+		BucketB: c.bucketB,
+		Added:   []*kvDiffKey{},
+		Removed: []*kvDiffKey{},
+		Changed: []*kvDiffKey{},
+	}
+
+	entriesB := map[string]*kvExportEntry{}
+	for _, entry := range exportB.Entries {
+		entriesB[entry.Key] = entry
+	}
+
+	for _, a := range exportA.Entries {
+		b, ok := entriesB[a.Key]
+		delete(entriesB, a.Key)
+
+		switch {
+		case !ok:
+			res.Added = append(res.Added, &kvDiffKey{Key: a.Key, RevisionA: a.Revision, value: a.Value})
+		case !bytes.Equal(a.Value, b.Value):
+			res.Changed = append(res.Changed, &kvDiffKey{Key: a.Key, RevisionA: a.Revision, RevisionB: b.Revision, value: a.Value})
+		default:
+			res.Equal++
+		}
+	}
+
+	for _, b := range entriesB {
+		res.Removed = append(res.Removed, &kvDiffKey{Key: b.Key, RevisionB: b.Revision})
+	}
+
+	for _, keys := range [][]*kvDiffKey{res.Added, res.Removed, res.Changed} {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	}
+
+	return res, nil
+}
+
+// sync applies the differences to the second bucket, changes are made using the revisions seen during the comparison
+func (c *kvDiffCmd) sync(res *kvDiffResult) error {
+	store, err := c.jsB.KeyValue(c.bucketB)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range res.Added {
+		_, err = store.Create(k.Key, k.value)
+		if err != nil {
+			return fmt.Errorf("could not add key %s: %v", k.Key, err)
+		}
+	}
+
+	for _, k := range res.Changed {
+		_, err = store.Update(k.Key, k.value, k.RevisionB)
+		if err != nil {
+			return fmt.Errorf("could not update key %s: %v", k.Key, err)
+		}
+	}
+
+	for _, k := range res.Removed {
+		err = store.Delete(k.Key, nats.LastRevision(k.RevisionB))
+		if err != nil {
+			return fmt.Errorf("could not delete key %s: %v", k.Key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestKVDiffSync(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		east, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "EAST"})
+		checkErr(t, err, "create failed: %v", err)
+		west, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "WEST"})
+		checkErr(t, err, "create failed: %v", err)
+
+		for k, v := range map[string]string{"same": "1", "changed": "new", "added": "x"} {
+			_, err = east.PutString(k, v)
+			checkErr(t, err, "put failed: %v", err)
+		}
+		for k, v := range map[string]string{"same": "1", "changed": "old", "removed": "y", "deleted": "z"} {
+			_, err = west.PutString(k, v)
+			checkErr(t, err, "put failed: %v", err)
+		}
+		checkErr(t, west.Delete("deleted"), "delete failed")
+
+		c := &kvDiffCmd{bucketA: "EAST", bucketB: "WEST", jsA: js, jsB: js}
+		res, err := c.diff()
+		checkErr(t, err, "diff failed: %v", err)
+
+		keys := func(dk []*kvDiffKey) []string {
+			var res []string
+			for _, k := range dk {
+				res = append(res, k.Key)
+			}
+			return res
+		}
+
+		assertListEquals(t, keys(res.Added), "added")
+		assertListEquals(t, keys(res.Removed), "removed")
+		assertListEquals(t, keys(res.Changed), "changed")
+		if res.Equal != 1 || !res.differs() {
+			t.Fatalf("invalid result: %+v", res)
+		}
+
+		checkErr(t, c.sync(res), "sync failed")
+
+		res, err = c.diff()
+		checkErr(t, err, "diff failed: %v", err)
+		if res.differs() || res.Equal != 3 {
+			t.Fatalf("buckets differ after sync: %+v", res)
+		}
+
+		_, err = east.PutString("same", "2")
+		checkErr(t, err, "put failed: %v", err)
+		res, err = c.diff()
+		checkErr(t, err, "diff failed: %v", err)
+
+		// a change in the second bucket after the comparison must not be overwritten
+		_, err = west.PutString("same", "3")
+		checkErr(t, err, "put failed: %v", err)
+		if c.sync(res) == nil {
+			t.Fatalf("expected sync to fail")
+		}
+	})
+}
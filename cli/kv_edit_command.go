@@ -0,0 +1,204 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/nats.go"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+type kvEditCmd struct {
+	bucket   string
+	key      string
+	schema   string
+	template string
+
+	js nats.JetStreamContext
+}
+
+func configureKVEditCommand(kv *fisk.CmdClause) {
+	c := &kvEditCmd{}
+
+	help := `Edits the value of a key in your EDITOR
+
+The value is written back using the revision it was read at, should the
+key be modified while editing the update fails and the edited value is kept
+in a temporary file.
+
+Values can be validated against a JSON Schema, invalid values can be edited
+again. Keys that do not exist are created, starting with the contents of a
+template file when one is given.
+`
+
+	edit := kv.Command("edit", "Edits the value of a key in your EDITOR").Alias("vi").Action(c.editAction)
+	edit.HelpLong(help)
+	edit.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
+	edit.Arg("key", "The key to act on").Required().StringVar(&c.key)
+	edit.Flag("schema", "Validates the value against a JSON Schema").PlaceHolder("FILE").ExistingFileVar(&c.schema)
+	edit.Flag("template", "Initial value for keys that do not exist").PlaceHolder("FILE").ExistingFileVar(&c.template)
+}
+
+func (c *kvEditCmd) editAction(_ *fisk.ParseContext) error {
+	var err error
+
+	if os.Getenv("EDITOR") == "" {
+		return fmt.Errorf("set EDITOR environment variable to your chosen editor")
+	}
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	rev, err := c.edit()
+	if err != nil {
+		return err
+	}
+
+	if rev == 0 {
+		fmt.Printf("%s > %s was not changed\n", c.bucket, c.key)
+		return nil
+	}
+
+	fmt.Printf("Stored %s > %s revision %d\n", c.bucket, c.key, rev)
+
+	return nil
+}
+
+// edit opens the value in the editor and stores the result, returns 0 when the value was not changed
+func (c *kvEditCmd) edit() (uint64, error) {
+	store, err := c.js.KeyValue(c.bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	var schema *jsonschema.Schema
+	if c.schema != "" {
+		schema, err = jsonschema.Compile(c.schema)
+		if err != nil {
+			return 0, fmt.Errorf("could not load schema %s: %v", c.schema, err)
+		}
+	}
+
+	var original []byte
+	var rev uint64
+
+	entry, err := store.Get(c.key)
+	switch {
+	case errors.Is(err, nats.ErrKeyNotFound):
+		if c.template != "" {
+			original, err = os.ReadFile(c.template)
+			if err != nil {
+				return 0, err
+			}
+		}
+	case err != nil:
+		return 0, err
+	default:
+		original = entry.Value()
+		rev = entry.Revision()
+	}
+
+	ext := "*.txt"
+	if schema != nil || json.Valid(original) {
+		ext = "*.json"
+	}
+
+	tfile, err := os.CreateTemp("", ext)
+	if err != nil {
+		return 0, fmt.Errorf("could not create temporary copy to edit: %w", err)
+	}
+	tfile.Write(original)
+	tfile.Close()
+
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(tfile.Name())
+		}
+	}()
+
+	var val []byte
+	for {
+		cmd := exec.Command(os.Getenv("EDITOR"), tfile.Name())
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+		if err != nil {
+			return 0, fmt.Errorf("could not edit value: %s", err)
+		}
+
+		val, err = os.ReadFile(tfile.Name())
+		if err != nil {
+			return 0, fmt.Errorf("could not read temporary file: %s", err)
+		}
+
+		if bytes.Equal(val, original) && (rev > 0 || len(val) == 0) {
+			return 0, nil
+		}
+
+		if schema == nil {
+			break
+		}
+
+		errs := validateKVValue(schema, val)
+		if len(errs) == 0 {
+			break
+		}
+
+		fmt.Printf("Invalid value:\n\n  %s\n\n", strings.Join(errs, "\n  "))
+		ok, err := askConfirmation("Retry edit", true)
+		if err != nil || !ok {
+			return 0, fmt.Errorf("value does not match schema %s", c.schema)
+		}
+	}
+
+	if rev == 0 {
+		rev, err = store.Create(c.key, val)
+	} else {
+		rev, err = store.Update(c.key, val, rev)
+	}
+	if errors.Is(err, nats.ErrKeyExists) {
+		keep = true
+		return 0, fmt.Errorf("%s > %s was modified while editing, the edited value was saved in %s", c.bucket, c.key, tfile.Name())
+	}
+
+	return rev, err
+}
+
+func validateKVValue(schema *jsonschema.Schema, val []byte) []string {
+	var d any
+	err := json.Unmarshal(val, &d)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %s", err)}
+	}
+
+	err = schema.Validate(d)
+	if err != nil {
+		return schemaValidationErrors(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestKVEdit(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		store, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "CONFIG"})
+		checkErr(t, err, "create failed: %v", err)
+
+		dir := t.TempDir()
+		schema := filepath.Join(dir, "schema.json")
+		err = os.WriteFile(schema, []byte(`{"type":"object","required":["port"],"properties":{"port":{"type":"integer"}}}`), 0600)
+		checkErr(t, err, "write failed: %v", err)
+
+		// the editor replaces the value with the contents of the value file
+		value := filepath.Join(dir, "value")
+		editor := filepath.Join(dir, "editor.sh")
+		err = os.WriteFile(editor, []byte(fmt.Sprintf("#!/bin/sh\ncp %s \"$1\"\n", value)), 0700)
+		checkErr(t, err, "write failed: %v", err)
+		t.Setenv("EDITOR", editor)
+
+		setValue := func(v string) {
+			checkErr(t, os.WriteFile(value, []byte(v), 0600), "write failed")
+		}
+
+		c := &kvEditCmd{js: js, bucket: "CONFIG", key: "server", schema: schema}
+
+		setValue(`{"port": 4222}`)
+		rev, err := c.edit()
+		checkErr(t, err, "edit failed: %v", err)
+		if rev != 1 {
+			t.Fatalf("expected revision 1 got %d", rev)
+		}
+
+		rev, err = c.edit()
+		checkErr(t, err, "edit failed: %v", err)
+		if rev != 0 {
+			t.Fatalf("expected no change got revision %d", rev)
+		}
+
+		setValue(`{"port": "invalid"}`)
+		_, err = c.edit()
+		if err == nil || !strings.Contains(err.Error(), "does not match schema") {
+			t.Fatalf("expected schema failure got %v", err)
+		}
+
+		setValue(`{"port": 4223}`)
+		rev, err = c.edit()
+		checkErr(t, err, "edit failed: %v", err)
+		entry, err := store.Get("server")
+		checkErr(t, err, "get failed: %v", err)
+		if rev != 2 || string(entry.Value()) != `{"port": 4223}` {
+			t.Fatalf("invalid value at revision %d: %s", entry.Revision(), entry.Value())
+		}
+
+		// an unchanged template is stored for new keys
+		template := filepath.Join(dir, "template.json")
+		checkErr(t, os.WriteFile(template, []byte(`{"port": 1}`), 0600), "write failed")
+		setValue(`{"port": 1}`)
+		c = &kvEditCmd{js: js, bucket: "CONFIG", key: "other", template: template}
+		rev, err = c.edit()
+		checkErr(t, err, "edit failed: %v", err)
+		if rev != 3 {
+			t.Fatalf("expected the template to be stored at revision 3 got %d", rev)
+		}
+	})
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/nats.go"
+)
+
+type kvExportCmd struct {
+	bucket  string
+	file    string
+	history bool
+	mode    string
+
+	js nats.JetStreamContext
+}
+
+type kvExport struct {
+	Bucket  string           `json:"bucket"`
+	Time    time.Time        `json:"time"`
+	History bool             `json:"history"`
+	Entries []*kvExportEntry `json:"entries"`
+}
+
+type kvExportEntry struct {
+	Key       string    `json:"key"`
+	Revision  uint64    `json:"revision"`
+	Operation string    `json:"operation"`
+	Created   time.Time `json:"created"`
+	Value     []byte    `json:"value,omitempty"`
+}
+
+type kvImportResult struct {
+	imported  int
+	skipped   int
+	conflicts int
+}
+
+func configureKVExportCommand(kv *fisk.CmdClause) {
+	c := &kvExportCmd{}
+
+	exportHelp := `Exports the keys and values in a bucket as JSON
+
+Every key is exported with its value, revision and creation time. Using
+--history all values kept in the bucket are exported including delete and
+purge markers.
+
+When no file is given the export is written to STDOUT.
+`
+
+	export := kv.Command("export", "Exports the contents of a bucket").Action(c.exportAction)
+	export.HelpLong(exportHelp)
+	export.Arg("bucket", "The bucket to export").Required().StringVar(&c.bucket)
+	export.Arg("file", "File to write the export to").StringVar(&c.file)
+	export.Flag("history", "Export all historic values and delete markers").UnNegatableBoolVar(&c.history)
+
+	importHelp := `Imports keys and values exported using nats kv export
+
+The import mode controls how existing keys are handled:
+
+   create     only stores keys that do not exist in the bucket
+   overwrite  stores all keys, replacing existing values
+   cas        updates keys only when their revision in the bucket matches
+              the revision recorded in the export
+
+Exports holding history can only be imported using the overwrite mode, the
+values are stored in their original order.
+
+When the file is - the export is read from STDIN.
+`
+
+	imp := kv.Command("import", "Imports keys and values into a bucket").Action(c.importAction)
+	imp.HelpLong(importHelp)
+	imp.Arg("bucket", "The bucket to import into").Required().StringVar(&c.bucket)
+	imp.Arg("file", "File holding the export").Required().StringVar(&c.file)
+	imp.Flag("mode", "How to handle existing keys (create, overwrite, cas)").Default("create").EnumVar(&c.mode, "create", "overwrite", "cas")
+}
+
+func (c *kvExportCmd) exportAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	export, err := c.export()
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if c.file != "" {
+		out, err = os.Create(c.file)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(export)
+	if err != nil {
+		return err
+	}
+
+	if c.file != "" {
+		fmt.Printf("Exported %s entries from bucket %s to %s\n", f(len(export.Entries)), c.bucket, c.file)
+	}
+
+	return nil
+}
+
+func (c *kvExportCmd) export() (*kvExport, error) {
+	store, err := c.js.KeyValue(c.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	wopts := []nats.WatchOpt{nats.IgnoreDeletes()}
+	if c.history {
+		wopts = []nats.WatchOpt{nats.IncludeHistory()}
+	}
+
+	watch, err := store.WatchAll(wopts...)
+	if err != nil {
+		return nil, err
+	}
+	defer watch.Stop()
+
+	export := &kvExport{
+		Bucket:  c.bucket,
+		Time:    time.Now().UTC(),
+		History: c.history,
+		Entries: []*kvExportEntry{},
+	}
+
+	timeout := opts.Timeout
+	if timeout < 5*time.Second {
+		timeout = 5 * time.Second
+	}
+
+	for {
+		select {
+		case entry := <-watch.Updates():
+			// a nil entry marks the end of the initial values
+			if entry == nil {
+				return export, nil
+			}
+
+			export.Entries = append(export.Entries, &kvExportEntry{
+				Key:       entry.Key(),
+				Revision:  entry.Revision(),
+				Operation: kvOperationName(entry.Operation()),
+				Created:   entry.Created().UTC(),
+				Value:     entry.Value(),
+			})
+
+		case <-time.After(timeout):
+			return nil, fmt.Errorf("timeout while reading bucket %s", c.bucket)
+		}
+	}
+}
+
+func (c *kvExportCmd) importAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	in := os.Stdin
+	if c.file != "-" {
+		in, err = os.Open(c.file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	res, err := c.importEntries(in)
+	if err != nil {
+		return fmt.Errorf("import failed after %s entries: %v", f(res.imported), err)
+	}
+
+	fmt.Printf("Imported %s entries into bucket %s, %s existing keys were skipped and %s had conflicting revisions\n", f(res.imported), c.bucket, f(res.skipped), f(res.conflicts))
+
+	return nil
+}
+
+func (c *kvExportCmd) importEntries(in io.Reader) (*kvImportResult, error) {
+	res := &kvImportResult{}
+
+	var export kvExport
+	err := json.NewDecoder(in).Decode(&export)
+	if err != nil {
+		return res, fmt.Errorf("invalid export: %v", err)
+	}
+
+	if export.History && c.mode != "overwrite" {
+		return res, fmt.Errorf("exports holding history can only be imported using --mode overwrite")
+	}
+
+	store, err := c.js.KeyValue(c.bucket)
+	if err != nil {
+		return res, err
+	}
+
+	for _, entry := range export.Entries {
+		switch entry.Operation {
+		case kvOperationName(nats.KeyValueDelete):
+			err = store.Delete(entry.Key)
+		case kvOperationName(nats.KeyValuePurge):
+			err = store.Purge(entry.Key)
+		case kvOperationName(nats.KeyValuePut):
+			switch c.mode {
+			case "create":
+				_, err = store.Create(entry.Key, entry.Value)
+				if errors.Is(err, nats.ErrKeyExists) {
+					res.skipped++
+					continue
+				}
+			case "cas":
+				_, err = store.Update(entry.Key, entry.Value, entry.Revision)
+				if errors.Is(err, nats.ErrKeyExists) {
+					res.conflicts++
+					continue
+				}
+			default:
+				_, err = store.Put(entry.Key, entry.Value)
+			}
+		default:
+			return res, fmt.Errorf("unknown operation %q for key %s", entry.Operation, entry.Key)
+		}
+		if err != nil {
+			return res, fmt.Errorf("could not import key %s: %v", entry.Key, err)
+		}
+
+		res.imported++
+	}
+
+	return res, nil
+}
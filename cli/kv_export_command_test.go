@@ -0,0 +1,115 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestKVExportImport(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		src, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "CONFIG", History: 5})
+		checkErr(t, err, "create failed: %v", err)
+
+		for _, kv := range [][2]string{{"username", "bob"}, {"password", "secret"}, {"username", "alice"}, {"removed", "x"}} {
+			_, err = src.PutString(kv[0], kv[1])
+			checkErr(t, err, "put failed: %v", err)
+		}
+		checkErr(t, src.Delete("removed"), "delete failed")
+
+		c := &kvExportCmd{js: js, bucket: "CONFIG"}
+		export, err := c.export()
+		checkErr(t, err, "export failed: %v", err)
+		if len(export.Entries) != 2 || export.History {
+			t.Fatalf("expected 2 entries without history: %+v", export.Entries)
+		}
+
+		c.history = true
+		history, err := c.export()
+		checkErr(t, err, "export failed: %v", err)
+		if len(history.Entries) != 5 || !history.History {
+			t.Fatalf("expected 5 entries with history: %+v", history.Entries)
+		}
+
+		encode := func(e *kvExport) *bytes.Buffer {
+			buf := &bytes.Buffer{}
+			checkErr(t, json.NewEncoder(buf).Encode(e), "encode failed")
+			return buf
+		}
+
+		dst, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "RESTORE", History: 5})
+		checkErr(t, err, "create failed: %v", err)
+		_, err = dst.PutString("username", "existing")
+		checkErr(t, err, "put failed: %v", err)
+		_, err = dst.PutString("other", "value")
+		checkErr(t, err, "put failed: %v", err)
+
+		c = &kvExportCmd{js: js, bucket: "RESTORE", mode: "create"}
+		_, err = c.importEntries(encode(history))
+		if err == nil {
+			t.Fatalf("expected history import in create mode to fail")
+		}
+
+		res, err := c.importEntries(encode(export))
+		checkErr(t, err, "import failed: %v", err)
+		if res.imported != 1 || res.skipped != 1 {
+			t.Fatalf("invalid create result: %+v", res)
+		}
+		entry, err := dst.Get("username")
+		checkErr(t, err, "get failed: %v", err)
+		if string(entry.Value()) != "existing" {
+			t.Fatalf("existing key was overwritten: %s", entry.Value())
+		}
+
+		// the revisions in RESTORE differ from those recorded in the export
+		c.mode = "cas"
+		res, err = c.importEntries(encode(export))
+		checkErr(t, err, "import failed: %v", err)
+		if res.imported != 0 || res.conflicts != 2 {
+			t.Fatalf("invalid cas result: %+v", res)
+		}
+
+		c.mode = "overwrite"
+		res, err = c.importEntries(encode(history))
+		checkErr(t, err, "import failed: %v", err)
+		if res.imported != 5 {
+			t.Fatalf("invalid overwrite result: %+v", res)
+		}
+		entry, err = dst.Get("username")
+		checkErr(t, err, "get failed: %v", err)
+		if string(entry.Value()) != "alice" {
+			t.Fatalf("expected alice got %s", entry.Value())
+		}
+		_, err = dst.Get("removed")
+		if err != nats.ErrKeyNotFound {
+			t.Fatalf("expected removed to be deleted: %v", err)
+		}
+
+		c = &kvExportCmd{js: js, bucket: "CONFIG", mode: "cas"}
+		res, err = c.importEntries(encode(export))
+		checkErr(t, err, "import failed: %v", err)
+		if res.imported != 2 || res.conflicts != 0 {
+			t.Fatalf("invalid cas result: %+v", res)
+		}
+	})
+}
@@ -46,6 +46,9 @@ type objCommand struct {
 	maxBucketSize       int64
 	maxBucketSizeString string
 	metadata            map[string]string
+	linkBucket          string
+	linkObject          string
+	metaKeys            []string
 
 	description string
 	replicas    uint
@@ -118,6 +121,29 @@ NOTE: This is an experimental feature.
 
 	watch := obj.Command("watch", "Watch a bucket for changes").Action(c.watchAction)
 	watch.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
+
+	link := obj.Command("link", "Adds a link to an object or bucket").Action(c.linkAction)
+	link.Arg("bucket", "The bucket to add the link to").Required().StringVar(&c.bucket)
+	link.Arg("name", "The name of the link").Required().StringVar(&c.file)
+	link.Arg("target-bucket", "The bucket to link to").Required().StringVar(&c.linkBucket)
+	link.Arg("target-object", "The object to link to, links to the entire bucket when not set").StringVar(&c.linkObject)
+
+	meta := obj.Command("meta", "Manage the metadata of objects")
+
+	metaSet := meta.Command("set", "Sets metadata on an object").Action(c.metaSetAction)
+	metaSet.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
+	metaSet.Arg("file", "The object to act on").Required().StringVar(&c.file)
+	metaSet.Arg("metadata", "Metadata to set as key=value pairs").StringMapVar(&c.metadata)
+	metaSet.Flag("description", "Sets the description of the object").StringVar(&c.description)
+	metaSet.Flag("header", "Sets headers on the object, replacing existing headers").Short('H').StringsVar(&c.hdrs)
+
+	metaRm := meta.Command("rm", "Removes metadata from an object").Alias("del").Action(c.metaRmAction)
+	metaRm.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
+	metaRm.Arg("file", "The object to act on").Required().StringVar(&c.file)
+	metaRm.Arg("keys", "Metadata keys to remove").Required().StringsVar(&c.metaKeys)
+
+	configureObjectSyncCommand(obj)
+	configureObjectVerifyCommand(obj)
 }
 
 func init() {
@@ -187,6 +213,111 @@ func (c *objCommand) sealAction(_ *fisk.ParseContext) error {
 	return c.showBucketInfo(obj)
 }
 
+func (c *objCommand) linkAction(_ *fisk.ParseContext) error {
+	_, js, obj, err := c.loadBucket()
+	if err != nil {
+		return err
+	}
+
+	target, err := js.ObjectStore(c.linkBucket)
+	if err != nil {
+		return err
+	}
+
+	var nfo *nats.ObjectInfo
+	if c.linkObject == "" {
+		nfo, err = obj.AddBucketLink(c.file, target)
+	} else {
+		var tnfo *nats.ObjectInfo
+		tnfo, err = target.GetInfo(c.linkObject)
+		if err != nil {
+			return err
+		}
+		nfo, err = obj.AddLink(c.file, tnfo)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.showObjectInfo(nfo)
+
+	return nil
+}
+
+func (c *objCommand) metaSetAction(_ *fisk.ParseContext) error {
+	if len(c.metadata) == 0 && c.description == "" && len(c.hdrs) == 0 {
+		return fmt.Errorf("metadata, --description or --header is required")
+	}
+
+	return c.updateMeta(func(meta *nats.ObjectMeta) error {
+		if meta.Metadata == nil {
+			meta.Metadata = map[string]string{}
+		}
+		for k, v := range c.metadata {
+			meta.Metadata[k] = v
+		}
+
+		if c.description != "" {
+			meta.Description = c.description
+		}
+
+		if len(c.hdrs) > 0 {
+			hdr, err := parseStringsToHeader(c.hdrs, 0)
+			if err != nil {
+				return err
+			}
+			meta.Headers = hdr
+		}
+
+		return nil
+	})
+}
+
+func (c *objCommand) metaRmAction(_ *fisk.ParseContext) error {
+	return c.updateMeta(func(meta *nats.ObjectMeta) error {
+		for _, k := range c.metaKeys {
+			if _, ok := meta.Metadata[k]; !ok {
+				return fmt.Errorf("object %s > %s has no metadata %s", c.bucket, c.file, k)
+			}
+			delete(meta.Metadata, k)
+		}
+
+		return nil
+	})
+}
+
+func (c *objCommand) updateMeta(update func(meta *nats.ObjectMeta) error) error {
+	_, _, obj, err := c.loadBucket()
+	if err != nil {
+		return err
+	}
+
+	nfo, err := obj.GetInfo(c.file)
+	if err != nil {
+		return err
+	}
+
+	meta := nfo.ObjectMeta
+	err = update(&meta)
+	if err != nil {
+		return err
+	}
+
+	err = obj.UpdateMeta(c.file, &meta)
+	if err != nil {
+		return err
+	}
+
+	nfo, err = obj.GetInfo(c.file)
+	if err != nil {
+		return err
+	}
+
+	c.showObjectInfo(nfo)
+
+	return nil
+}
+
 func (c *objCommand) delAction(_ *fisk.ParseContext) error {
 	_, _, obj, err := c.loadBucket()
 	if err != nil {
@@ -317,20 +448,34 @@ func (c *objCommand) showBucketInfo(store nats.ObjectStore) error {
 }
 
 func (c *objCommand) showObjectInfo(nfo *nats.ObjectInfo) {
-	digest := strings.SplitN(nfo.Digest, "=", 2)
-	digestBytes, _ := base64.URLEncoding.DecodeString(digest[1])
-
 	cols := newColumns(fmt.Sprintf("Object information for %s > %s", nfo.Bucket, nfo.Name))
 	defer cols.Frender(os.Stdout)
 
 	if nfo.Description != "" {
 		cols.AddRowIfNotEmpty("Description", nfo.Description)
 	}
-	cols.AddRow("Size", fiBytes(nfo.Size))
-	cols.AddRow("Modification Time", nfo.ModTime)
-	cols.AddRow("Chunks", nfo.Chunks)
-	cols.AddRowf("Digest", "%s %x", digest[0], digestBytes)
+
+	if nfo.Opts != nil && nfo.Opts.Link != nil {
+		if nfo.Opts.Link.Name == "" {
+			cols.AddRowf("Link", "bucket %s", nfo.Opts.Link.Bucket)
+		} else {
+			cols.AddRowf("Link", "%s > %s", nfo.Opts.Link.Bucket, nfo.Opts.Link.Name)
+		}
+		cols.AddRow("Modification Time", nfo.ModTime)
+	} else {
+		digest := strings.SplitN(nfo.Digest, "=", 2)
+		cols.AddRow("Size", fiBytes(nfo.Size))
+		cols.AddRow("Modification Time", nfo.ModTime)
+		cols.AddRow("Chunks", nfo.Chunks)
+		if len(digest) == 2 {
+			digestBytes, _ := base64.URLEncoding.DecodeString(digest[1])
+			cols.AddRowf("Digest", "%s %x", digest[0], digestBytes)
+		}
+	}
 	cols.AddRowIf("Deleted", nfo.Deleted, nfo.Deleted)
+	if len(nfo.Metadata) > 0 {
+		cols.AddMapStringsAsValue("Metadata", nfo.Metadata)
+	}
 	if len(nfo.Headers) > 0 {
 		var vals []string
 		for k, v := range nfo.Headers {
@@ -0,0 +1,79 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestObjectLinkAndMeta(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		timeout := opts.Timeout
+		opts.Timeout = 5 * time.Second
+		defer func() { opts.Timeout = timeout }()
+
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		store, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "ARTEFACTS"})
+		checkErr(t, err, "create failed: %v", err)
+		_, err = js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "RELEASES"})
+		checkErr(t, err, "create failed: %v", err)
+
+		_, err = store.PutString("build.tgz", "build")
+		checkErr(t, err, "put failed: %v", err)
+
+		c := &objCommand{bucket: "ARTEFACTS", file: "latest.tgz", linkBucket: "ARTEFACTS", linkObject: "build.tgz"}
+		checkErr(t, c.linkAction(nil), "link failed")
+		val, err := store.GetString("latest.tgz")
+		checkErr(t, err, "get failed: %v", err)
+		if val != "build" {
+			t.Fatalf("invalid link value %q", val)
+		}
+
+		c = &objCommand{bucket: "ARTEFACTS", file: "releases", linkBucket: "RELEASES"}
+		checkErr(t, c.linkAction(nil), "link failed")
+		nfo, err := store.GetInfo("releases")
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.Opts == nil || nfo.Opts.Link == nil || nfo.Opts.Link.Bucket != "RELEASES" || nfo.Opts.Link.Name != "" {
+			t.Fatalf("invalid bucket link: %+v", nfo.Opts)
+		}
+
+		c = &objCommand{bucket: "ARTEFACTS", file: "build.tgz", metadata: map[string]string{"commit": "abc", "branch": "main"}, description: "nightly"}
+		checkErr(t, c.metaSetAction(nil), "meta set failed")
+
+		c = &objCommand{bucket: "ARTEFACTS", file: "build.tgz", metaKeys: []string{"branch"}}
+		checkErr(t, c.metaRmAction(nil), "meta rm failed")
+		if c.metaRmAction(nil) == nil {
+			t.Fatalf("expected removing missing metadata to fail")
+		}
+
+		nfo, err = store.GetInfo("build.tgz")
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.Description != "nightly" || len(nfo.Metadata) != 1 || nfo.Metadata["commit"] != "abc" {
+			t.Fatalf("invalid metadata: %+v", nfo.ObjectMeta)
+		}
+
+		val, err = store.GetString("build.tgz")
+		checkErr(t, err, "get failed: %v", err)
+		if val != "build" {
+			t.Fatalf("object data changed: %q", val)
+		}
+	})
+}
@@ -0,0 +1,519 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/dustin/go-humanize"
+	"github.com/gosuri/uiprogress"
+	"github.com/nats-io/nats.go"
+)
+
+// objSyncMtimeMeta is the object metadata holding the modification time of uploaded files
+const objSyncMtimeMeta = "mtime"
+
+type objSyncCmd struct {
+	source       string
+	destination  string
+	up           bool
+	delete       bool
+	dryRun       bool
+	force        bool
+	workers      int
+	showProgress bool
+
+	js nats.JetStreamContext
+}
+
+type objSyncFile struct {
+	name  string
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+type objSyncPlan struct {
+	upload    bool
+	dir       string
+	bucket    string
+	prefix    string
+	transfer  []*objSyncFile
+	remove    []*objSyncFile
+	unchanged int
+}
+
+func configureObjectSyncCommand(obj *fisk.CmdClause) {
+	c := &objSyncCmd{}
+
+	help := `Synchronizes a local directory with a bucket
+
+Use sync up to upload the files in a local directory to the bucket and sync
+down to download the objects in the bucket into a local directory. The bucket
+is given as BUCKET or BUCKET/prefix, object names are the paths of the files
+relative to the directory below the prefix.
+
+Files are compared by size and modification time, recorded in the object
+metadata, and by SHA-256 digest when those differ. Only files that changed
+are transferred.
+
+Using --delete objects or files that do not exist in the source are removed.
+
+Examples:
+
+   nats object sync up ./build ARTEFACTS/builds
+   nats object sync down ARTEFACTS/builds ./build
+`
+
+	sync := obj.Command("sync", "Synchronizes a local directory with a bucket")
+	sync.HelpLong(help)
+
+	up := sync.Command("up", "Uploads a local directory to a bucket").Action(c.upAction)
+	up.Arg("directory", "The local directory to copy from").Required().ExistingDirVar(&c.source)
+	up.Arg("bucket", "The BUCKET/prefix to copy to").Required().StringVar(&c.destination)
+
+	down := sync.Command("down", "Downloads a bucket into a local directory").Action(c.downAction)
+	down.Arg("bucket", "The BUCKET/prefix to copy from").Required().StringVar(&c.source)
+	down.Arg("directory", "The local directory to copy to").Required().StringVar(&c.destination)
+
+	for _, cmd := range []*fisk.CmdClause{up, down} {
+		cmd.Flag("delete", "Removes objects or files not present in the source").UnNegatableBoolVar(&c.delete)
+		cmd.Flag("dry-run", "Show the changes without applying them").UnNegatableBoolVar(&c.dryRun)
+		cmd.Flag("workers", "Number of files to transfer in parallel").Default("4").IntVar(&c.workers)
+		cmd.Flag("progress", "Enable progress bar").Default("true").BoolVar(&c.showProgress)
+		cmd.Flag("force", "Remove files without prompting").Short('f').UnNegatableBoolVar(&c.force)
+	}
+}
+
+func (c *objSyncCmd) upAction(pc *fisk.ParseContext) error {
+	c.up = true
+	return c.syncAction(pc)
+}
+
+func (c *objSyncCmd) downAction(pc *fisk.ParseContext) error {
+	c.up = false
+	return c.syncAction(pc)
+}
+
+func (c *objSyncCmd) syncAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	plan, err := c.plan()
+	if err != nil {
+		return err
+	}
+
+	if c.dryRun {
+		c.renderPlan(plan)
+		return nil
+	}
+
+	if len(plan.remove) > 0 && !c.force {
+		ok, err := askConfirmation(fmt.Sprintf("Really remove %d files from %s", len(plan.remove), c.destination), false)
+		if err != nil {
+			return fmt.Errorf("could not obtain confirmation: %v", err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	err = c.apply(plan)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Transferred %s files, removed %s and skipped %s unchanged files\n", f(len(plan.transfer)), f(len(plan.remove)), f(plan.unchanged))
+
+	return nil
+}
+
+func (c *objSyncCmd) renderPlan(plan *objSyncPlan) {
+	action := "download"
+	if plan.upload {
+		action = "upload"
+	}
+
+	table := newTableWriter(fmt.Sprintf("Synchronization of %s to %s", c.source, c.destination))
+	table.AddHeaders("Action", "Name", "Size")
+	for _, file := range plan.transfer {
+		table.AddRow(action, file.name, humanize.IBytes(uint64(file.size)))
+	}
+	for _, file := range plan.remove {
+		table.AddRow("remove", file.name, humanize.IBytes(uint64(file.size)))
+	}
+	fmt.Println(table.Render())
+	fmt.Printf("%s files are unchanged\n", f(plan.unchanged))
+}
+
+// parseObjSyncTarget splits BUCKET/prefix
+func parseObjSyncTarget(target string) (string, string) {
+	bucket, prefix, _ := strings.Cut(target, "/")
+	return bucket, strings.Trim(prefix, "/")
+}
+
+// plan compares the source and destination in the direction selected using sync up or sync down
+func (c *objSyncCmd) plan() (*objSyncPlan, error) {
+	plan := &objSyncPlan{upload: c.up}
+
+	if c.up {
+		stat, err := os.Stat(c.source)
+		if err != nil {
+			return nil, err
+		}
+		if !stat.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", c.source)
+		}
+
+		plan.dir = c.source
+		plan.bucket, plan.prefix = parseObjSyncTarget(c.destination)
+	} else {
+		plan.dir = c.destination
+		plan.bucket, plan.prefix = parseObjSyncTarget(c.source)
+	}
+
+	store, err := c.js.ObjectStore(plan.bucket)
+	if err != nil {
+		return nil, fmt.Errorf("could not load bucket %s: %v", plan.bucket, err)
+	}
+
+	local, err := c.localFiles(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := c.remoteObjects(store, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	src, dst := remote, local
+	if plan.upload {
+		src, dst = local, remote
+	}
+
+	for name, file := range src {
+		existing, ok := dst[name]
+		delete(dst, name)
+
+		if ok {
+			same, err := c.unchanged(store, plan, file, existing)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				plan.unchanged++
+				continue
+			}
+		}
+
+		plan.transfer = append(plan.transfer, file)
+	}
+
+	if c.delete {
+		for _, file := range dst {
+			plan.remove = append(plan.remove, file)
+		}
+	}
+
+	sort.Slice(plan.transfer, func(i, j int) bool { return plan.transfer[i].name < plan.transfer[j].name })
+	sort.Slice(plan.remove, func(i, j int) bool { return plan.remove[i].name < plan.remove[j].name })
+
+	return plan, nil
+}
+
+// unchanged compares size and modification time and falls back to comparing digests
+func (c *objSyncCmd) unchanged(store nats.ObjectStore, plan *objSyncPlan, a *objSyncFile, b *objSyncFile) (bool, error) {
+	if a.size != b.size {
+		return false, nil
+	}
+
+	if !a.mtime.IsZero() && a.mtime.Equal(b.mtime) {
+		return true, nil
+	}
+
+	localPath := a.path
+	if !plan.upload {
+		localPath = b.path
+	}
+
+	digest, err := fileDigest(localPath)
+	if err != nil {
+		return false, err
+	}
+
+	nfo, err := store.GetInfo(a.name)
+	if err != nil {
+		return false, err
+	}
+
+	return digest == nfo.Digest, nil
+}
+
+func (c *objSyncCmd) objectName(plan *objSyncPlan, rel string) string {
+	if plan.prefix == "" {
+		return rel
+	}
+
+	return plan.prefix + "/" + rel
+}
+
+func (c *objSyncCmd) localFiles(plan *objSyncPlan) (map[string]*objSyncFile, error) {
+	files := map[string]*objSyncFile{}
+
+	err := filepath.WalkDir(plan.dir, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			// a missing download destination is created later
+			if errors.Is(err, iofs.ErrNotExist) && p == plan.dir && !plan.upload {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(plan.dir, p)
+		if err != nil {
+			return err
+		}
+
+		name := c.objectName(plan, filepath.ToSlash(rel))
+		files[name] = &objSyncFile{name: name, path: p, size: stat.Size(), mtime: stat.ModTime().UTC().Truncate(time.Microsecond)}
+
+		return nil
+	})
+
+	return files, err
+}
+
+func (c *objSyncCmd) remoteObjects(store nats.ObjectStore, plan *objSyncPlan) (map[string]*objSyncFile, error) {
+	objects := map[string]*objSyncFile{}
+
+	list, err := store.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return objects, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nfo := range list {
+		if nfo.Deleted {
+			continue
+		}
+
+		rel := nfo.Name
+		if plan.prefix != "" {
+			if !strings.HasPrefix(rel, plan.prefix+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(rel, plan.prefix+"/")
+		}
+
+		// object names are not restricted, never write outside of the directory
+		rel = path.Clean("/" + rel)[1:]
+		if rel == "" {
+			continue
+		}
+
+		file := &objSyncFile{name: nfo.Name, path: filepath.Join(plan.dir, filepath.FromSlash(rel)), size: int64(nfo.Size)}
+		if mtime, ok := nfo.Metadata[objSyncMtimeMeta]; ok {
+			file.mtime, _ = time.Parse(time.RFC3339Nano, mtime)
+		}
+
+		objects[nfo.Name] = file
+	}
+
+	return objects, nil
+}
+
+func (c *objSyncCmd) apply(plan *objSyncPlan) error {
+	store, err := c.js.ObjectStore(plan.bucket)
+	if err != nil {
+		return err
+	}
+
+	var progress *uiprogress.Bar
+	if c.showProgress && len(plan.transfer) > 0 {
+		progress = uiprogress.AddBar(len(plan.transfer)).AppendCompleted().PrependFunc(func(b *uiprogress.Bar) string {
+			return fmt.Sprintf("%s / %s", f(b.Current()), f(b.Total))
+		})
+		progress.Width = progressWidth()
+		uiprogress.Start()
+	}
+
+	workers := c.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		errs []error
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	files := make(chan *objSyncFile, len(plan.transfer))
+	for _, file := range plan.transfer {
+		files <- file
+	}
+	close(files)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for file := range files {
+				var err error
+				if plan.upload {
+					err = c.upload(store, file)
+				} else {
+					err = c.download(store, file)
+				}
+
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("could not transfer %s: %v", file.name, err))
+					mu.Unlock()
+				}
+
+				if progress != nil {
+					progress.Incr()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if progress != nil {
+		uiprogress.Stop()
+		fmt.Println()
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, file := range plan.remove {
+		if plan.upload {
+			err = store.Delete(file.name)
+		} else {
+			err = os.Remove(file.path)
+		}
+		if err != nil {
+			return fmt.Errorf("could not remove %s: %v", file.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *objSyncCmd) upload(store nats.ObjectStore, file *objSyncFile) error {
+	r, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	meta := &nats.ObjectMeta{
+		Name:     file.name,
+		Metadata: map[string]string{objSyncMtimeMeta: file.mtime.Format(time.RFC3339Nano)},
+	}
+
+	_, err = store.Put(meta, r)
+
+	return err
+}
+
+// download writes the object to a temporary file that replaces the target once complete
+func (c *objSyncCmd) download(store nats.ObjectStore, file *objSyncFile) error {
+	err := os.MkdirAll(filepath.Dir(file.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	res, err := store.Get(file.name)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	tf, err := os.CreateTemp(filepath.Dir(file.path), ".nats-sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+
+	_, err = io.Copy(tf, res)
+	if err != nil {
+		tf.Close()
+		return err
+	}
+
+	err = tf.Close()
+	if err != nil {
+		return err
+	}
+
+	if !file.mtime.IsZero() {
+		err = os.Chtimes(tf.Name(), file.mtime, file.mtime)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tf.Name(), file.path)
+}
+
+func fileDigest(p string) (string, error) {
+	r, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+
+	return nats.GetObjectDigestValue(h), nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestObjectSync(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		store, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "ARTEFACTS"})
+		checkErr(t, err, "create failed: %v", err)
+
+		_, err = store.PutString("builds/stale.txt", "stale")
+		checkErr(t, err, "put failed: %v", err)
+		_, err = store.PutString("other/file.txt", "other")
+		checkErr(t, err, "put failed: %v", err)
+
+		src := t.TempDir()
+		write := func(dir string, name string, content string) {
+			p := filepath.Join(dir, name)
+			checkErr(t, os.MkdirAll(filepath.Dir(p), 0755), "mkdir failed")
+			checkErr(t, os.WriteFile(p, []byte(content), 0600), "write failed")
+		}
+		write(src, "a.txt", "a")
+		write(src, "nested/b.txt", "b")
+
+		c := &objSyncCmd{js: js, source: src, destination: "ARTEFACTS/builds", up: true, delete: true, workers: 2}
+		plan, err := c.plan()
+		checkErr(t, err, "plan failed: %v", err)
+		if !plan.upload || len(plan.transfer) != 2 || len(plan.remove) != 1 || plan.remove[0].name != "builds/stale.txt" {
+			t.Fatalf("invalid upload plan: %+v", plan)
+		}
+		checkErr(t, c.apply(plan), "apply failed")
+
+		nfo, err := store.GetInfo("builds/nested/b.txt")
+		checkErr(t, err, "info failed: %v", err)
+		if nfo.Metadata[objSyncMtimeMeta] == "" {
+			t.Fatalf("modification time was not recorded")
+		}
+		_, err = store.GetInfo("other/file.txt")
+		checkErr(t, err, "objects outside the prefix were removed: %v", err)
+
+		plan, err = c.plan()
+		checkErr(t, err, "plan failed: %v", err)
+		if len(plan.transfer) != 0 || len(plan.remove) != 0 || plan.unchanged != 2 {
+			t.Fatalf("expected no changes: %+v", plan)
+		}
+
+		// same content with a different modification time is detected using the digest
+		later := time.Now().Add(time.Hour)
+		checkErr(t, os.Chtimes(filepath.Join(src, "a.txt"), later, later), "chtimes failed")
+		write(src, "nested/b.txt", "B")
+		plan, err = c.plan()
+		checkErr(t, err, "plan failed: %v", err)
+		if len(plan.transfer) != 1 || plan.transfer[0].name != "builds/nested/b.txt" || plan.unchanged != 1 {
+			t.Fatalf("expected only b.txt to change: %+v", plan)
+		}
+		checkErr(t, c.apply(plan), "apply failed")
+
+		dst := filepath.Join(t.TempDir(), "download")
+		c = &objSyncCmd{js: js, source: "ARTEFACTS/builds", destination: dst, workers: 2}
+		plan, err = c.plan()
+		checkErr(t, err, "plan failed: %v", err)
+		if plan.upload || len(plan.transfer) != 2 {
+			t.Fatalf("invalid download plan: %+v", plan)
+		}
+		checkErr(t, c.apply(plan), "apply failed")
+
+		// the direction is never guessed from the arguments
+		_, err = (&objSyncCmd{js: js, source: "ARTEFACTS/builds", destination: dst, up: true}).plan()
+		if err == nil {
+			t.Fatalf("expected uploading from a bucket name to fail")
+		}
+
+		b, err := os.ReadFile(filepath.Join(dst, "nested", "b.txt"))
+		checkErr(t, err, "read failed: %v", err)
+		if string(b) != "B" {
+			t.Fatalf("invalid content: %s", b)
+		}
+
+		plan, err = c.plan()
+		checkErr(t, err, "plan failed: %v", err)
+		if len(plan.transfer) != 0 || plan.unchanged != 2 {
+			t.Fatalf("expected no changes: %+v", plan)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/choria-io/fisk"
+	"github.com/dustin/go-humanize"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	objVerifyOK        = "ok"
+	objVerifyCorrupt   = "corrupt"
+	objVerifyTruncated = "truncated"
+	objVerifyFailed    = "failed"
+)
+
+type objVerifyCmd struct {
+	bucket string
+	name   string
+	json   bool
+
+	js nats.JetStreamContext
+}
+
+type objVerifyResult struct {
+	Name   string `json:"name"`
+	Size   uint64 `json:"size"`
+	Chunks uint32 `json:"chunks"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func configureObjectVerifyCommand(obj *fisk.CmdClause) {
+	c := &objVerifyCmd{}
+
+	help := `Verifies the integrity of objects
+
+The chunks of every object are read and the SHA-256 digest and size are
+compared to the values recorded when the object was stored. Objects that
+have fewer chunks stored than recorded are reported as truncated, objects
+with a different digest as corrupt.
+
+When no object is given all objects in the bucket are verified, links are
+not verified. A link given by name is verified by verifying the object it
+points to.
+`
+
+	verify := obj.Command("verify", "Verifies the integrity of objects").Action(c.verifyAction)
+	verify.HelpLong(help)
+	verify.Arg("bucket", "The bucket to act on").Required().StringVar(&c.bucket)
+	verify.Arg("file", "The object to verify").StringVar(&c.name)
+	verify.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+}
+
+func (c *objVerifyCmd) verifyAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	results, err := c.verify()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Status != objVerifyOK {
+			failed++
+		}
+	}
+
+	if c.json {
+		err = printJSON(results)
+		if err != nil {
+			return err
+		}
+	} else {
+		table := newTableWriter(fmt.Sprintf("Verification of Object Store Bucket %s", c.bucket))
+		table.AddHeaders("Name", "Size", "Chunks", "Status", "Detail")
+		for _, res := range results {
+			table.AddRow(res.Name, humanize.IBytes(res.Size), f(res.Chunks), res.Status, res.Detail)
+		}
+		fmt.Println(table.Render())
+		fmt.Printf("Verified %s objects, %s failed verification\n", f(len(results)), f(failed))
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func (c *objVerifyCmd) verify() ([]*objVerifyResult, error) {
+	store, err := c.js.ObjectStore(c.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*nats.ObjectInfo
+	if c.name != "" {
+		nfo, err := store.GetInfo(c.name)
+		if err != nil {
+			return nil, err
+		}
+
+		if nfo.Opts != nil && nfo.Opts.Link != nil {
+			res, err := c.verifyLink(nfo)
+			if err != nil {
+				return nil, err
+			}
+			return []*objVerifyResult{res}, nil
+		}
+
+		objects = append(objects, nfo)
+	} else {
+		objects, err = store.List()
+		if errors.Is(err, nats.ErrNoObjectsFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var results []*objVerifyResult
+	for _, nfo := range objects {
+		if nfo.Deleted || (nfo.Opts != nil && nfo.Opts.Link != nil) {
+			continue
+		}
+
+		results = append(results, c.verifyObject(store, c.bucket, nfo))
+	}
+
+	return results, nil
+}
+
+// verifyLink verifies the object a named link points to, links to entire buckets can not be verified
+func (c *objVerifyCmd) verifyLink(link *nats.ObjectInfo) (*objVerifyResult, error) {
+	target := link.Opts.Link
+	if target.Name == "" {
+		return nil, fmt.Errorf("%s is a link to bucket %s, verify that bucket instead", link.Name, target.Bucket)
+	}
+
+	store, err := c.js.ObjectStore(target.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	nfo, err := store.GetInfo(target.Name)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s > %s linked from %s: %w", target.Bucket, target.Name, link.Name, err)
+	}
+
+	res := c.verifyObject(store, target.Bucket, nfo)
+	res.Name = link.Name
+	if res.Detail == "" {
+		res.Detail = fmt.Sprintf("link to %s > %s", target.Bucket, target.Name)
+	} else {
+		res.Detail = fmt.Sprintf("link to %s > %s: %s", target.Bucket, target.Name, res.Detail)
+	}
+
+	return res, nil
+}
+
+func (c *objVerifyCmd) verifyObject(store nats.ObjectStore, bucket string, nfo *nats.ObjectInfo) *objVerifyResult {
+	res := &objVerifyResult{Name: nfo.Name, Size: nfo.Size, Chunks: nfo.Chunks, Status: objVerifyOK}
+
+	// reading an object with missing chunks ends early, count the stored chunks to report those clearly
+	subj := fmt.Sprintf("$O.%s.C.%s", bucket, nfo.NUID)
+	si, err := c.js.StreamInfo(fmt.Sprintf("OBJ_%s", bucket), &nats.StreamInfoRequest{SubjectsFilter: subj})
+	if err != nil {
+		res.Status, res.Detail = objVerifyFailed, err.Error()
+		return res
+	}
+
+	stored := si.State.Subjects[subj]
+	if stored < uint64(nfo.Chunks) {
+		res.Status, res.Detail = objVerifyTruncated, fmt.Sprintf("%d of %d chunks stored", stored, nfo.Chunks)
+		return res
+	}
+
+	obj, err := store.Get(nfo.Name)
+	if err != nil {
+		res.Status, res.Detail = objVerifyFailed, err.Error()
+		return res
+	}
+	defer obj.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, obj)
+	switch {
+	case errors.Is(err, nats.ErrDigestMismatch):
+		res.Status, res.Detail = objVerifyCorrupt, "digest does not match"
+	case err != nil:
+		res.Status, res.Detail = objVerifyFailed, err.Error()
+	case uint64(n) != nfo.Size:
+		res.Status, res.Detail = objVerifyTruncated, fmt.Sprintf("read %s of %s", humanize.IBytes(uint64(n)), humanize.IBytes(nfo.Size))
+	case nats.GetObjectDigestValue(h) != nfo.Digest:
+		res.Status, res.Detail = objVerifyCorrupt, "digest does not match"
+	}
+
+	return res
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestObjectVerify(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		store, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "ARTEFACTS"})
+		checkErr(t, err, "create failed: %v", err)
+
+		put := func(name string) *nats.ObjectInfo {
+			nfo, err := store.Put(&nats.ObjectMeta{Name: name, Opts: &nats.ObjectMetaOptions{ChunkSize: 4}}, bytes.NewReader([]byte("0123456789ab")))
+			checkErr(t, err, "put failed: %v", err)
+			return nfo
+		}
+
+		put("good")
+		corrupt := put("corrupt")
+		truncated := put("truncated")
+		_, err = store.AddLink("link", corrupt)
+		checkErr(t, err, "link failed: %v", err)
+
+		chunk := func(nfo *nats.ObjectInfo) (string, uint64) {
+			subj := fmt.Sprintf("$O.ARTEFACTS.C.%s", nfo.NUID)
+			msg, err := js.GetLastMsg("OBJ_ARTEFACTS", subj)
+			checkErr(t, err, "get failed: %v", err)
+			return subj, msg.Sequence
+		}
+
+		// replace the last chunk of one object with different data and remove it from another
+		subj, seq := chunk(corrupt)
+		checkErr(t, js.DeleteMsg("OBJ_ARTEFACTS", seq), "delete failed")
+		_, err = js.Publish(subj, []byte("xxxx"))
+		checkErr(t, err, "publish failed: %v", err)
+
+		_, seq = chunk(truncated)
+		checkErr(t, js.DeleteMsg("OBJ_ARTEFACTS", seq), "delete failed")
+
+		c := &objVerifyCmd{js: js, bucket: "ARTEFACTS"}
+		results, err := c.verify()
+		checkErr(t, err, "verify failed: %v", err)
+
+		status := map[string]string{}
+		for _, res := range results {
+			status[res.Name] = res.Status
+		}
+
+		expected := map[string]string{"good": objVerifyOK, "corrupt": objVerifyCorrupt, "truncated": objVerifyTruncated}
+		if len(status) != len(expected) {
+			t.Fatalf("expected %v got %v", expected, status)
+		}
+		for k, v := range expected {
+			if status[k] != v {
+				t.Fatalf("expected %v got %v", expected, status)
+			}
+		}
+
+		c.name = "good"
+		results, err = c.verify()
+		checkErr(t, err, "verify failed: %v", err)
+		if len(results) != 1 || results[0].Status != objVerifyOK {
+			t.Fatalf("invalid result: %+v", results)
+		}
+
+		c.name = "link"
+		results, err = c.verify()
+		checkErr(t, err, "verify failed: %v", err)
+		if len(results) != 1 || results[0].Name != "link" || results[0].Status != objVerifyCorrupt {
+			t.Fatalf("invalid result: %+v", results)
+		}
+	})
+}
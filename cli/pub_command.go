@@ -39,6 +39,8 @@ type pubCmd struct {
 	replyTimeout time.Duration
 	forceStdin   bool
 	translate    string
+	playback     string
+	speed        float64
 }
 
 func configurePubCommand(app commandHost) {
@@ -62,18 +64,26 @@ Available template functions are:
    Time             the current time
    ID               an unique ID
    Random(min, max) random string at least min long, at most max
+
+Messages recorded using nats sub --record can be played back using
+--playback, preserving the time between messages. The --speed flag
+accelerates or slows down the playback.
+
+   nats pub --playback orders.natscap --speed 10
 `
 
 	pub := app.Command("publish", "Generic data publish utility").Alias("pub").Action(c.publish)
 	addCheat("pub", pub)
 	pub.HelpLong(pubHelp)
-	pub.Arg("subject", "Subject to publish to").Required().StringVar(&c.subject)
+	pub.Arg("subject", "Subject to publish to, overrides recorded subjects when playing back captures").StringVar(&c.subject)
 	pub.Arg("body", "Message body").Default("!nil!").StringVar(&c.body)
 	pub.Flag("reply", "Sets a custom reply to subject").StringVar(&c.replyTo)
 	pub.Flag("header", "Adds headers to the message").Short('H').StringsVar(&c.hdrs)
 	pub.Flag("count", "Publish multiple messages").Default("1").IntVar(&c.cnt)
 	pub.Flag("sleep", "When publishing multiple messages, sleep between publishes").DurationVar(&c.sleep)
 	pub.Flag("force-stdin", "Force reading from stdin").UnNegatableBoolVar(&c.forceStdin)
+	pub.Flag("playback", "Plays back messages recorded using nats sub --record").PlaceHolder("FILE").StringVar(&c.playback)
+	pub.Flag("speed", "Playback speed relative to the recording, 0 publishes as fast as possible").Default("1").Float64Var(&c.speed)
 
 	requestHelp := `Body and Header values of the messages may use Go templates to 
 create unique messages.
@@ -236,6 +246,14 @@ func (c *pubCmd) publish(_ *fisk.ParseContext) error {
 	}
 	defer nc.Close()
 
+	if c.playback != "" {
+		return c.playbackAction(nc)
+	}
+
+	if c.subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+
 	if c.cnt < 1 {
 		c.cnt = math.MaxInt16
 	}
@@ -302,3 +320,67 @@ func (c *pubCmd) publish(_ *fisk.ParseContext) error {
 
 	return nil
 }
+
+func (c *pubCmd) playbackAction(nc *nats.Conn) error {
+	fh, err := os.Open(c.playback)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	cnt, err := c.playbackMessages(nc, fh)
+	if err != nil {
+		return fmt.Errorf("playback failed after %s messages: %v", f(cnt), err)
+	}
+
+	log.Printf("Played back %s messages from %s", f(cnt), c.playback)
+
+	return nil
+}
+
+// playbackMessages publishes recorded messages keeping the time between them, scaled by speed
+func (c *pubCmd) playbackMessages(nc *nats.Conn, r io.Reader) (int, error) {
+	if c.speed < 0 {
+		return 0, fmt.Errorf("speed can not be negative")
+	}
+
+	var (
+		cnt       int
+		firstTime time.Time
+		started   time.Time
+	)
+
+	err := readCapture(r, func(rec *captureRecord) error {
+		if c.speed > 0 {
+			if firstTime.IsZero() {
+				firstTime = rec.Time
+				started = time.Now()
+			}
+
+			time.Sleep(time.Until(started.Add(time.Duration(float64(rec.Time.Sub(firstTime)) / c.speed))))
+		}
+
+		msg := nats.NewMsg(rec.Subject)
+		if c.subject != "" {
+			msg.Subject = c.subject
+		}
+		msg.Reply = rec.Reply
+		msg.Data = rec.Data
+		if len(rec.Headers) > 0 {
+			msg.Header = rec.Headers
+		}
+
+		err := nc.PublishMsg(msg)
+		if err != nil {
+			return err
+		}
+		cnt++
+
+		return nil
+	})
+	if err != nil {
+		return cnt, err
+	}
+
+	return cnt, nc.Flush()
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestPubPlayback(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, _ *jsm.Manager) {
+		capture := &bytes.Buffer{}
+		start := time.Now()
+
+		for i := 0; i < 3; i++ {
+			msg := nats.NewMsg(fmt.Sprintf("orders.%d", i))
+			msg.Reply = "reply"
+			msg.Header.Add("Order", fmt.Sprintf("%d", i))
+			msg.Data = []byte(fmt.Sprintf("order %d", i))
+			err := writeCaptureRecord(capture, msg, start.Add(time.Duration(i)*100*time.Millisecond))
+			checkErr(t, err, "record failed: %v", err)
+		}
+
+		sub, err := nc.SubscribeSync("orders.>")
+		checkErr(t, err, "subscribe failed: %v", err)
+
+		c := &pubCmd{speed: 2}
+		started := time.Now()
+		cnt, err := c.playbackMessages(nc, bytes.NewReader(capture.Bytes()))
+		checkErr(t, err, "playback failed: %v", err)
+		if cnt != 3 {
+			t.Fatalf("expected 3 messages got %d", cnt)
+		}
+		if took := time.Since(started); took < 100*time.Millisecond || took > 500*time.Millisecond {
+			t.Fatalf("playback did not honor timing and speed, took %v", took)
+		}
+
+		for i := 0; i < 3; i++ {
+			msg, err := sub.NextMsg(time.Second)
+			checkErr(t, err, "next failed: %v", err)
+			if msg.Subject != fmt.Sprintf("orders.%d", i) || msg.Reply != "reply" || msg.Header.Get("Order") != fmt.Sprintf("%d", i) || string(msg.Data) != fmt.Sprintf("order %d", i) {
+				t.Fatalf("invalid message played back: %+v", msg)
+			}
+		}
+	})
+}
@@ -67,6 +67,8 @@ func withJetStream(t *testing.T, cb func(srv *server.Server, nc *nats.Conn, mgr
 	}()
 
 	opts.Conn = nil
+	opts.Mgr = nil
+	opts.JSc = nil
 	nc, mgr, err := prepareHelper(srv.ClientURL())
 	checkErr(t, err, "could not connect client to server @ %s: %v", srv.ClientURL(), err)
 	defer nc.Close()
@@ -0,0 +1,686 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/dustin/go-humanize"
+	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats-server/v2/server/avl"
+	"github.com/nats-io/nats.go"
+)
+
+// file store layout and record format as written into snapshots by the server
+const (
+	backupMetaFile      = "meta.inf"
+	backupStateFile     = "msgs/index.db"
+	backupConsumerState = "o.dat"
+	backupErrorFile     = "errors.txt"
+
+	backupStateMagic    = 11
+	backupStateVersion  = 1
+	backupConsumerMagic = 22
+
+	backupRecordHeaderSize = 22
+	backupRecordHashSize   = 8
+	backupHeadersBit       = 1 << 31
+	backupErasedBit        = 1 << 63
+	backupTombstoneBit     = 1 << 62
+)
+
+type streamBackupCmd struct {
+	directory string
+	subjects  []string
+	seqRange  string
+	format    string
+	translate string
+	json      bool
+}
+
+// streamMsgRecord is the JSON representation of a stored message
+type streamMsgRecord struct {
+	Stream   string      `json:"stream,omitempty"`
+	Subject  string      `json:"subject"`
+	Sequence uint64      `json:"seq"`
+	Time     time.Time   `json:"time"`
+	Headers  nats.Header `json:"headers,omitempty"`
+	Data     []byte      `json:"data"`
+}
+
+type streamBackupConsumerState struct {
+	DeliveredStream   uint64 `json:"delivered_stream_seq"`
+	DeliveredConsumer uint64 `json:"delivered_consumer_seq"`
+	AckFloorStream    uint64 `json:"ack_floor_stream_seq"`
+	AckFloorConsumer  uint64 `json:"ack_floor_consumer_seq"`
+	NumAckPending     int    `json:"num_ack_pending"`
+	NumRedelivered    int    `json:"num_redelivered"`
+}
+
+type streamBackupConsumer struct {
+	Name    string                     `json:"name"`
+	Created time.Time                  `json:"created"`
+	Config  api.ConsumerConfig         `json:"config"`
+	State   *streamBackupConsumerState `json:"state,omitempty"`
+}
+
+type streamBackupContents struct {
+	Blocks     int               `json:"blocks"`
+	BlockBytes uint64            `json:"block_bytes"`
+	Messages   uint64            `json:"messages"`
+	Bytes      uint64            `json:"bytes"`
+	FirstSeq   uint64            `json:"first_seq"`
+	LastSeq    uint64            `json:"last_seq"`
+	Subjects   map[string]uint64 `json:"subjects,omitempty"`
+	Erased     uint64            `json:"erased,omitempty"`
+	Deleted    uint64            `json:"deleted,omitempty"`
+	Incomplete bool              `json:"incomplete,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"`
+}
+
+type streamBackup struct {
+	Created   time.Time               `json:"created"`
+	Config    api.StreamConfig        `json:"config"`
+	State     api.StreamState         `json:"state"`
+	Consumers []*streamBackupConsumer `json:"consumers"`
+	Contents  streamBackupContents    `json:"contents"`
+
+	directory string
+}
+
+type streamBackupBlockState struct {
+	first   uint64
+	deleted *avl.SequenceSet
+}
+
+func configureStreamBackupCommand(backup *fisk.CmdClause) {
+	c := &streamBackupCmd{}
+
+	inspect := backup.Command("inspect", "Shows the configuration, state and consumers held in a backup without a server").Action(c.inspectAction)
+	inspect.Arg("directory", "The directory holding the backup").Required().ExistingDirVar(&c.directory)
+	inspect.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+
+	extract := backup.Command("extract", "Extracts messages from a backup without a server").Action(c.extractAction)
+	extract.Arg("directory", "The directory holding the backup").Required().ExistingDirVar(&c.directory)
+	extract.Flag("subject", "Only extract messages matching a subject (pass multiple times)").StringsVar(&c.subjects)
+	extract.Flag("seq-range", "Only extract messages in a sequence range like 10-20, 10- or -20").PlaceHolder("RANGE").StringVar(&c.seqRange)
+	extract.Flag("format", "The format to extract messages in (text, jsonl)").Default("text").EnumVar(&c.format, "text", "jsonl")
+	extract.Flag("translate", "Translate the message data by running it through the given command before output").StringVar(&c.translate)
+}
+
+func (c *streamBackupCmd) inspectAction(_ *fisk.ParseContext) error {
+	backup, err := openStreamBackup(c.directory)
+	if err != nil {
+		return err
+	}
+
+	subjects := map[string]uint64{}
+	err = backup.read(func(msg *streamMsgRecord) error {
+		subjects[msg.Subject]++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	backup.Contents.Subjects = subjects
+
+	if c.json {
+		return printJSON(backup)
+	}
+
+	cols := newColumns(fmt.Sprintf("Backup of Stream %s created %s", backup.Config.Name, f(backup.Created.Local())))
+
+	cols.AddSectionTitle("Configuration")
+	cols.AddRow("Name", backup.Config.Name)
+	(&streamCmd{}).showStreamConfig(cols, backup.Config)
+
+	cols.AddSectionTitle("State")
+	cols.AddRow("Messages", backup.State.Msgs)
+	cols.AddRow("Bytes", humanize.IBytes(backup.State.Bytes))
+	if backup.State.FirstTime.IsZero() {
+		cols.AddRow("First Sequence", backup.State.FirstSeq)
+	} else {
+		cols.AddRowf("First Sequence", "%s @ %s UTC", f(backup.State.FirstSeq), f(backup.State.FirstTime))
+	}
+	if backup.State.LastTime.IsZero() {
+		cols.AddRow("Last Sequence", backup.State.LastSeq)
+	} else {
+		cols.AddRowf("Last Sequence", "%s @ %s UTC", f(backup.State.LastSeq), f(backup.State.LastTime))
+	}
+	cols.AddRowIf("Deleted Messages", backup.State.NumDeleted, backup.State.NumDeleted > 0)
+	cols.AddRowIf("Number of Subjects", backup.State.NumSubjects, backup.State.NumSubjects > 0)
+
+	cols.AddSectionTitle("Contents")
+	cols.AddRowf("Message Blocks", "%s (%s)", f(backup.Contents.Blocks), humanize.IBytes(backup.Contents.BlockBytes))
+	cols.AddRowf("Messages", "%s (%s)", f(backup.Contents.Messages), humanize.IBytes(backup.Contents.Bytes))
+	if backup.Contents.Messages > 0 {
+		cols.AddRowf("Sequences", "%s - %s", f(backup.Contents.FirstSeq), f(backup.Contents.LastSeq))
+	}
+	cols.AddRow("Subjects", len(subjects))
+	cols.AddRowIf("Deleted Messages", backup.Contents.Deleted, backup.Contents.Deleted > 0)
+	cols.AddRowIf("Erased Messages", backup.Contents.Erased, backup.Contents.Erased > 0)
+	cols.AddRowIf("Incomplete", true, backup.Contents.Incomplete)
+	for _, w := range backup.Contents.Warnings {
+		cols.AddRow("Warning", w)
+	}
+
+	cols.Frender(os.Stdout)
+
+	if len(backup.Consumers) == 0 {
+		fmt.Println("No consumers were included in the backup")
+		return nil
+	}
+
+	table := newTableWriter("Consumers")
+	table.AddHeaders("Name", "Durable", "Ack Policy", "Filter", "Delivered", "Ack Floor", "Ack Pending", "Redelivered")
+	for _, consumer := range backup.Consumers {
+		filter := consumer.Config.FilterSubject
+		if len(consumer.Config.FilterSubjects) > 0 {
+			filter = strings.Join(consumer.Config.FilterSubjects, ", ")
+		}
+
+		if consumer.State == nil {
+			table.AddRow(consumer.Name, consumer.Config.Durable != "", consumer.Config.AckPolicy.String(), filter, "", "", "", "")
+			continue
+		}
+
+		table.AddRow(consumer.Name, consumer.Config.Durable != "", consumer.Config.AckPolicy.String(), filter,
+			f(consumer.State.DeliveredStream), f(consumer.State.AckFloorStream), f(consumer.State.NumAckPending), f(consumer.State.NumRedelivered))
+	}
+	fmt.Println(table.Render())
+
+	return nil
+}
+
+func (c *streamBackupCmd) extractAction(_ *fisk.ParseContext) error {
+	start, end, err := parseSeqRange(c.seqRange)
+	if err != nil {
+		return err
+	}
+
+	backup, err := openStreamBackup(c.directory)
+	if err != nil {
+		return err
+	}
+
+	subjects := splitCLISubjects(c.subjects)
+	enc := json.NewEncoder(os.Stdout)
+
+	err = backup.read(func(msg *streamMsgRecord) error {
+		if msg.Sequence < start || (end > 0 && msg.Sequence > end) {
+			return nil
+		}
+
+		if len(subjects) > 0 {
+			matched := false
+			for _, s := range subjects {
+				if server.SubjectsCollide(s, msg.Subject) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil
+			}
+		}
+
+		if c.format == "jsonl" {
+			return enc.Encode(msg)
+		}
+
+		fmt.Printf("[%d] Subject: %s Received: %s\n", msg.Sequence, msg.Subject, msg.Time.Format(time.RFC3339))
+		if len(msg.Headers) > 0 {
+			fmt.Println()
+			for k, vs := range msg.Headers {
+				for _, v := range vs {
+					fmt.Printf("  %s: %s\n", k, v)
+				}
+			}
+		}
+		fmt.Println()
+		outPutMSGBody(msg.Data, c.translate, msg.Subject, backup.Config.Name)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range backup.Contents.Warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+	}
+
+	return nil
+}
+
+// parseSeqRange parses ranges like 10-20, 10- or -20, end is 0 for open ended ranges
+func parseSeqRange(r string) (start uint64, end uint64, err error) {
+	r = strings.TrimSpace(r)
+	if r == "" {
+		return 0, 0, nil
+	}
+
+	s, e, found := strings.Cut(r, "-")
+	if s != "" {
+		start, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid sequence range %q: %v", r, err)
+		}
+	}
+
+	switch {
+	case !found:
+		end = start
+	case e != "":
+		end, err = strconv.ParseUint(e, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid sequence range %q: %v", r, err)
+		}
+	}
+
+	if end > 0 && start > end {
+		return 0, 0, fmt.Errorf("invalid sequence range %q: start is after end", r)
+	}
+
+	return start, end, nil
+}
+
+// openStreamBackup loads the metadata of a backup made using backupStream
+func openStreamBackup(dir string) (*streamBackup, error) {
+	backup := &streamBackup{directory: dir}
+
+	var req api.JSApiStreamRestoreRequest
+	rj, err := os.ReadFile(filepath.Join(dir, "backup.json"))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(rj, &req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup metadata: %v", err)
+	}
+	if req.Config.Name == "" {
+		return nil, fmt.Errorf("invalid backup metadata: stream configuration is required")
+	}
+	backup.Config = req.Config
+	backup.State = req.State
+
+	return backup, nil
+}
+
+// read reads the backup data, cb is called for every message held in the backup in sequence order
+func (b *streamBackup) read(cb func(msg *streamMsgRecord) error) error {
+	df, err := os.Open(filepath.Join(b.directory, "stream.tar.s2"))
+	if err != nil {
+		return err
+	}
+	defer df.Close()
+
+	consumers := map[string]*streamBackupConsumer{}
+	consumer := func(name string) *streamBackupConsumer {
+		if _, ok := consumers[name]; !ok {
+			consumers[name] = &streamBackupConsumer{Name: name}
+		}
+		return consumers[name]
+	}
+
+	var blocks map[uint32]*streamBackupBlockState
+	var hasState bool
+	tr := tar.NewReader(s2.NewReader(df))
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read backup data: %v", err)
+		}
+
+		name := filepath.ToSlash(hdr.Name)
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("could not read %s from backup data: %v", name, err)
+		}
+
+		parent, file := path.Split(name)
+
+		switch {
+		case name == backupErrorFile:
+			b.Contents.Incomplete = true
+			b.Contents.Warnings = append(b.Contents.Warnings, fmt.Sprintf("server reported an error while creating the backup: %s", strings.TrimSpace(string(data))))
+
+		case name == backupMetaFile:
+			var meta struct {
+				Created time.Time
+			}
+			err = json.Unmarshal(data, &meta)
+			if err != nil {
+				return fmt.Errorf("invalid stream metadata: %v", err)
+			}
+			b.Created = meta.Created
+
+		case name == backupStateFile:
+			hasState = true
+			blocks, err = decodeBackupStreamState(data)
+			if err != nil {
+				b.Contents.Warnings = append(b.Contents.Warnings, fmt.Sprintf("could not read stream state, deleted messages might be included: %v", err))
+			}
+
+		case parent == "msgs/" && strings.HasSuffix(file, ".blk"):
+			idx, err := strconv.ParseUint(strings.TrimSuffix(file, ".blk"), 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid message block %s", name)
+			}
+
+			b.Contents.Blocks++
+			b.Contents.BlockBytes += uint64(len(data))
+
+			err = b.readBlock(data, blocks[uint32(idx)], cb)
+			if err != nil {
+				return fmt.Errorf("could not read message block %s: %v", name, err)
+			}
+
+		case strings.HasPrefix(parent, "obs/") && file == backupMetaFile:
+			o := consumer(strings.TrimSuffix(strings.TrimPrefix(parent, "obs/"), "/"))
+			var meta struct {
+				Created time.Time
+				api.ConsumerConfig
+			}
+			err = json.Unmarshal(data, &meta)
+			if err != nil {
+				return fmt.Errorf("invalid consumer metadata for %s: %v", o.Name, err)
+			}
+			o.Created = meta.Created
+			o.Config = meta.ConsumerConfig
+
+		case strings.HasPrefix(parent, "obs/") && file == backupConsumerState:
+			o := consumer(strings.TrimSuffix(strings.TrimPrefix(parent, "obs/"), "/"))
+			o.State, err = decodeBackupConsumerState(data)
+			if err != nil {
+				b.Contents.Warnings = append(b.Contents.Warnings, fmt.Sprintf("could not read state for consumer %s: %v", o.Name, err))
+			}
+		}
+	}
+
+	if !hasState && b.Contents.Blocks > 0 && b.State.NumDeleted > 0 {
+		b.Contents.Warnings = append(b.Contents.Warnings, "no stream state found, deleted messages might be included")
+	}
+
+	b.Consumers = []*streamBackupConsumer{}
+	for _, o := range consumers {
+		b.Consumers = append(b.Consumers, o)
+	}
+	sort.Slice(b.Consumers, func(i, j int) bool {
+		return b.Consumers[i].Name < b.Consumers[j].Name
+	})
+
+	return nil
+}
+
+func (b *streamBackup) readBlock(buf []byte, state *streamBackupBlockState, cb func(msg *streamMsgRecord) error) error {
+	le := binary.LittleEndian
+
+	for index := 0; index < len(buf); {
+		if len(buf)-index < backupRecordHeaderSize+backupRecordHashSize {
+			return fmt.Errorf("short record at offset %d", index)
+		}
+
+		hdr := buf[index : index+backupRecordHeaderSize]
+		rl := le.Uint32(hdr[0:])
+		hasHeaders := rl&backupHeadersBit != 0
+		rl &^= backupHeadersBit
+		dlen := int(rl) - backupRecordHeaderSize
+		slen := int(le.Uint16(hdr[20:]))
+		if dlen < backupRecordHashSize || slen > dlen-backupRecordHashSize || index+int(rl) > len(buf) {
+			return fmt.Errorf("corrupt record at offset %d", index)
+		}
+
+		data := buf[index+backupRecordHeaderSize : index+int(rl)-backupRecordHashSize]
+		seq := le.Uint64(hdr[4:])
+		ts := int64(le.Uint64(hdr[12:]))
+		index += int(rl)
+
+		switch {
+		case seq&backupTombstoneBit != 0:
+			continue
+		case seq&backupErasedBit != 0:
+			b.Contents.Erased++
+			continue
+		case seq == 0 || seq < b.State.FirstSeq || seq > b.State.LastSeq:
+			continue
+		case state != nil && (seq < state.first || (state.deleted != nil && state.deleted.Exists(seq))):
+			b.Contents.Deleted++
+			continue
+		}
+
+		msg := &streamMsgRecord{
+			Stream:   b.Config.Name,
+			Subject:  string(data[:slen]),
+			Sequence: seq,
+			Time:     time.Unix(0, ts).UTC(),
+		}
+
+		body := data[slen:]
+		if hasHeaders {
+			if len(body) < 4 || int(le.Uint32(body)) > len(body)-4 {
+				return fmt.Errorf("corrupt headers in message %d", seq)
+			}
+			hl := int(le.Uint32(body))
+			if hl > 0 {
+				msg.Headers, _ = decodeHeadersMsg(body[4 : 4+hl])
+			}
+			body = body[4+hl:]
+		}
+		msg.Data = body
+
+		b.Contents.Messages++
+		b.Contents.Bytes += uint64(rl)
+		if b.Contents.FirstSeq == 0 {
+			b.Contents.FirstSeq = seq
+		}
+		b.Contents.LastSeq = seq
+
+		if cb != nil {
+			err := cb(msg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// decodeBackupStreamState decodes the per block first sequences and deleted messages from a stream state file
+func decodeBackupStreamState(buf []byte) (map[uint32]*streamBackupBlockState, error) {
+	if len(buf) < 2+backupRecordHashSize {
+		return nil, fmt.Errorf("state file is too short")
+	}
+	if buf[0] != backupStateMagic || buf[1] != backupStateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d", buf[1])
+	}
+
+	buf = buf[2 : len(buf)-backupRecordHashSize]
+	bi := 0
+	readU := func() uint64 {
+		if bi < 0 {
+			return 0
+		}
+		v, n := binary.Uvarint(buf[bi:])
+		if n <= 0 {
+			bi = -1
+			return 0
+		}
+		bi += n
+		return v
+	}
+	readI := func() {
+		if bi < 0 {
+			return
+		}
+		_, n := binary.Varint(buf[bi:])
+		if n <= 0 {
+			bi = -1
+			return
+		}
+		bi += n
+	}
+
+	// messages, bytes, first sequence and time, last sequence and time
+	readU()
+	readU()
+	readU()
+	readI()
+	readU()
+	readI()
+
+	numSubjects := readU()
+	for i := uint64(0); i < numSubjects && bi >= 0; i++ {
+		l := int(readU())
+		if bi < 0 || bi+l > len(buf) {
+			bi = -1
+			break
+		}
+		bi += l
+		if total := readU(); total > 1 {
+			readU()
+			readU()
+		} else {
+			readU()
+		}
+	}
+
+	numBlocks := readU()
+	blocks := map[uint32]*streamBackupBlockState{}
+	for i := uint64(0); i < numBlocks && bi >= 0; i++ {
+		idx := readU()
+		readU()
+		state := &streamBackupBlockState{first: readU()}
+		readI()
+		readU()
+		readI()
+
+		if numDeleted := readU(); numDeleted > 0 && bi >= 0 {
+			dmap, n, err := avl.Decode(buf[bi:])
+			if err != nil {
+				return nil, fmt.Errorf("corrupt deleted messages in block %d: %v", idx, err)
+			}
+			bi += n
+			state.deleted = dmap
+		}
+
+		blocks[uint32(idx)] = state
+	}
+
+	if bi < 0 {
+		return nil, fmt.Errorf("corrupt state file")
+	}
+
+	return blocks, nil
+}
+
+// decodeBackupConsumerState decodes the delivery and ack state of a consumer
+func decodeBackupConsumerState(buf []byte) (*streamBackupConsumerState, error) {
+	if len(buf) < 2 || buf[0] != backupConsumerMagic {
+		return nil, fmt.Errorf("corrupt consumer state")
+	}
+
+	version := buf[1]
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("unsupported consumer state version %d", version)
+	}
+
+	bi := 2
+	readU := func() uint64 {
+		if bi < 0 {
+			return 0
+		}
+		v, n := binary.Uvarint(buf[bi:])
+		if n <= 0 {
+			bi = -1
+			return 0
+		}
+		bi += n
+		return v
+	}
+	readI := func() {
+		if bi < 0 {
+			return
+		}
+		_, n := binary.Varint(buf[bi:])
+		if n <= 0 {
+			bi = -1
+			return
+		}
+		bi += n
+	}
+
+	state := &streamBackupConsumerState{
+		AckFloorConsumer:  readU(),
+		AckFloorStream:    readU(),
+		DeliveredConsumer: readU(),
+		DeliveredStream:   readU(),
+	}
+
+	// version 1 stored the next sequence to deliver
+	if version == 1 {
+		if state.AckFloorConsumer > 1 {
+			state.DeliveredConsumer += state.AckFloorConsumer - 1
+		}
+		if state.AckFloorStream > 1 {
+			state.DeliveredStream += state.AckFloorStream - 1
+		}
+	}
+
+	if numPending := readU(); numPending > 0 {
+		readI()
+		for i := uint64(0); i < numPending && bi >= 0; i++ {
+			readU()
+			if version == 2 {
+				readU()
+			}
+			readI()
+		}
+		state.NumAckPending = int(numPending)
+	}
+
+	if numRedelivered := readU(); numRedelivered > 0 {
+		for i := uint64(0); i < numRedelivered && bi >= 0; i++ {
+			readU()
+			readU()
+		}
+		state.NumRedelivered = int(numRedelivered)
+	}
+
+	if bi < 0 {
+		return nil, fmt.Errorf("corrupt consumer state")
+	}
+
+	return state, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestParseSeqRange(t *testing.T) {
+	for _, tc := range []struct {
+		r     string
+		start uint64
+		end   uint64
+		err   bool
+	}{
+		{"", 0, 0, false},
+		{"10-20", 10, 20, false},
+		{"10-", 10, 0, false},
+		{"-20", 0, 20, false},
+		{"15", 15, 15, false},
+		{"20-10", 0, 0, true},
+		{"x-10", 0, 0, true},
+	} {
+		start, end, err := parseSeqRange(tc.r)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("expected an error for %q", tc.r)
+			}
+			continue
+		}
+		assertNoError(t, err)
+		if start != tc.start || end != tc.end {
+			t.Fatalf("expected %d-%d for %q got %d-%d", tc.start, tc.end, tc.r, start, end)
+		}
+	}
+}
+
+func TestStreamBackupRead(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		stream, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.FileStorage())
+		checkErr(t, err, "create failed: %v", err)
+		_, err = stream.NewConsumer(jsm.DurableName("PROCESSOR"), jsm.FilterStreamBySubject("orders.new"))
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 10; i++ {
+			msg := nats.NewMsg(fmt.Sprintf("orders.%d", i%2))
+			msg.Data = []byte(fmt.Sprintf("order %d", i))
+			msg.Header.Add("Order", fmt.Sprintf("%d", i))
+			_, err = js.PublishMsg(msg)
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		checkErr(t, stream.FastDeleteMessage(1), "delete failed")
+		checkErr(t, stream.FastDeleteMessage(3), "delete failed")
+		checkErr(t, stream.DeleteMessage(5), "delete failed")
+
+		SetContext(context.Background())
+		dir := t.TempDir()
+		err = backupStream(stream, false, true, false, dir, 128*1024)
+		checkErr(t, err, "backup failed: %v", err)
+
+		backup, err := openStreamBackup(dir)
+		checkErr(t, err, "open failed: %v", err)
+
+		var seqs []string
+		err = backup.read(func(msg *streamMsgRecord) error {
+			seqs = append(seqs, fmt.Sprintf("%d", msg.Sequence))
+			if msg.Headers.Get("Order") != fmt.Sprintf("%d", msg.Sequence) {
+				t.Fatalf("invalid headers for %d: %v", msg.Sequence, msg.Headers)
+			}
+			if string(msg.Data) != fmt.Sprintf("order %d", msg.Sequence) {
+				t.Fatalf("invalid data for %d: %q", msg.Sequence, msg.Data)
+			}
+			if msg.Subject != fmt.Sprintf("orders.%d", msg.Sequence%2) {
+				t.Fatalf("invalid subject for %d: %q", msg.Sequence, msg.Subject)
+			}
+			return nil
+		})
+		checkErr(t, err, "read failed: %v", err)
+
+		assertListEquals(t, seqs, "2", "4", "6", "7", "8", "9", "10")
+		if backup.Config.Name != "ORDERS" || backup.State.Msgs != 7 {
+			t.Fatalf("invalid backup metadata: %+v", backup)
+		}
+		if backup.Contents.Messages != 7 || len(backup.Contents.Warnings) > 0 {
+			t.Fatalf("invalid backup contents: %+v", backup.Contents)
+		}
+		if len(backup.Consumers) != 1 || backup.Consumers[0].Name != "PROCESSOR" || backup.Consumers[0].Config.FilterSubject != "orders.new" {
+			t.Fatalf("invalid consumers: %+v", backup.Consumers)
+		}
+		if backup.Consumers[0].State == nil {
+			t.Fatalf("consumer state was not read")
+		}
+	})
+}
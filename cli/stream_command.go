@@ -365,13 +365,15 @@ Finding streams with certain subjects configured:
 	strGet.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
 	strGet.Flag("translate", "Translate the message data by running it through the given command before output").StringVar(&c.vwTranslate)
 
-	strBackup := str.Command("backup", "Creates a backup of a Stream over the NATS network").Alias("snapshot").Action(c.backupAction)
+	strBackupParent := str.Command("backup", "Creates and inspects backups of Streams").Alias("snapshot")
+	strBackup := strBackupParent.Command("create", "Creates a backup of a Stream over the NATS network").Default().Action(c.backupAction)
 	strBackup.Arg("stream", "Stream to backup").Required().StringVar(&c.stream)
 	strBackup.Arg("target", "Directory to create the backup in").Required().StringVar(&c.backupDirectory)
 	strBackup.Flag("progress", "Enables or disables progress reporting using a progress bar").Default("true").BoolVar(&c.showProgress)
 	strBackup.Flag("check", "Checks the Stream for health prior to backup").UnNegatableBoolVar(&c.healthCheck)
 	strBackup.Flag("consumers", "Enable or disable consumer backups").Default("true").BoolVar(&c.snapShotConsumers)
 	strBackup.Flag("chunk-size", "Sets a specific chunk size that the server will send").PlaceHolder("BYTES").Default("128KB").StringVar(&c.chunkSize)
+	configureStreamBackupCommand(strBackupParent)
 
 	strRestore := str.Command("restore", "Restore a Stream over the NATS network").Action(c.restoreAction)
 	strRestore.Arg("file", "The directory holding the backup to restore").Required().ExistingDirVar(&c.backupDirectory)
@@ -381,6 +383,11 @@ Finding streams with certain subjects configured:
 	strRestore.Flag("tag", "Place the stream on servers that has specific tags (pass multiple times)").StringsVar(&c.placementTags)
 	strRestore.Flag("replicas", "Override how many replicas of the data to create").Int64Var(&c.replicas)
 
+	configureStreamExportCommand(str)
+	configureStreamCompareCommand(str)
+	configureStreamReplayCommand(str)
+	configureStreamSearchCommand(str)
+
 	strSeal := str.Command("seal", "Seals a stream preventing further updates").Action(c.sealAction)
 	strSeal.Arg("stream", "The name of the Stream to seal").Required().StringVar(&c.stream)
 	strSeal.Flag("force", "Force sealing without prompting").Short('f').UnNegatableBoolVar(&c.force)
@@ -489,19 +496,12 @@ func (c *streamCmd) detectGaps(_ *fisk.ParseContext) error {
 		return nil
 	}
 
-	var table *tbl
 	if len(gaps) == 1 {
-		table = newTableWriter(fmt.Sprintf("1 gap found in Stream %s", c.stream))
+		fmt.Println(renderSeqRanges(fmt.Sprintf("1 gap found in Stream %s", c.stream), gaps))
 	} else {
-		table = newTableWriter(fmt.Sprintf("%s gaps found in Stream %s", f(len(gaps)), c.stream))
+		fmt.Println(renderSeqRanges(fmt.Sprintf("%s gaps found in Stream %s", f(len(gaps)), c.stream), gaps))
 	}
 
-	table.AddHeaders("First Message", "Last Message")
-	for _, gap := range gaps {
-		table.AddRow(f(gap[0]), f(gap[1]))
-	}
-	fmt.Println(table.Render())
-
 	return nil
 }
 
@@ -863,28 +863,7 @@ func (c *streamCmd) viewAction(_ *fisk.ParseContext) error {
 		case c.vwRaw:
 			fmt.Println(string(msg.Data))
 		default:
-			meta, err := jsm.ParseJSMsgMetadata(msg)
-			if err == nil {
-				fmt.Printf("[%d] Subject: %s Received: %s\n", meta.StreamSequence(), msg.Subject, meta.TimeStamp().Format(time.RFC3339))
-			} else {
-				fmt.Printf("Subject: %s Reply: %s\n", msg.Subject, msg.Reply)
-			}
-
-			if len(msg.Header) > 0 {
-				fmt.Println()
-				for k, vs := range msg.Header {
-					for _, v := range vs {
-						if k == "Nats-Stream-Source" {
-							v = strings.ReplaceAll(v, "\f", "\u240A")
-						}
-
-						fmt.Printf("  %s: %s\n", k, v)
-					}
-				}
-			}
-
-			fmt.Println()
-			outPutMSGBody(msg.Data, c.vwTranslate, msg.Subject, meta.Stream())
+			renderStreamMsg(msg, c.vwTranslate)
 		}
 
 		if shouldTerminate {
@@ -902,6 +881,35 @@ func (c *streamCmd) viewAction(_ *fisk.ParseContext) error {
 	}
 }
 
+// renderStreamMsg shows a message received from a stream consumer including its metadata and headers
+func renderStreamMsg(msg *nats.Msg, translate string) {
+	var stream string
+
+	meta, err := jsm.ParseJSMsgMetadata(msg)
+	if err == nil {
+		stream = meta.Stream()
+		fmt.Printf("[%d] Subject: %s Received: %s\n", meta.StreamSequence(), msg.Subject, meta.TimeStamp().Format(time.RFC3339))
+	} else {
+		fmt.Printf("Subject: %s Reply: %s\n", msg.Subject, msg.Reply)
+	}
+
+	if len(msg.Header) > 0 {
+		fmt.Println()
+		for k, vs := range msg.Header {
+			for _, v := range vs {
+				if k == "Nats-Stream-Source" {
+					v = strings.ReplaceAll(v, "\f", "\u240A")
+				}
+
+				fmt.Printf("  %s: %s\n", k, v)
+			}
+		}
+	}
+
+	fmt.Println()
+	outPutMSGBody(msg.Data, translate, msg.Subject, stream)
+}
+
 func (c *streamCmd) sealAction(_ *fisk.ParseContext) error {
 	c.connectAndAskStream()
 
@@ -0,0 +1,434 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/choria-io/fisk"
+	"github.com/gosuri/uiprogress"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	streamCompareSequence = "sequence"
+	streamCompareSource   = "source"
+)
+
+type streamCompareCmd struct {
+	streamA        string
+	streamB        string
+	contextB       string
+	domainB        string
+	match          string
+	subjects       []string
+	seqRange       string
+	ignoreHeaders  []string
+	json           bool
+	showProgress   bool
+	maxDifferences int
+
+	jsA nats.JetStreamContext
+	jsB nats.JetStreamContext
+}
+
+type streamCompareDifference struct {
+	Sequence uint64   `json:"seq"`
+	Fields   []string `json:"fields"`
+}
+
+type streamCompareResult struct {
+	StreamA     string                     `json:"stream_a"`
+	StreamB     string                     `json:"stream_b"`
+	Match       string                     `json:"match"`
+	Compared    uint64                     `json:"compared"`
+	Equal       uint64                     `json:"equal"`
+	MissingInB  [][2]uint64                `json:"missing_in_b,omitempty"`
+	ExtraInB    [][2]uint64                `json:"extra_in_b,omitempty"`
+	Different   []*streamCompareDifference `json:"different,omitempty"`
+	NumMissing  uint64                     `json:"num_missing"`
+	NumExtra    uint64                     `json:"num_extra"`
+	NumDiffered uint64                     `json:"num_different"`
+}
+
+type streamCompareMsg struct {
+	key     uint64
+	subject string
+	headers [32]byte
+	data    [32]byte
+}
+
+func configureStreamCompareCommand(str *fisk.CmdClause) {
+	c := &streamCompareCmd{}
+
+	help := `Compares the messages held in two streams
+
+Messages are matched by stream sequence, which suits mirrors and copies of
+a stream, or by the sequence recorded in the Nats-Stream-Source header for
+streams sourcing from the first stream. By default the mode is picked based
+on the configuration of the second stream.
+
+Subjects, headers and message bodies are compared and messages missing from
+the second stream, extra messages in the second stream and messages that
+differ are reported. Missing and extra messages are reported as sequence
+ranges in the same manner as nats stream gaps.
+
+The second stream can be accessed using a different context or JetStream
+domain.
+`
+
+	compare := str.Command("compare", "Compares the messages in two streams").Action(c.compareAction)
+	compare.HelpLong(help)
+	compare.Arg("a", "The stream to compare").Required().StringVar(&c.streamA)
+	compare.Arg("b", "The stream to compare to").Required().StringVar(&c.streamB)
+	compare.Flag("context-b", "Access the second stream using a different context").PlaceHolder("CONTEXT").StringVar(&c.contextB)
+	compare.Flag("domain-b", "Access the second stream in a different JetStream domain").PlaceHolder("DOMAIN").StringVar(&c.domainB)
+	compare.Flag("match", "How messages are matched (sequence, source)").EnumVar(&c.match, streamCompareSequence, streamCompareSource)
+	compare.Flag("subject", "Only compare messages matching a subject (pass multiple times)").StringsVar(&c.subjects)
+	compare.Flag("seq-range", "Only compare messages in a sequence range of the first stream like 10-20, 10- or -20").PlaceHolder("RANGE").StringVar(&c.seqRange)
+	compare.Flag("ignore-header", "Ignore a header when comparing messages (pass multiple times)").PlaceHolder("HEADER").StringsVar(&c.ignoreHeaders)
+	compare.Flag("max-differences", "Maximum number of differing messages to list").Default("100").IntVar(&c.maxDifferences)
+	compare.Flag("progress", "Enable progress bar").Default("true").BoolVar(&c.showProgress)
+	compare.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+}
+
+func (c *streamCompareCmd) compareAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.jsA, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	if c.contextB == "" && c.domainB == "" {
+		c.jsB = c.jsA
+	} else {
+		var nc *nats.Conn
+		nc, c.jsB, err = prepareContextJSHelper(c.contextB, c.domainB)
+		if err != nil {
+			return fmt.Errorf("setup failed: %v", err)
+		}
+		if c.contextB != "" {
+			defer nc.Close()
+		}
+	}
+
+	if c.json {
+		c.showProgress = false
+	}
+
+	res, err := c.compare()
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		err = printJSON(res)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.renderResult(res)
+	}
+
+	if res.NumMissing > 0 || res.NumExtra > 0 || res.NumDiffered > 0 {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func (c *streamCompareCmd) renderResult(res *streamCompareResult) {
+	cols := newColumns(fmt.Sprintf("Comparison of Stream %s and %s", res.StreamA, res.StreamB))
+	cols.AddRow("Matched By", res.Match)
+	cols.AddRow("Compared Messages", res.Compared)
+	cols.AddRow("Equal Messages", res.Equal)
+	cols.AddRow("Missing in Second", res.NumMissing)
+	cols.AddRow("Extra in Second", res.NumExtra)
+	cols.AddRow("Different Messages", res.NumDiffered)
+	cols.Frender(os.Stdout)
+	fmt.Println()
+
+	if len(res.MissingInB) > 0 {
+		fmt.Println(renderSeqRanges(fmt.Sprintf("Messages missing from %s", res.StreamB), res.MissingInB))
+	}
+	if len(res.ExtraInB) > 0 {
+		fmt.Println(renderSeqRanges(fmt.Sprintf("Extra messages in %s", res.StreamB), res.ExtraInB))
+	}
+
+	if len(res.Different) > 0 {
+		title := "Different messages"
+		if uint64(len(res.Different)) < res.NumDiffered {
+			title = fmt.Sprintf("First %d different messages", len(res.Different))
+		}
+
+		table := newTableWriter(title)
+		table.AddHeaders("Sequence", "Differences")
+		for _, d := range res.Different {
+			table.AddRow(f(d.Sequence), strings.Join(d.Fields, ", "))
+		}
+		fmt.Println(table.Render())
+	}
+}
+
+// detectMatch picks a match mode based on the configuration of the second stream
+func (c *streamCompareCmd) detectMatch() (string, error) {
+	if c.match != "" {
+		return c.match, nil
+	}
+
+	nfo, err := c.jsB.StreamInfo(c.streamB)
+	if err != nil {
+		return "", err
+	}
+
+	for _, source := range nfo.Config.Sources {
+		if source.Name == c.streamA {
+			return streamCompareSource, nil
+		}
+	}
+
+	return streamCompareSequence, nil
+}
+
+func (c *streamCompareCmd) compare() (*streamCompareResult, error) {
+	start, end, err := parseSeqRange(c.seqRange)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := c.detectMatch()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &streamCompareResult{StreamA: c.streamA, StreamB: c.streamB, Match: match}
+
+	var sopts []nats.SubOpt
+	subjects := splitCLISubjects(c.subjects)
+	if len(subjects) > 0 {
+		sopts = append(sopts, nats.ConsumerFilterSubjects(subjects...))
+	}
+
+	optsA := append([]nats.SubOpt{}, sopts...)
+	optsB := append([]nats.SubOpt{}, sopts...)
+	if start > 0 {
+		optsA = append(optsA, nats.StartSequence(start))
+		if match == streamCompareSequence {
+			optsB = append(optsB, nats.StartSequence(start))
+		}
+	}
+
+	walkerA, err := newStreamWalker(c.jsA, c.streamA, optsA...)
+	if err != nil {
+		return nil, fmt.Errorf("could not read stream %s: %v", c.streamA, err)
+	}
+	defer walkerA.close()
+
+	walkerB, err := newStreamWalker(c.jsB, c.streamB, optsB...)
+	if err != nil {
+		return nil, fmt.Errorf("could not read stream %s: %v", c.streamB, err)
+	}
+	defer walkerB.close()
+
+	var progress *uiprogress.Bar
+	if c.showProgress && walkerA.pending > 0 {
+		progress = uiprogress.AddBar(int(walkerA.pending)).AppendCompleted().PrependFunc(func(b *uiprogress.Bar) string {
+			return fmt.Sprintf("%s / %s", f(b.Current()), f(b.Total))
+		})
+		progress.Width = progressWidth()
+		uiprogress.Start()
+		defer func() {
+			uiprogress.Stop()
+			fmt.Println()
+		}()
+	}
+
+	inRange := func(key uint64) bool {
+		return key >= start && (end == 0 || key <= end)
+	}
+
+	nextA := func() (*streamCompareMsg, error) {
+		msg, meta, err := walkerA.next()
+		if msg == nil || err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress.Incr()
+		}
+		if !inRange(meta.Sequence.Stream) {
+			return nil, nil
+		}
+		return c.compareMsg(meta.Sequence.Stream, msg), nil
+	}
+
+	nextB := func() (*streamCompareMsg, error) {
+		for {
+			msg, meta, err := walkerB.next()
+			if msg == nil || err != nil {
+				return nil, err
+			}
+
+			key := meta.Sequence.Stream
+			if match == streamCompareSource {
+				var stream string
+				stream, key = c.sourceSequence(msg.Header.Get("Nats-Stream-Source"))
+				if stream != c.streamA || key < start {
+					continue
+				}
+			}
+
+			if end > 0 && key > end {
+				return nil, nil
+			}
+
+			return c.compareMsg(key, msg), nil
+		}
+	}
+
+	a, err := nextA()
+	if err != nil {
+		return nil, err
+	}
+	b, err := nextB()
+	if err != nil {
+		return nil, err
+	}
+
+	for a != nil || b != nil {
+		switch {
+		case b == nil || (a != nil && a.key < b.key):
+			res.MissingInB = appendSeqRange(res.MissingInB, a.key)
+			res.NumMissing++
+			res.Compared++
+			a, err = nextA()
+
+		case a == nil || b.key < a.key:
+			res.ExtraInB = appendSeqRange(res.ExtraInB, b.key)
+			res.NumExtra++
+			b, err = nextB()
+
+		default:
+			res.Compared++
+
+			var fields []string
+			if a.subject != b.subject {
+				fields = append(fields, "subject")
+			}
+			if a.headers != b.headers {
+				fields = append(fields, "headers")
+			}
+			if a.data != b.data {
+				fields = append(fields, "data")
+			}
+
+			if len(fields) == 0 {
+				res.Equal++
+			} else {
+				res.NumDiffered++
+				if len(res.Different) < c.maxDifferences {
+					res.Different = append(res.Different, &streamCompareDifference{Sequence: a.key, Fields: fields})
+				}
+			}
+
+			a, err = nextA()
+			if err == nil {
+				b, err = nextB()
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// sourceSequence extracts the origin stream and sequence from a Nats-Stream-Source header
+func (c *streamCompareCmd) sourceSequence(hdr string) (string, uint64) {
+	fields := strings.Fields(hdr)
+	if len(fields) < 2 {
+		return "", 0
+	}
+
+	seq, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return "", 0
+	}
+
+	stream, _, _ := strings.Cut(fields[0], ":")
+
+	return stream, seq
+}
+
+func (c *streamCompareCmd) compareMsg(key uint64, msg *nats.Msg) *streamCompareMsg {
+	res := &streamCompareMsg{key: key, subject: msg.Subject, data: sha256.Sum256(msg.Data)}
+
+	var keys []string
+	for k := range msg.Header {
+		if k == "Nats-Stream-Source" || strings.HasPrefix(k, "Nats-Expected-") {
+			continue
+		}
+
+		ignored := false
+		for _, i := range c.ignoreHeaders {
+			if strings.EqualFold(i, k) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := bytes.Buffer{}
+	for _, k := range keys {
+		for _, v := range msg.Header[k] {
+			fmt.Fprintf(&buf, "%s: %s\n", k, v)
+		}
+	}
+	res.headers = sha256.Sum256(buf.Bytes())
+
+	return res
+}
+
+// appendSeqRange adds seq to ranges, extending the last range when seq follows it
+func appendSeqRange(ranges [][2]uint64, seq uint64) [][2]uint64 {
+	if len(ranges) > 0 && ranges[len(ranges)-1][1]+1 == seq {
+		ranges[len(ranges)-1][1] = seq
+		return ranges
+	}
+
+	return append(ranges, [2]uint64{seq, seq})
+}
+
+// renderSeqRanges renders a table of sequence ranges like those produced by gap detection
+func renderSeqRanges(title string, ranges [][2]uint64) string {
+	table := newTableWriter(title)
+	table.AddHeaders("First Message", "Last Message")
+	for _, r := range ranges {
+		table.AddRow(f(r[0]), f(r[1]))
+	}
+
+	return table.Render()
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestAppendSeqRange(t *testing.T) {
+	var ranges [][2]uint64
+	for _, seq := range []uint64{1, 2, 3, 5, 7, 8} {
+		ranges = appendSeqRange(ranges, seq)
+	}
+
+	if len(ranges) != 3 || ranges[0] != [2]uint64{1, 3} || ranges[1] != [2]uint64{5, 5} || ranges[2] != [2]uint64{7, 8} {
+		t.Fatalf("invalid ranges: %v", ranges)
+	}
+}
+
+func TestStreamCompare(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		_, err = mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		_, err = mgr.NewStream("COPY", jsm.Subjects("copy.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 10; i++ {
+			_, err = js.Publish(fmt.Sprintf("orders.%d", i), []byte(fmt.Sprintf("order %d", i)))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		_, err = js.AddStream(&nats.StreamConfig{Name: "MIRROR", Mirror: &nats.StreamSource{Name: "ORDERS"}, Storage: nats.MemoryStorage})
+		checkErr(t, err, "create failed: %v", err)
+		_, err = js.AddStream(&nats.StreamConfig{Name: "SOURCED", Sources: []*nats.StreamSource{{Name: "ORDERS"}}, Storage: nats.MemoryStorage})
+		checkErr(t, err, "create failed: %v", err)
+
+		mirror, err := mgr.LoadStream("MIRROR")
+		checkErr(t, err, "load failed: %v", err)
+		sourced, err := mgr.LoadStream("SOURCED")
+		checkErr(t, err, "load failed: %v", err)
+
+		for _, s := range []*jsm.Stream{mirror, sourced} {
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				nfo, err := js.StreamInfo(s.Name())
+				checkErr(t, err, "info failed: %v", err)
+				if nfo.State.Msgs == 10 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("stream %s did not receive all messages", s.Name())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+
+		checkErr(t, mirror.DeleteMessage(3), "delete failed")
+		checkErr(t, mirror.DeleteMessage(4), "delete failed")
+		checkErr(t, sourced.DeleteMessage(7), "delete failed")
+
+		compare := func(b string, match string, seqRange string) *streamCompareResult {
+			t.Helper()
+			c := &streamCompareCmd{jsA: js, jsB: js, streamA: "ORDERS", streamB: b, match: match, seqRange: seqRange, maxDifferences: 100}
+			res, err := c.compare()
+			checkErr(t, err, "compare failed: %v", err)
+			return res
+		}
+
+		res := compare("MIRROR", "", "")
+		if res.Match != streamCompareSequence || res.Compared != 10 || res.Equal != 8 || res.NumMissing != 2 || res.NumExtra != 0 || res.NumDiffered != 0 {
+			t.Fatalf("invalid mirror comparison: %+v", res)
+		}
+		if len(res.MissingInB) != 1 || res.MissingInB[0] != [2]uint64{3, 4} {
+			t.Fatalf("invalid missing ranges: %v", res.MissingInB)
+		}
+
+		res = compare("SOURCED", "", "5-")
+		if res.Match != streamCompareSource || res.Compared != 6 || res.Equal != 5 || res.NumMissing != 1 || res.NumExtra != 0 {
+			t.Fatalf("invalid sourced comparison: %+v", res)
+		}
+		if len(res.MissingInB) != 1 || res.MissingInB[0] != [2]uint64{7, 7} {
+			t.Fatalf("invalid missing ranges: %v", res.MissingInB)
+		}
+
+		for i := 1; i <= 12; i++ {
+			_, err = js.Publish(fmt.Sprintf("copy.%d", i), []byte(fmt.Sprintf("order %d", i)))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		res = compare("COPY", "", "")
+		if res.Match != streamCompareSequence || res.Compared != 10 || res.NumDiffered != 10 || res.NumExtra != 2 {
+			t.Fatalf("invalid copy comparison: %+v", res)
+		}
+		if len(res.ExtraInB) != 1 || res.ExtraInB[0] != [2]uint64{11, 12} {
+			t.Fatalf("invalid extra ranges: %v", res.ExtraInB)
+		}
+		if res.Different[0].Sequence != 1 || len(res.Different[0].Fields) != 1 || res.Different[0].Fields[0] != "subject" {
+			t.Fatalf("invalid differences: %+v", res.Different[0])
+		}
+	})
+}
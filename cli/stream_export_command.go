@@ -0,0 +1,280 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/nats.go"
+)
+
+const streamImportBatch = 256
+
+type streamExportCmd struct {
+	stream   string
+	file     string
+	since    time.Duration
+	seqRange string
+	subjects []string
+	dedupe   bool
+
+	js nats.JetStreamContext
+}
+
+func configureStreamExportCommand(str *fisk.CmdClause) {
+	c := &streamExportCmd{}
+
+	exportHelp := `Exports messages from a Stream as JSON Lines
+
+Every message is written as a JSON document on its own line holding the
+subject, headers, data, original sequence and time stamp. The output can be
+imported into another Stream using nats stream import.
+
+When no file is given the messages are written to STDOUT.
+`
+
+	export := str.Command("export", "Exports messages from a Stream").Action(c.exportAction)
+	export.HelpLong(exportHelp)
+	export.Arg("stream", "Stream to export").Required().StringVar(&c.stream)
+	export.Arg("file", "File to write the messages to").StringVar(&c.file)
+	export.Flag("since", "Only export messages received since a duration like 1d3h5m2s").PlaceHolder("DURATION").DurationVar(&c.since)
+	export.Flag("seq-range", "Only export messages in a sequence range like 10-20, 10- or -20").PlaceHolder("RANGE").StringVar(&c.seqRange)
+	export.Flag("subject", "Only export messages matching a subject (pass multiple times)").StringsVar(&c.subjects)
+
+	importHelp := `Imports messages exported using nats stream export
+
+Messages are published with their original subjects and headers, new
+sequences and time stamps are assigned by the Stream. Messages extracted
+from backups using nats stream backup extract --format jsonl can also be
+imported.
+
+When the file is - the messages are read from STDIN.
+`
+
+	imp := str.Command("import", "Imports messages into a Stream").Action(c.importAction)
+	imp.HelpLong(importHelp)
+	imp.Arg("stream", "Stream to import into").Required().StringVar(&c.stream)
+	imp.Arg("file", "File holding the messages to import").Required().StringVar(&c.file)
+	imp.Flag("dedupe", "Sets a Nats-Msg-Id header based on the original message so repeated imports are discarded as duplicates").UnNegatableBoolVar(&c.dedupe)
+}
+
+func (c *streamExportCmd) exportAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	out := os.Stdout
+	if c.file != "" {
+		out, err = os.Create(c.file)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	cnt, err := c.exportMessages(out)
+	if err != nil {
+		return err
+	}
+
+	if c.file != "" {
+		fmt.Printf("Exported %s messages from Stream %s to %s\n", f(cnt), c.stream, c.file)
+	}
+
+	return nil
+}
+
+func (c *streamExportCmd) exportMessages(out io.Writer) (int, error) {
+	start, end, err := parseSeqRange(c.seqRange)
+	if err != nil {
+		return 0, err
+	}
+
+	if start > 0 && c.since > 0 {
+		return 0, fmt.Errorf("--since and --seq-range can not be used together")
+	}
+
+	var sopts []nats.SubOpt
+	switch {
+	case start > 0:
+		sopts = append(sopts, nats.StartSequence(start))
+	case c.since > 0:
+		sopts = append(sopts, nats.StartTime(time.Now().Add(-c.since)))
+	}
+
+	subjects := splitCLISubjects(c.subjects)
+	if len(subjects) > 0 {
+		sopts = append(sopts, nats.ConsumerFilterSubjects(subjects...))
+	}
+
+	walker, err := newStreamWalker(c.js, c.stream, sopts...)
+	if err != nil {
+		return 0, err
+	}
+	defer walker.close()
+
+	enc := json.NewEncoder(out)
+	cnt := 0
+
+	for {
+		msg, meta, err := walker.next()
+		if msg == nil || err != nil {
+			return cnt, err
+		}
+
+		if end > 0 && meta.Sequence.Stream > end {
+			return cnt, nil
+		}
+
+		rec := &streamMsgRecord{
+			Stream:   c.stream,
+			Subject:  msg.Subject,
+			Sequence: meta.Sequence.Stream,
+			Time:     meta.Timestamp.UTC(),
+			Data:     msg.Data,
+		}
+		if len(msg.Header) > 0 {
+			rec.Headers = msg.Header
+		}
+
+		err = enc.Encode(rec)
+		if err != nil {
+			return cnt, err
+		}
+		cnt++
+
+		if meta.Sequence.Stream == end {
+			return cnt, nil
+		}
+	}
+}
+
+func (c *streamExportCmd) importAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	in := os.Stdin
+	if c.file != "-" {
+		in, err = os.Open(c.file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	imported, dupes, err := c.importMessages(in)
+	if err != nil {
+		return fmt.Errorf("import failed after %s messages: %v", f(imported), err)
+	}
+
+	if dupes > 0 {
+		fmt.Printf("Imported %s messages into Stream %s, %s duplicates were discarded\n", f(imported), c.stream, f(dupes))
+	} else {
+		fmt.Printf("Imported %s messages into Stream %s\n", f(imported), c.stream)
+	}
+
+	return nil
+}
+
+func (c *streamExportCmd) importMessages(in io.Reader) (imported int, dupes int, err error) {
+	dec := json.NewDecoder(in)
+	futures := make([]nats.PubAckFuture, 0, streamImportBatch)
+	timeout := opts.Timeout
+	if timeout < 5*time.Second {
+		timeout = 5 * time.Second
+	}
+
+	flush := func() error {
+		if len(futures) == 0 {
+			return nil
+		}
+
+		select {
+		case <-c.js.PublishAsyncComplete():
+		case <-time.After(timeout):
+			return fmt.Errorf("timeout waiting for %d acknowledgements", c.js.PublishAsyncPending())
+		}
+
+		for _, future := range futures {
+			select {
+			case ack := <-future.Ok():
+				if ack.Duplicate {
+					dupes++
+				} else {
+					imported++
+				}
+			case err := <-future.Err():
+				return err
+			}
+		}
+
+		futures = futures[:0]
+
+		return nil
+	}
+
+	for n := 1; ; n++ {
+		var rec streamMsgRecord
+		err = dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, dupes, fmt.Errorf("invalid message %d: %v", n, err)
+		}
+
+		msg := nats.NewMsg(rec.Subject)
+		msg.Data = rec.Data
+		for k, v := range rec.Headers {
+			// expectations refer to the source stream and would fail here
+			if strings.HasPrefix(k, "Nats-Expected-") {
+				continue
+			}
+			msg.Header[k] = v
+		}
+
+		if c.dedupe && msg.Header.Get(nats.MsgIdHdr) == "" && rec.Stream != "" && rec.Sequence > 0 {
+			msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s:%d", rec.Stream, rec.Sequence))
+		}
+
+		future, err := c.js.PublishMsgAsync(msg, nats.ExpectStream(c.stream))
+		if err != nil {
+			return imported, dupes, err
+		}
+		futures = append(futures, future)
+
+		if len(futures) == streamImportBatch {
+			err = flush()
+			if err != nil {
+				return imported, dupes, err
+			}
+		}
+	}
+
+	return imported, dupes, flush()
+}
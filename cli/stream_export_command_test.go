@@ -0,0 +1,93 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestStreamExportImport(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		stream, err := mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 10; i++ {
+			msg := nats.NewMsg(fmt.Sprintf("orders.%d", i%2))
+			msg.Data = []byte(fmt.Sprintf("order %d", i))
+			msg.Header.Add("Order", fmt.Sprintf("%d", i))
+			_, err = js.PublishMsg(msg)
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		c := &streamExportCmd{js: js, stream: "ORDERS"}
+		export := func() *bytes.Buffer {
+			t.Helper()
+			out := &bytes.Buffer{}
+			_, err := c.exportMessages(out)
+			checkErr(t, err, "export failed: %v", err)
+			return out
+		}
+
+		c.seqRange = "3-6"
+		c.subjects = []string{"orders.1"}
+		cnt, err := c.exportMessages(&bytes.Buffer{})
+		checkErr(t, err, "export failed: %v", err)
+		if cnt != 2 {
+			t.Fatalf("expected 2 messages got %d", cnt)
+		}
+
+		c.subjects = []string{"orders.none"}
+		cnt, err = c.exportMessages(&bytes.Buffer{})
+		checkErr(t, err, "export failed: %v", err)
+		if cnt != 0 {
+			t.Fatalf("expected 0 messages got %d", cnt)
+		}
+
+		c.seqRange = ""
+		c.subjects = nil
+		out := export()
+
+		checkErr(t, stream.Purge(), "purge failed")
+
+		c.dedupe = true
+		imported, dupes, err := c.importMessages(bytes.NewReader(out.Bytes()))
+		checkErr(t, err, "import failed: %v", err)
+		if imported != 10 || dupes != 0 {
+			t.Fatalf("expected 10 imported messages got %d and %d duplicates", imported, dupes)
+		}
+
+		imported, dupes, err = c.importMessages(bytes.NewReader(out.Bytes()))
+		checkErr(t, err, "import failed: %v", err)
+		if imported != 0 || dupes != 10 {
+			t.Fatalf("expected 10 duplicates got %d imported and %d duplicates", imported, dupes)
+		}
+
+		msg, err := stream.ReadMessage(14)
+		checkErr(t, err, "read failed: %v", err)
+		hdrs, err := decodeHeadersMsg(msg.Header)
+		checkErr(t, err, "invalid headers: %v", err)
+		if msg.Subject != "orders.0" || string(msg.Data) != "order 4" || hdrs.Get("Order") != "4" || hdrs.Get(nats.MsgIdHdr) != "ORDERS:4" {
+			t.Fatalf("invalid message imported: %+v %v", msg, hdrs)
+		}
+	})
+}
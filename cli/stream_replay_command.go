@@ -0,0 +1,349 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/gosuri/uiprogress"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+type streamReplayCmd struct {
+	stream        string
+	subjectMaps   []string
+	rate          string
+	since         time.Duration
+	seqRange      string
+	subjects      []string
+	realtime      bool
+	toStream      string
+	targetContext string
+	targetDomain  string
+	count         int
+	showProgress  bool
+
+	js       nats.JetStreamContext
+	targetNc *nats.Conn
+	targetJs nats.JetStreamContext
+
+	transforms []server.SubjectTransformer
+	interval   time.Duration
+	loopback   []string
+}
+
+func configureStreamReplayCommand(str *fisk.CmdClause) {
+	c := &streamReplayCmd{}
+
+	help := `Replays messages stored in a Stream
+
+Messages are read from the Stream in order and published to Core NATS or,
+when --to-stream is set, to another Stream with acknowledgements. Subjects
+can be rewritten using one or more mappings in the same format as nats
+server mapping, messages not matching any mapping keep their subject.
+
+Replays can be paced using --rate like 500/s, 100/m or 10/100ms, or using
+--realtime which preserves the time between the original messages.
+
+Using --target-context messages can be replayed into another environment,
+for example stored production traffic into a staging system.
+
+Messages can not be replayed into the Stream they are read from, when
+publishing to Core NATS the subjects have to be mapped outside the Stream
+subjects.
+`
+
+	replay := str.Command("replay", "Replays stored messages with rate control and subject rewriting").Action(c.replayAction)
+	replay.HelpLong(help)
+	replay.Arg("stream", "Stream to replay").Required().StringVar(&c.stream)
+	replay.Flag("to-subject-map", "Rewrites subjects using a mapping like 'orders.* -> replay.orders.{{wildcard(1)}}' (pass multiple times)").PlaceHolder("MAP").StringsVar(&c.subjectMaps)
+	replay.Flag("to-stream", "Publish to a Stream, expecting acknowledgements from it").PlaceHolder("STREAM").StringVar(&c.toStream)
+	replay.Flag("target-context", "Publish using a different context").PlaceHolder("CONTEXT").StringVar(&c.targetContext)
+	replay.Flag("target-domain", "Publish to a Stream in a different JetStream domain").PlaceHolder("DOMAIN").StringVar(&c.targetDomain)
+	replay.Flag("rate", "Maximum rate to publish at like 500/s").PlaceHolder("RATE").StringVar(&c.rate)
+	replay.Flag("realtime", "Preserve the relative timing of the original messages").UnNegatableBoolVar(&c.realtime)
+	replay.Flag("since", "Only replay messages received since a duration like 1d3h5m2s").PlaceHolder("DURATION").DurationVar(&c.since)
+	replay.Flag("seq-range", "Only replay messages in a sequence range like 10-20, 10- or -20").PlaceHolder("RANGE").StringVar(&c.seqRange)
+	replay.Flag("subject", "Only replay messages matching a subject (pass multiple times)").StringsVar(&c.subjects)
+	replay.Flag("count", "Maximum number of messages to replay").IntVar(&c.count)
+	replay.Flag("progress", "Enable progress bar").Default("true").BoolVar(&c.showProgress)
+}
+
+func (c *streamReplayCmd) replayAction(_ *fisk.ParseContext) error {
+	nc, js, err := prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+	c.js = js
+
+	if c.targetContext == "" && c.targetDomain == "" {
+		c.targetNc, c.targetJs = nc, js
+	} else {
+		c.targetNc, c.targetJs, err = prepareContextJSHelper(c.targetContext, c.targetDomain)
+		if err != nil {
+			return fmt.Errorf("setup failed: %v", err)
+		}
+		if c.targetContext != "" {
+			defer c.targetNc.Close()
+		}
+	}
+
+	cnt, err := c.replay()
+	if err != nil {
+		return fmt.Errorf("replay failed after %s messages: %v", f(cnt), err)
+	}
+
+	target := "Core NATS"
+	if c.toStream != "" {
+		target = fmt.Sprintf("Stream %s", c.toStream)
+	}
+
+	fmt.Printf("Replayed %s messages from Stream %s to %s\n", f(cnt), c.stream, target)
+
+	return nil
+}
+
+func (c *streamReplayCmd) prepare() error {
+	var err error
+
+	if c.realtime && c.rate != "" {
+		return fmt.Errorf("--rate and --realtime can not be used together")
+	}
+
+	if c.rate != "" {
+		c.interval, err = parseRate(c.rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	// replaying into the source stream adds a message for every one replayed, the walk would never end
+	c.loopback = nil
+	if c.targetContext == "" && c.targetDomain == "" {
+		if c.toStream == c.stream {
+			return fmt.Errorf("can not replay Stream %s into itself", c.stream)
+		}
+
+		if c.toStream == "" {
+			nfo, err := c.js.StreamInfo(c.stream)
+			if err != nil {
+				return err
+			}
+			c.loopback = nfo.Config.Subjects
+		}
+	}
+
+	c.transforms = nil
+	for _, m := range c.subjectMaps {
+		src, dest, err := parseSubjectMap(m)
+		if err != nil {
+			return err
+		}
+
+		trans, err := server.NewSubjectTransform(src, dest)
+		if err != nil {
+			return fmt.Errorf("invalid subject map %q: %v", m, err)
+		}
+
+		c.transforms = append(c.transforms, trans)
+	}
+
+	return nil
+}
+
+func (c *streamReplayCmd) mapSubject(subject string) string {
+	for _, trans := range c.transforms {
+		mapped, err := trans.Match(subject)
+		if err == nil {
+			return mapped
+		}
+	}
+
+	return subject
+}
+
+func (c *streamReplayCmd) replay() (int, error) {
+	err := c.prepare()
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, err := parseSeqRange(c.seqRange)
+	if err != nil {
+		return 0, err
+	}
+
+	if start > 0 && c.since > 0 {
+		return 0, fmt.Errorf("--since and --seq-range can not be used together")
+	}
+
+	var sopts []nats.SubOpt
+	switch {
+	case start > 0:
+		sopts = append(sopts, nats.StartSequence(start))
+	case c.since > 0:
+		sopts = append(sopts, nats.StartTime(time.Now().Add(-c.since)))
+	}
+
+	subjects := splitCLISubjects(c.subjects)
+	if len(subjects) > 0 {
+		sopts = append(sopts, nats.ConsumerFilterSubjects(subjects...))
+	}
+
+	walker, err := newStreamWalker(c.js, c.stream, sopts...)
+	if err != nil {
+		return 0, err
+	}
+	defer walker.close()
+
+	var progress *uiprogress.Bar
+	if c.showProgress && walker.pending > 0 {
+		total := int(walker.pending)
+		if c.count > 0 && c.count < total {
+			total = c.count
+		}
+		progress = uiprogress.AddBar(total).AppendCompleted().PrependFunc(func(b *uiprogress.Bar) string {
+			return fmt.Sprintf("%s / %s", f(b.Current()), f(b.Total))
+		})
+		progress.Width = progressWidth()
+		uiprogress.Start()
+		defer func() {
+			uiprogress.Stop()
+			fmt.Println()
+		}()
+	}
+
+	var (
+		cnt       int
+		next      time.Time
+		firstTime time.Time
+		started   time.Time
+	)
+
+	for {
+		if c.count > 0 && cnt == c.count {
+			break
+		}
+
+		msg, meta, err := walker.next()
+		if err != nil {
+			return cnt, err
+		}
+		if msg == nil || (end > 0 && meta.Sequence.Stream > end) {
+			break
+		}
+
+		switch {
+		case c.realtime:
+			if firstTime.IsZero() {
+				firstTime = meta.Timestamp
+				started = time.Now()
+			}
+			time.Sleep(time.Until(started.Add(meta.Timestamp.Sub(firstTime))))
+
+		case c.interval > 0:
+			if !next.IsZero() {
+				time.Sleep(time.Until(next))
+			}
+			next = time.Now().Add(c.interval)
+		}
+
+		out := nats.NewMsg(c.mapSubject(msg.Subject))
+		for _, subj := range c.loopback {
+			if server.SubjectsCollide(subj, out.Subject) {
+				return cnt, fmt.Errorf("subject %s would be stored in Stream %s again, use --to-subject-map to replay to other subjects", out.Subject, c.stream)
+			}
+		}
+		out.Data = msg.Data
+		for k, v := range msg.Header {
+			// expectations refer to the source stream and would fail when republished
+			if strings.HasPrefix(k, "Nats-Expected-") {
+				continue
+			}
+			out.Header[k] = v
+		}
+
+		if c.toStream != "" {
+			_, err = c.targetJs.PublishMsg(out, nats.ExpectStream(c.toStream))
+		} else {
+			err = c.targetNc.PublishMsg(out)
+		}
+		if err != nil {
+			return cnt, err
+		}
+
+		cnt++
+		if progress != nil {
+			progress.Incr()
+		}
+
+		if meta.Sequence.Stream == end {
+			break
+		}
+	}
+
+	if c.toStream == "" {
+		return cnt, c.targetNc.Flush()
+	}
+
+	return cnt, nil
+}
+
+// parseSubjectMap parses a mapping like 'orders.* -> replay.orders.{{wildcard(1)}}'
+func parseSubjectMap(m string) (string, string, error) {
+	src, dest, ok := strings.Cut(m, "->")
+	src = strings.TrimSpace(src)
+	dest = strings.TrimSpace(dest)
+
+	if !ok || src == "" || dest == "" {
+		return "", "", fmt.Errorf("invalid subject map %q, expected a map like 'source -> destination'", m)
+	}
+
+	return src, dest, nil
+}
+
+// parseRate parses rates like 500, 500/s, 100/m or 10/100ms and returns the interval between messages
+func parseRate(rate string) (time.Duration, error) {
+	cnt, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		period = "s"
+	}
+
+	n, err := strconv.ParseUint(strings.TrimSpace(cnt), 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid rate %q", rate)
+	}
+
+	var d time.Duration
+	switch strings.TrimSpace(period) {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid rate %q", rate)
+		}
+	}
+
+	return d / time.Duration(n), nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestParseRate(t *testing.T) {
+	for _, tc := range []struct {
+		rate     string
+		interval time.Duration
+		err      bool
+	}{
+		{"500", 2 * time.Millisecond, false},
+		{"500/s", 2 * time.Millisecond, false},
+		{"60/m", time.Second, false},
+		{"10/100ms", 10 * time.Millisecond, false},
+		{"0/s", 0, true},
+		{"x/s", 0, true},
+		{"10/x", 0, true},
+	} {
+		interval, err := parseRate(tc.rate)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("expected an error for %q", tc.rate)
+			}
+			continue
+		}
+		assertNoError(t, err)
+		if interval != tc.interval {
+			t.Fatalf("expected %v for %q got %v", tc.interval, tc.rate, interval)
+		}
+	}
+}
+
+func TestStreamReplay(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		_, err = mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+		replayed, err := mgr.NewStream("REPLAY", jsm.Subjects("replay.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 10; i++ {
+			msg := nats.NewMsg(fmt.Sprintf("orders.%d", i%2))
+			msg.Data = []byte(fmt.Sprintf("order %d", i))
+			msg.Header.Add("Order", fmt.Sprintf("%d", i))
+			_, err = js.PublishMsg(msg, nats.ExpectStream("ORDERS"))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		c := &streamReplayCmd{
+			js:          js,
+			targetNc:    nc,
+			targetJs:    js,
+			stream:      "ORDERS",
+			toStream:    "REPLAY",
+			seqRange:    "3-",
+			rate:        "1000/s",
+			subjectMaps: []string{"orders.* -> replay.orders.{{wildcard(1)}}"},
+		}
+
+		start := time.Now()
+		cnt, err := c.replay()
+		checkErr(t, err, "replay failed: %v", err)
+		if cnt != 8 {
+			t.Fatalf("expected 8 messages got %d", cnt)
+		}
+		if time.Since(start) < 7*time.Millisecond {
+			t.Fatalf("replay was not rate limited")
+		}
+
+		msg, err := replayed.ReadMessage(1)
+		checkErr(t, err, "read failed: %v", err)
+		hdrs, err := decodeHeadersMsg(msg.Header)
+		checkErr(t, err, "invalid headers: %v", err)
+		if msg.Subject != "replay.orders.1" || string(msg.Data) != "order 3" || hdrs.Get("Order") != "3" {
+			t.Fatalf("invalid message replayed: %+v %v", msg, hdrs)
+		}
+
+		c.subjectMaps = []string{"orders.*"}
+		_, err = c.replay()
+		if err == nil {
+			t.Fatalf("expected an error for an invalid subject map")
+		}
+
+		// replays back into the source stream are refused
+		c = &streamReplayCmd{js: js, targetNc: nc, targetJs: js, stream: "ORDERS", toStream: "ORDERS"}
+		_, err = c.replay()
+		if err == nil || err.Error() != "can not replay Stream ORDERS into itself" {
+			t.Fatalf("expected a loop error got %v", err)
+		}
+
+		c = &streamReplayCmd{js: js, targetNc: nc, targetJs: js, stream: "ORDERS", subjectMaps: []string{"orders.1 -> replay.orders.1"}}
+		cnt, err = c.replay()
+		if err == nil || err.Error() != "subject orders.0 would be stored in Stream ORDERS again, use --to-subject-map to replay to other subjects" || cnt != 1 {
+			t.Fatalf("expected a loop error got %v after %d messages", err, cnt)
+		}
+	})
+}
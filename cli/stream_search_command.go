@@ -0,0 +1,340 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/nats-io/nats.go"
+)
+
+type streamSearchCmd struct {
+	stream     string
+	expression string
+	subjects   []string
+	seqRange   string
+	since      time.Duration
+	workers    int
+	count      int
+	raw        bool
+	translate  string
+	json       bool
+
+	js      nats.JetStreamContext
+	program *vm.Program
+}
+
+type streamSearchMatch struct {
+	msg   *nats.Msg
+	meta  *nats.MsgMetadata
+	index int
+}
+
+func configureStreamSearchCommand(str *fisk.CmdClause) {
+	c := &streamSearchCmd{}
+
+	help := `Searches the messages in a Stream using an expression
+
+Every message is matched against an expression that must return a boolean,
+the expression can access these values:
+
+   subject    the message subject
+   seq        the stream sequence
+   time       the time the message was received
+   headers    map of message headers, holding the first value of each
+   data       the message body as a string
+   size       the size of the message body
+   json       the message body parsed as JSON, nil when not valid JSON
+
+We use the expr language to perform matching, see
+https://expr.medv.io/docs/Language-Definition for detail about the expression
+language. Messages where the expression fails, for example when accessing
+missing JSON fields, do not match, use ?. to access optional fields.
+
+The Stream is split into sequence ranges that are searched in parallel, use
+--workers to adjust the parallelism. Searches using --since are not split.
+
+Examples:
+
+   nats stream search ORDERS --expr 'json?.customer?.id == "42" && headers["X-Region"] == "eu"'
+   nats stream search ORDERS --expr 'data contains "error"' --subject 'ORDERS.failed.>'
+`
+
+	search := str.Command("search", "Searches messages in a Stream using an expression").Action(c.searchAction)
+	search.HelpLong(help)
+	search.Arg("stream", "Stream to search").Required().StringVar(&c.stream)
+	search.Flag("expr", "Expression matching messages").Required().StringVar(&c.expression)
+	search.Flag("subject", "Only search messages matching a subject (pass multiple times)").StringsVar(&c.subjects)
+	search.Flag("seq-range", "Only search messages in a sequence range like 10-20, 10- or -20").PlaceHolder("RANGE").StringVar(&c.seqRange)
+	search.Flag("since", "Only search messages received since a duration like 1d3h5m2s").PlaceHolder("DURATION").DurationVar(&c.since)
+	search.Flag("workers", "Number of sequence ranges to search in parallel").Default("4").IntVar(&c.workers)
+	search.Flag("count", "Maximum number of matching messages to show").IntVar(&c.count)
+	search.Flag("raw", "Show only the message bodies").Short('r').UnNegatableBoolVar(&c.raw)
+	search.Flag("translate", "Translate the message data by running it through the given command before output").StringVar(&c.translate)
+	search.Flag("json", "Produce JSON output").Short('j').UnNegatableBoolVar(&c.json)
+}
+
+func (c *streamSearchCmd) searchAction(_ *fisk.ParseContext) error {
+	var err error
+
+	_, c.js, err = prepareJSHelper()
+	if err != nil {
+		return fmt.Errorf("setup failed: %v", err)
+	}
+
+	if c.json {
+		// matches are written as they are found so the array is produced incrementally
+		fmt.Print("[")
+		found, err := c.search(func(m *streamSearchMatch) error {
+			rec := &streamMsgRecord{Stream: c.stream, Subject: m.msg.Subject, Sequence: m.meta.Sequence.Stream, Time: m.meta.Timestamp.UTC(), Data: m.msg.Data}
+			if len(m.msg.Header) > 0 {
+				rec.Headers = m.msg.Header
+			}
+
+			j, err := json.MarshalIndent(rec, "  ", "  ")
+			if err != nil {
+				return err
+			}
+			if m.index > 0 {
+				fmt.Print(",")
+			}
+			fmt.Printf("\n  %s", j)
+
+			return nil
+		})
+		if found > 0 {
+			fmt.Println()
+		}
+		fmt.Println("]")
+
+		return err
+	}
+
+	found, err := c.search(func(m *streamSearchMatch) error {
+		if c.raw {
+			fmt.Println(string(m.msg.Data))
+			return nil
+		}
+
+		renderStreamMsg(m.msg, c.translate)
+		fmt.Println()
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.raw {
+		fmt.Printf("Found %s matching messages in Stream %s\n", f(found), c.stream)
+	}
+
+	return nil
+}
+
+// streamSearchRange is a sequence range of the stream searched by a single worker
+type streamSearchRange struct {
+	first uint64
+	last  uint64
+	found chan *streamSearchMatch
+	err   error
+}
+
+// search finds messages matching the expression and passes them to cb in sequence order, searching sequence
+// ranges of the stream in parallel, it stops once count matches were found and returns the number of matches
+func (c *streamSearchCmd) search(cb func(*streamSearchMatch) error) (int, error) {
+	var err error
+
+	c.program, err = expr.Compile(c.expression, expr.Env(map[string]any{}), expr.AsBool(), expr.AllowUndefinedVariables())
+	if err != nil {
+		return 0, fmt.Errorf("invalid expression: %v", err)
+	}
+
+	start, end, err := parseSeqRange(c.seqRange)
+	if err != nil {
+		return 0, err
+	}
+
+	if start > 0 && c.since > 0 {
+		return 0, fmt.Errorf("--since and --seq-range can not be used together")
+	}
+
+	nfo, err := c.js.StreamInfo(c.stream)
+	if err != nil {
+		return 0, err
+	}
+
+	if nfo.State.Msgs == 0 {
+		return 0, nil
+	}
+
+	if start < nfo.State.FirstSeq {
+		start = nfo.State.FirstSeq
+	}
+	if end == 0 || end > nfo.State.LastSeq {
+		end = nfo.State.LastSeq
+	}
+	if start > end {
+		return 0, nil
+	}
+
+	workers := uint64(c.workers)
+	if workers < 1 || c.since > 0 {
+		workers = 1
+	}
+	if span := end - start + 1; span < workers {
+		workers = span
+	}
+
+	var (
+		ranges []*streamSearchRange
+		wg     sync.WaitGroup
+		done   = make(chan struct{})
+	)
+
+	chunk := (end - start + 1) / workers
+	for i := uint64(0); i < workers; i++ {
+		r := &streamSearchRange{first: start + i*chunk, found: make(chan *streamSearchMatch, 100)}
+		r.last = r.first + chunk - 1
+		if i == workers-1 {
+			r.last = end
+		}
+		ranges = append(ranges, r)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(r.found)
+
+			r.err = c.searchRange(r, done)
+		}()
+	}
+
+	// later ranges block once their buffer is full until the earlier ranges were handled
+	defer wg.Wait()
+	defer close(done)
+
+	found := 0
+	for _, r := range ranges {
+		for m := range r.found {
+			m.index = found
+			err = cb(m)
+			if err != nil {
+				return found, err
+			}
+
+			found++
+			if c.count > 0 && found == c.count {
+				return found, nil
+			}
+		}
+
+		if r.err != nil {
+			return found, r.err
+		}
+	}
+
+	return found, nil
+}
+
+func (c *streamSearchCmd) searchRange(r *streamSearchRange, done chan struct{}) error {
+	var sopts []nats.SubOpt
+	if c.since > 0 {
+		sopts = append(sopts, nats.StartTime(time.Now().Add(-c.since)))
+	} else {
+		sopts = append(sopts, nats.StartSequence(r.first))
+	}
+
+	subjects := splitCLISubjects(c.subjects)
+	if len(subjects) > 0 {
+		sopts = append(sopts, nats.ConsumerFilterSubjects(subjects...))
+	}
+
+	walker, err := newStreamWalker(c.js, c.stream, sopts...)
+	if err != nil {
+		return err
+	}
+	defer walker.close()
+
+	found := 0
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		msg, meta, err := walker.next()
+		if msg == nil || err != nil {
+			return err
+		}
+
+		if meta.Sequence.Stream > r.last {
+			return nil
+		}
+
+		if !c.matches(msg, meta) {
+			continue
+		}
+
+		select {
+		case r.found <- &streamSearchMatch{msg: msg, meta: meta}:
+		case <-done:
+			return nil
+		}
+
+		// matches are handled in sequence order so each range contributes at most count matches
+		found++
+		if c.count > 0 && found == c.count {
+			return nil
+		}
+	}
+}
+
+func (c *streamSearchCmd) matches(msg *nats.Msg, meta *nats.MsgMetadata) bool {
+	headers := map[string]string{}
+	for k := range msg.Header {
+		headers[k] = msg.Header.Get(k)
+	}
+
+	var body any
+	if json.Unmarshal(msg.Data, &body) != nil {
+		body = nil
+	}
+
+	env := map[string]any{
+		"subject": msg.Subject,
+		"seq":     meta.Sequence.Stream,
+		"time":    meta.Timestamp,
+		"headers": headers,
+		"data":    string(msg.Data),
+		"size":    len(msg.Data),
+		"json":    body,
+	}
+
+	out, err := expr.Run(c.program, env)
+	if err != nil {
+		return false
+	}
+
+	matched, ok := out.(bool)
+
+	return ok && matched
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestStreamSearch(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		_, err = mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 100; i++ {
+			msg := nats.NewMsg(fmt.Sprintf("orders.%d", i%2))
+			msg.Data = []byte(fmt.Sprintf(`{"customer":{"id":"%d"}}`, i%10))
+			msg.Header.Add("X-Region", []string{"eu", "us"}[i%2])
+			_, err = js.PublishMsg(msg)
+			checkErr(t, err, "publish failed: %v", err)
+		}
+		_, err = js.Publish("orders.text", []byte("not json"))
+		checkErr(t, err, "publish failed: %v", err)
+
+		search := func(c *streamSearchCmd) []string {
+			t.Helper()
+			c.js = js
+			c.stream = "ORDERS"
+			var seqs []string
+			found, err := c.search(func(m *streamSearchMatch) error {
+				seqs = append(seqs, fmt.Sprintf("%d", m.meta.Sequence.Stream))
+				return nil
+			})
+			checkErr(t, err, "search failed: %v", err)
+			if found != len(seqs) {
+				t.Fatalf("expected %d matches got %d", len(seqs), found)
+			}
+			return seqs
+		}
+
+		seqs := search(&streamSearchCmd{expression: `json?.customer?.id == "4" && headers["X-Region"] == "eu"`, workers: 3})
+		assertListEquals(t, seqs, "4", "14", "24", "34", "44", "54", "64", "74", "84", "94")
+
+		seqs = search(&streamSearchCmd{expression: `json?.customer?.id == "4"`, workers: 7, seqRange: "20-60", count: 2})
+		assertListEquals(t, seqs, "24", "34")
+
+		seqs = search(&streamSearchCmd{expression: `data contains "not"`, workers: 4, subjects: []string{"orders.text"}})
+		assertListEquals(t, seqs, "101")
+
+		// searching stops once the handler fails
+		calls := 0
+		found, err := (&streamSearchCmd{js: js, stream: "ORDERS", expression: `size > 0`, workers: 4}).search(func(_ *streamSearchMatch) error {
+			calls++
+			return fmt.Errorf("stop")
+		})
+		if err == nil || err.Error() != "stop" || found != 0 || calls != 1 {
+			t.Fatalf("expected the search to stop: %v %d %d", err, found, calls)
+		}
+
+		_, err = (&streamSearchCmd{js: js, stream: "ORDERS", expression: `subject ==`}).search(nil)
+		if err == nil {
+			t.Fatalf("expected an error for an invalid expression")
+		}
+	})
+}
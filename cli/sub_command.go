@@ -14,16 +14,19 @@
 package cli
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/choria-io/fisk"
@@ -57,6 +60,9 @@ type subCmd struct {
 	jetStream             bool
 	ignoreSubjects        []string
 	wait                  time.Duration
+	record                string
+
+	capture *bufio.Writer
 }
 
 func configureSubCommand(app commandHost) {
@@ -74,6 +80,7 @@ func configureSubCommand(app commandHost) {
 	act.Flag("inbox", "Subscribes to a generate inbox").Short('i').UnNegatableBoolVar(&c.inbox)
 	act.Flag("count", "Quit after receiving this many messages").UintVar(&c.limit)
 	act.Flag("dump", "Dump received messages to files, 1 file per message. Specify - for null terminated STDOUT for use with xargs -0").PlaceHolder("DIRECTORY").StringVar(&c.dump)
+	act.Flag("record", "Records received messages to a capture file that can be played back using nats pub --playback").PlaceHolder("FILE").StringVar(&c.record)
 	act.Flag("headers-only", "Do not render any data, shows only headers").UnNegatableBoolVar(&c.headersOnly)
 	act.Flag("start-sequence", "Starts at a specific Stream sequence (requires JetStream)").PlaceHolder("SEQUENCE").Uint64Var(&c.sseq)
 	act.Flag("all", "Delivers all messages found in the Stream (requires JetStream").UnNegatableBoolVar(&c.deliverAll)
@@ -183,6 +190,9 @@ func (c *subCmd) subscribe(p *fisk.ParseContext) error {
 	if c.reportSubjects && c.reportSubjectsCount == 0 {
 		return fmt.Errorf("subject count must be at least one")
 	}
+	if c.record != "" && (c.dump != "" || c.reportSubjects || c.match) {
+		return fmt.Errorf("recording is not compatible with dumping, subject reports or matching replies")
+	}
 
 	if c.dump != "" && c.dump != "-" {
 		err = os.MkdirAll(c.dump, 0700)
@@ -208,6 +218,29 @@ func (c *subCmd) subscribe(p *fisk.ParseContext) error {
 	)
 	defer cancel()
 
+	if c.record != "" {
+		fh, err := os.Create(c.record)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+
+		c.capture = bufio.NewWriter(fh)
+		defer func() {
+			mu.Lock()
+			err := c.capture.Flush()
+			mu.Unlock()
+			if err != nil {
+				log.Printf("Could not save recorded messages: %s", err)
+			}
+		}()
+
+		// an interrupt ends the subscription normally so the buffered recording is written
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+	}
+
 	// If the wait timeout is set, then we will cancel after the timer fires.
 	var t *time.Timer
 	if c.wait > 0 {
@@ -336,6 +369,10 @@ func (c *subCmd) subscribe(p *fisk.ParseContext) error {
 	}
 
 	if (!c.raw && c.dump == "") || c.inbox {
+		if c.record != "" {
+			log.Printf("Recording messages to %s", c.record)
+		}
+
 		switch {
 		case c.jetStream:
 			// logs later depending on settings
@@ -499,6 +536,19 @@ func (c *subCmd) printMsg(msg *nats.Msg, reply *nats.Msg, ctr uint) {
 		fmt.Printf("<<< Reply Subject: %v\n", msg.Reply)
 	}
 
+	if c.capture != nil {
+		err := writeCaptureRecord(c.capture, msg, time.Now())
+		if err != nil {
+			log.Printf("Could not record message: %s", err)
+		}
+
+		if ctr%100 == 0 {
+			fmt.Print(".")
+		}
+
+		return
+	}
+
 	if c.dump != "" {
 		// Output format 1/3: dumping, to stdout or files
 
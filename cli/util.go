@@ -1553,3 +1553,168 @@ func mapKeys[M ~map[K]V, K comparable, V any](m M) []K {
 
 	return r
 }
+
+// prepareContextJSHelper prepares a JetStream context for a named context, domain overrides the context domain
+func prepareContextJSHelper(name string, domain string) (*nats.Conn, nats.JetStreamContext, error) {
+	if name == "" {
+		nc, js, err := prepareJSHelper()
+		if err != nil || domain == "" {
+			return nc, js, err
+		}
+
+		js, err = nc.JetStream(append(jsOpts(), nats.Domain(domain))...)
+		return nc, js, err
+	}
+
+	cfg, err := natscontext.New(name, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copts, err := cfg.NATSOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nc, err := nats.Connect(cfg.ServerURL(), copts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if domain == "" {
+		domain = cfg.JSDomain()
+	}
+
+	jsopts := []nats.JSOpt{nats.MaxWait(opts.Timeout)}
+	switch {
+	case domain != "":
+		jsopts = append(jsopts, nats.Domain(domain))
+	case cfg.JSAPIPrefix() != "":
+		jsopts = append(jsopts, nats.APIPrefix(cfg.JSAPIPrefix()))
+	}
+
+	js, err := nc.JetStream(jsopts...)
+	if err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
+
+	return nc, js, nil
+}
+
+// streamWalker walks the messages in a stream in order using an ordered consumer, messages added to the stream
+// after the walk started are not returned
+type streamWalker struct {
+	sub     *nats.Subscription
+	pending uint64
+	lastSeq uint64
+	done    bool
+	timeout time.Duration
+}
+
+func newStreamWalker(js nats.JetStreamContext, stream string, sopts ...nats.SubOpt) (*streamWalker, error) {
+	// the last sequence is captured before subscribing so streams receiving messages can still be walked to the end
+	nfo, err := js.StreamInfo(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := js.SubscribeSync("", append([]nats.SubOpt{nats.BindStream(stream), nats.OrderedConsumer()}, sopts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+
+	w := &streamWalker{
+		sub:     sub,
+		pending: info.NumPending + info.Delivered.Consumer,
+		lastSeq: nfo.State.LastSeq,
+		done:    nfo.State.Msgs == 0 || (info.NumPending == 0 && info.Delivered.Consumer == 0),
+		timeout: opts.Timeout,
+	}
+
+	if w.timeout < 5*time.Second {
+		w.timeout = 5 * time.Second
+	}
+
+	return w, nil
+}
+
+// next returns the next message in the stream, nil once all messages present when the walk started were returned
+func (w *streamWalker) next() (*nats.Msg, *nats.MsgMetadata, error) {
+	if w.done {
+		return nil, nil, nil
+	}
+
+	msg, err := w.sub.NextMsg(w.timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if meta.Sequence.Stream > w.lastSeq {
+		w.done = true
+		return nil, nil, nil
+	}
+
+	w.done = meta.NumPending == 0 || meta.Sequence.Stream == w.lastSeq
+
+	return msg, meta, nil
+}
+
+func (w *streamWalker) close() error {
+	return w.sub.Unsubscribe()
+}
+
+// captureRecord is a message held in a traffic capture, captures are stored as JSON Lines
+type captureRecord struct {
+	Time    time.Time   `json:"time"`
+	Subject string      `json:"subject"`
+	Reply   string      `json:"reply,omitempty"`
+	Headers nats.Header `json:"headers,omitempty"`
+	Data    []byte      `json:"data"`
+}
+
+func writeCaptureRecord(w io.Writer, msg *nats.Msg, received time.Time) error {
+	rec := &captureRecord{
+		Time:    received.UTC(),
+		Subject: msg.Subject,
+		Reply:   msg.Reply,
+		Data:    msg.Data,
+	}
+	if len(msg.Header) > 0 {
+		rec.Headers = msg.Header
+	}
+
+	return json.NewEncoder(w).Encode(rec)
+}
+
+// readCapture calls cb for every message in a capture, in the order they were recorded
+func readCapture(r io.Reader, cb func(*captureRecord) error) error {
+	dec := json.NewDecoder(r)
+
+	for n := 1; ; n++ {
+		rec := &captureRecord{}
+		err := dec.Decode(rec)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid capture record %d: %v", n, err)
+		}
+
+		err = cb(rec)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -15,11 +15,15 @@ package cli
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/nats-io/jsm.go"
 	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
 )
 
 func checkErr(t *testing.T, err error, format string, a ...any) {
@@ -262,3 +266,45 @@ func TestMultipleSort(t *testing.T) {
 		t.Fatalf("expected true")
 	}
 }
+
+func TestStreamWalker(t *testing.T) {
+	withJetStream(t, func(_ *server.Server, nc *nats.Conn, mgr *jsm.Manager) {
+		js, err := nc.JetStream()
+		checkErr(t, err, "js failed: %v", err)
+
+		_, err = mgr.NewStream("ORDERS", jsm.Subjects("orders.>"), jsm.MemoryStorage())
+		checkErr(t, err, "create failed: %v", err)
+
+		for i := 1; i <= 10; i++ {
+			_, err = js.Publish(fmt.Sprintf("orders.%d", i%2), []byte("order"))
+			checkErr(t, err, "publish failed: %v", err)
+		}
+
+		walk := func(sopts ...nats.SubOpt) []string {
+			t.Helper()
+
+			walker, err := newStreamWalker(js, "ORDERS", sopts...)
+			checkErr(t, err, "walker failed: %v", err)
+			defer walker.close()
+
+			// messages added while walking are not part of the walk
+			for i := 0; i < 5; i++ {
+				_, err = js.Publish("orders.1", []byte("late"))
+				checkErr(t, err, "publish failed: %v", err)
+			}
+
+			var seqs []string
+			for {
+				msg, meta, err := walker.next()
+				checkErr(t, err, "next failed: %v", err)
+				if msg == nil {
+					return seqs
+				}
+				seqs = append(seqs, fmt.Sprintf("%d", meta.Sequence.Stream))
+			}
+		}
+
+		assertListEquals(t, walk(nats.StartSequence(8)), "8", "9", "10")
+		assertListEquals(t, walk(nats.StartSequence(12), nats.ConsumerFilterSubjects("orders.1")), "12", "13", "14", "15")
+	})
+}